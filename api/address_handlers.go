@@ -0,0 +1,222 @@
+package api
+
+import (
+	"AAHAOMS/OMS/models"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *ApiServer) handleGetCustomerAddresses(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := s.customerFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	addresses, err := s.Store.GetCustomerAddresses(customerID)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching addresses")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(addresses)
+}
+
+func (s *ApiServer) handleCreateCustomerAddress(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := s.customerFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var address models.CustomerAddress
+	if err := json.NewDecoder(r.Body).Decode(&address); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, address) {
+		return
+	}
+	address.CustomerID = customerID
+
+	id, err := s.Store.CreateCustomerAddress(address)
+	if err != nil {
+		writeStoreError(w, err, "Error creating address")
+		return
+	}
+
+	created, _ := s.Store.GetCustomerAddress(customerID, id)
+	s.audit(r, "create", "customer_address", id, nil, created)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (s *ApiServer) handleUpdateCustomerAddress(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := s.customerFromPath(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["address_id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid address ID")
+		return
+	}
+
+	var address models.CustomerAddress
+	if err := json.NewDecoder(r.Body).Decode(&address); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, address) {
+		return
+	}
+	address.ID = id
+	address.CustomerID = customerID
+
+	before, err := s.Store.GetCustomerAddress(customerID, id)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching address")
+		return
+	}
+
+	if err := s.Store.UpdateCustomerAddress(address); err != nil {
+		writeStoreError(w, err, "Error updating address")
+		return
+	}
+
+	after, _ := s.Store.GetCustomerAddress(customerID, id)
+	s.audit(r, "update", "customer_address", id, before, after)
+
+	json.NewEncoder(w).Encode(after)
+}
+
+func (s *ApiServer) handleDeleteCustomerAddress(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := s.customerFromPath(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["address_id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid address ID")
+		return
+	}
+
+	before, _ := s.Store.GetCustomerAddress(customerID, id)
+
+	if err := s.Store.DeleteCustomerAddress(customerID, id); err != nil {
+		writeStoreError(w, err, "Error deleting address")
+		return
+	}
+	s.audit(r, "delete", "customer_address", id, before, nil)
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Address deleted successfully"})
+}
+
+func (s *ApiServer) handleGetCustomerContacts(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := s.customerFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	contacts, err := s.Store.GetCustomerContacts(customerID)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching contacts")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(contacts)
+}
+
+func (s *ApiServer) handleCreateCustomerContact(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := s.customerFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var contact models.CustomerContact
+	if err := json.NewDecoder(r.Body).Decode(&contact); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, contact) {
+		return
+	}
+	contact.CustomerID = customerID
+
+	id, err := s.Store.CreateCustomerContact(contact)
+	if err != nil {
+		writeStoreError(w, err, "Error creating contact")
+		return
+	}
+
+	created, _ := s.Store.GetCustomerContact(customerID, id)
+	s.audit(r, "create", "customer_contact", id, nil, created)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (s *ApiServer) handleUpdateCustomerContact(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := s.customerFromPath(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["contact_id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid contact ID")
+		return
+	}
+
+	var contact models.CustomerContact
+	if err := json.NewDecoder(r.Body).Decode(&contact); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, contact) {
+		return
+	}
+	contact.ID = id
+	contact.CustomerID = customerID
+
+	before, err := s.Store.GetCustomerContact(customerID, id)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching contact")
+		return
+	}
+
+	if err := s.Store.UpdateCustomerContact(contact); err != nil {
+		writeStoreError(w, err, "Error updating contact")
+		return
+	}
+
+	after, _ := s.Store.GetCustomerContact(customerID, id)
+	s.audit(r, "update", "customer_contact", id, before, after)
+
+	json.NewEncoder(w).Encode(after)
+}
+
+func (s *ApiServer) handleDeleteCustomerContact(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := s.customerFromPath(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["contact_id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid contact ID")
+		return
+	}
+
+	before, _ := s.Store.GetCustomerContact(customerID, id)
+
+	if err := s.Store.DeleteCustomerContact(customerID, id); err != nil {
+		writeStoreError(w, err, "Error deleting contact")
+		return
+	}
+	s.audit(r, "delete", "customer_contact", id, before, nil)
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Contact deleted successfully"})
+}
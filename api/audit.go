@@ -0,0 +1,193 @@
+package api
+
+import (
+	"AAHAOMS/OMS/models"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// actorFromRequest identifies who made a request from the credential it was
+// authenticated with
+func actorFromRequest(r *http.Request) string {
+	if actor, ok := r.Context().Value(actorKey).(string); ok && actor != "" {
+		return actor
+	}
+	return "anonymous"
+}
+
+// actorHint is the user a client claims to act for in X-Actor. Nothing
+// checks it, so it is kept apart from the actor.
+func actorHint(r *http.Request) string {
+	hint := strings.TrimSpace(r.Header.Get("X-Actor"))
+	if runes := []rune(hint); len(runes) > 150 {
+		hint = string(runes[:150])
+	}
+	return hint
+}
+
+// audit appends an entry for a create, update or delete. before is nil for
+// creates and after is nil for deletes. Failures are logged but never fail
+// the request, since the change itself has already been committed.
+func (s *ApiServer) audit(r *http.Request, action, entityType string, entityID any, before, after any) {
+	entry := models.AuditEntry{
+		Actor:      actorFromRequest(r),
+		ActorHint:  actorHint(r),
+		RemoteAddr: r.RemoteAddr,
+		Method:     r.Method,
+		Route:      r.URL.Path,
+		EntityType: entityType,
+		EntityID:   fmt.Sprint(entityID),
+		Action:     action,
+	}
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			entry.Route = tmpl
+		}
+	}
+
+	var err error
+	if entry.Before, err = marshalSnapshot(before); err != nil {
+		log.Printf("Error encoding audit snapshot: %v", err)
+	}
+	if entry.After, err = marshalSnapshot(after); err != nil {
+		log.Printf("Error encoding audit snapshot: %v", err)
+	}
+	entry.Diff = diffSnapshots(entry.Before, entry.After)
+
+	if err := s.Store.RecordAudit(entry); err != nil {
+		log.Printf("Error writing audit entry for %s %s %s: %v", action, entityType, entry.EntityID, err)
+	}
+}
+
+func marshalSnapshot(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// diffSnapshots returns {"field": {"from": ..., "to": ...}} for every
+// top-level field that differs between the two snapshots.
+func diffSnapshots(before, after json.RawMessage) json.RawMessage {
+	var b, a map[string]any
+	json.Unmarshal(before, &b)
+	json.Unmarshal(after, &a)
+	if b == nil && a == nil {
+		return nil
+	}
+
+	diff := map[string]map[string]any{}
+	for k, bv := range b {
+		if av, ok := a[k]; !ok || !reflect.DeepEqual(av, bv) {
+			diff[k] = map[string]any{"from": bv, "to": a[k]}
+		}
+	}
+	for k, av := range a {
+		if _, ok := b[k]; !ok {
+			diff[k] = map[string]any{"from": nil, "to": av}
+		}
+	}
+	if len(diff) == 0 {
+		return nil
+	}
+
+	out, _ := json.Marshal(diff)
+	return out
+}
+
+func auditFilterFromQuery(r *http.Request) (models.AuditFilter, error) {
+	q := r.URL.Query()
+	filter := models.AuditFilter{
+		EntityType: q.Get("entity"),
+		EntityID:   q.Get("id"),
+		Actor:      q.Get("actor"),
+	}
+	var err error
+	if filter.From, err = dateParam(r, "from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = dateParam(r, "to"); err != nil {
+		return filter, err
+	}
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 {
+		filter.Limit = l
+	}
+	if id, err := strconv.Atoi(q.Get("before_id")); err == nil && id > 0 {
+		filter.BeforeID = id
+	}
+	return filter, nil
+}
+
+// handleGetAudit returns the newest matching entries first. Pass the last
+// entry's ID as before_id to page back through older ones.
+func (s *ApiServer) handleGetAudit(w http.ResponseWriter, r *http.Request) {
+	filter, err := auditFilterFromQuery(r)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	filter.NewestFirst = true
+	if filter.Limit == 0 || filter.Limit > 1000 {
+		filter.Limit = 100
+	}
+
+	entries, err := s.Store.GetAuditEntries(filter)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching audit log")
+		return
+	}
+	json.NewEncoder(w).Encode(entries)
+}
+
+// handleExportAudit streams the filtered audit log as CSV or JSON lines
+func (s *ApiServer) handleExportAudit(w http.ResponseWriter, r *http.Request) {
+	filter, err := auditFilterFromQuery(r)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	format := r.URL.Query().Get("format")
+
+	switch format {
+	case "", "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=audit_log.csv")
+
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "occurred_at", "actor", "actor_hint", "remote_addr", "method", "route", "entity_type", "entity_id", "action", "before", "after", "diff"})
+		err := s.Store.StreamAuditEntries(filter, func(e models.AuditEntry) error {
+			return cw.Write([]string{
+				strconv.Itoa(e.ID), e.OccurredAt, e.Actor, e.ActorHint, e.RemoteAddr, e.Method, e.Route, e.EntityType, e.EntityID,
+				e.Action, string(e.Before), string(e.After), string(e.Diff),
+			})
+		})
+		cw.Flush()
+		if err != nil {
+			log.Printf("Audit export aborted: %v", err)
+		}
+
+	case "jsonl":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", "attachment; filename=audit_log.jsonl")
+
+		enc := json.NewEncoder(w)
+		if err := s.Store.StreamAuditEntries(filter, func(e models.AuditEntry) error { return enc.Encode(e) }); err != nil {
+			log.Printf("Audit export aborted: %v", err)
+		}
+
+	default:
+		writeBadRequest(w, "format must be csv or jsonl")
+	}
+}
@@ -1,13 +1,12 @@
 package api
 
 import (
+	"AAHAOMS/OMS/mailer"
 	"AAHAOMS/OMS/models"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
 	"net/http"
-	"net/smtp"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -19,13 +18,6 @@ func generateUniqueKey() string {
 	return hex.EncodeToString(bytes)
 }
 
-func sendMailtrap(email, token string) error {
-	auth := smtp.PlainAuth("", os.Getenv("MAILTRAP_USER"), os.Getenv("MAILTRAP_PASS"), "smtp.mailtrap.io")
-	to := []string{email}
-	msg := []byte(fmt.Sprintf("Subject: Your Login Token\n\nUse this token to authenticate: %s", token))
-	return smtp.SendMail("smtp.mailtrap.io:587", auth, "no-reply@example.com", to, msg)
-}
-
 func generateJWT(email, secretKey string) (string, error) {
 	claims := &jwt.RegisteredClaims{
 		Subject:   email,
@@ -37,11 +29,12 @@ func generateJWT(email, secretKey string) (string, error) {
 	return token.SignedString([]byte(secretKey)) // Use the unique key
 }
 
-func (s *ApiServer) sendMailtrap(email, token string) error {
-	auth := smtp.PlainAuth("", os.Getenv("MAILTRAP_USER"), os.Getenv("MAILTRAP_PASS"), "smtp.mailtrap.io")
-	to := []string{email}
-	msg := []byte(fmt.Sprintf("Subject: Your Login Token\n\nUse this token to authenticate: %s", token))
-	return smtp.SendMail("smtp.mailtrap.io:587", auth, "no-reply@example.com", to, msg)
+func (s *ApiServer) sendLoginToken(email, token string) error {
+	return s.Mailer.Send(mailer.Message{
+		To:      []string{email},
+		Subject: "Your Login Token",
+		Text:    fmt.Sprintf("Use this token to authenticate: %s", token),
+	})
 }
 
 func (s *ApiServer) loginHandler(w http.ResponseWriter, r *http.Request) error {
@@ -50,12 +43,12 @@ func (s *ApiServer) loginHandler(w http.ResponseWriter, r *http.Request) error {
 	// Check if email exists in auth_users
 	exists, err := s.Store.IsUserExists(email)
 	if err != nil {
-		http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Database error", nil)
 		return nil
 	}
 
 	if !exists {
-		http.Error(w, `{"error": "Email not registered"}`, http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "Email not registered", nil)
 		return nil
 	}
 
@@ -65,14 +58,14 @@ func (s *ApiServer) loginHandler(w http.ResponseWriter, r *http.Request) error {
 	// Store OTP in DB
 	err = s.Store.AddOtp(models.AuthUser{Email: email, OTP: otp})
 	if err != nil {
-		http.Error(w, `{"error": "Failed to store OTP"}`, http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to store OTP", nil)
 		return nil
 	}
 
 	// Send OTP via email
-	err = sendMailtrap(email, otp)
+	err = s.sendLoginToken(email, otp)
 	if err != nil {
-		http.Error(w, `{"error": "Failed to send email"}`, http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to send email", nil)
 		return nil
 	}
 
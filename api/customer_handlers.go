@@ -2,8 +2,8 @@ package api
 
 import (
 	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/webhooks"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
 
@@ -14,23 +14,30 @@ func (s *ApiServer) handleCustomers(w http.ResponseWriter, r *http.Request) {
 	var customer models.Customer
 
 	if err := json.NewDecoder(r.Body).Decode(&customer); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, customer) {
 		return
 	}
 
 	id, err := s.Store.CreateCustomer(customer.Name, customer.Number, customer.Email, customer.Country, customer.Address)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creating customer: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error creating customer")
 		return
 	}
 
+	customer.ID = id
+	s.publishEvent(webhooks.CustomerCreated, customer)
+	s.audit(r, "create", "customer", id, nil, customer)
+
 	json.NewEncoder(w).Encode(map[string]int{"customer_id": id})
 }
 
 func (s *ApiServer) handleEditCustomers(w http.ResponseWriter, r *http.Request) {
 	var customer models.Customer
 	if err := json.NewDecoder(r.Body).Decode(&customer); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		writeInvalidPayload(w)
 		return
 	}
 	vars := mux.Vars(r)
@@ -38,16 +45,30 @@ func (s *ApiServer) handleEditCustomers(w http.ResponseWriter, r *http.Request)
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid customer ID", http.StatusBadRequest)
+		writeBadRequest(w, "Invalid customer ID")
 		return
 	}
 	customer.ID = id
+	if !validateRequest(w, customer) {
+		return
+	}
+
+	before, err := s.Store.GetCustomerByID(idStr)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching customer")
+		return
+	}
+
 	err = s.Store.EditCustumerDetails(customer)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error updating customer: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error updating customer")
 		return
 	}
 
+	after, _ := s.Store.GetCustomerByID(idStr)
+	s.publishEvent(webhooks.CustomerUpdated, after)
+	s.audit(r, "update", "customer", id, before, after)
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Customer updated successfully"})
 }
@@ -58,20 +79,24 @@ func (s *ApiServer) getCustomerByID(w http.ResponseWriter, r *http.Request) {
 
 	customer, err := s.Store.GetCustomerByID(id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching customer: %v", err), http.StatusInternalServerError)
-		return
-	}
-	if customer == nil {
-		http.Error(w, "Customer not found", http.StatusNotFound)
+		writeStoreError(w, err, "Error fetching customer")
 		return
 	}
 	json.NewEncoder(w).Encode(customer)
 }
 
+// getAllCustomers returns every customer as a plain array, or a ranked page
+// of matches in an envelope when q, page or page_size is given.
 func (s *ApiServer) getAllCustomers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Has("q") || query.Has("page") || query.Has("page_size") {
+		s.searchCustomers(w, r)
+		return
+	}
+
 	customers, err := s.Store.GetAllCustomers()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching customers: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error fetching customers")
 		return
 	}
 
@@ -83,10 +108,50 @@ func (s *ApiServer) getAllCustomers(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(customers)
 }
 
+func (s *ApiServer) searchCustomers(w http.ResponseWriter, r *http.Request) {
+	page, err := intParam(r, "page", 1, 1, 1000000)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	pageSize, err := intParam(r, "page_size", 25, 1, 100)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	result, err := s.Store.SearchCustomers(r.URL.Query().Get("q"), page, pageSize)
+	if err != nil {
+		writeStoreError(w, err, "Error searching customers")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleCustomerTypeahead returns ID and name pairs for pickers
+func (s *ApiServer) handleCustomerTypeahead(w http.ResponseWriter, r *http.Request) {
+	limit, err := intParam(r, "limit", 10, 1, 50)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	options, err := s.Store.GetCustomerOptions(r.URL.Query().Get("q"), limit)
+	if err != nil {
+		writeStoreError(w, err, "Error searching customers")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(options)
+}
+
 func (s *ApiServer) getCustumerCount(w http.ResponseWriter, r *http.Request) {
 	count, err := s.Store.CountCustumer()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching customer: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error fetching customer")
 		return
 	}
 	json.NewEncoder(w).Encode(count)
@@ -95,16 +160,137 @@ func (s *ApiServer) handleDeleteCustomer(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid customer ID", http.StatusBadRequest)
+		writeBadRequest(w, "Invalid customer ID")
 		return
 	}
 
+	before, _ := s.Store.GetCustomerByID(vars["id"])
+
 	err = s.Store.DeleteCustomer(id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error deleting customer: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error deleting customer")
 		return
 	}
+	s.publishEvent(webhooks.CustomerDeleted, map[string]int{"id": id})
+	s.audit(r, "delete", "customer", id, before, nil)
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Customer deleted successfully"})
 }
+
+func (s *ApiServer) handleCustomerNotificationPreference(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid customer ID")
+		return
+	}
+
+	var payload struct {
+		OptOut bool `json:"opt_out"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+
+	before, _ := s.Store.GetCustomerByID(vars["id"])
+
+	if err := s.Store.SetCustomerNotificationOptOut(id, payload.OptOut); err != nil {
+		writeStoreError(w, err, "Error updating notification preference")
+		return
+	}
+
+	after, _ := s.Store.GetCustomerByID(vars["id"])
+	s.audit(r, "update", "customer", id, before, after)
+
+	json.NewEncoder(w).Encode(map[string]bool{"notifications_opt_out": payload.OptOut})
+}
+
+// customerFromPath reads the {id} route variable and checks the customer
+// exists, writing the error response when it doesn't.
+func (s *ApiServer) customerFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid customer ID")
+		return 0, false
+	}
+	if _, err := s.Store.GetCustomerByID(vars["id"]); err != nil {
+		writeStoreError(w, err, "Error fetching customer")
+		return 0, false
+	}
+	return id, true
+}
+
+func (s *ApiServer) handleGetCustomerOrders(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.customerFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	orders, err := s.Store.GetOrdersByCustomerID(id)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching customer orders")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(orders)
+}
+
+func (s *ApiServer) handleGetCustomerShipments(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.customerFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	shipments, err := s.Store.GetShipmentsByCustomerID(id)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching customer shipments")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shipments)
+}
+
+func (s *ApiServer) handleGetCustomerSales(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.customerFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	sales, err := s.Store.GetCustomerSales(id)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching customer sales")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sales)
+}
+
+// handleGetCustomerSummary returns lifetime totals plus the most recent
+// orders and shipments (?recent=, default 5).
+func (s *ApiServer) handleGetCustomerSummary(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid customer ID")
+		return
+	}
+	recent, err := intParam(r, "recent", 5, 0, 50)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	summary, err := s.Store.GetCustomerSummary(id, recent)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching customer summary")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
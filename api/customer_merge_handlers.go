@@ -0,0 +1,93 @@
+package api
+
+import (
+	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/webhooks"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// handleGetDuplicateCustomers lists likely duplicate pairs, optionally only
+// those involving ?customer_id=. ?threshold= sets the name similarity (0-1).
+func (s *ApiServer) handleGetDuplicateCustomers(w http.ResponseWriter, r *http.Request) {
+	customerID, err := intParam(r, "customer_id", 0, 1, 1<<31-1)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	limit, err := intParam(r, "limit", 50, 1, 500)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	threshold := 0.6
+	if raw := r.URL.Query().Get("threshold"); raw != "" {
+		threshold, err = strconv.ParseFloat(raw, 64)
+		if err != nil || threshold <= 0 || threshold > 1 {
+			writeBadRequest(w, "threshold must be a number greater than 0 and at most 1")
+			return
+		}
+	}
+
+	candidates, err := s.Store.FindDuplicateCustomers(customerID, threshold, limit)
+	if err != nil {
+		writeStoreError(w, err, "Error finding duplicate customers")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(candidates)
+}
+
+// handleMergeCustomer folds source_id into the customer in the URL
+func (s *ApiServer) handleMergeCustomer(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := s.customerFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var payload models.CustomerMergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, payload) {
+		return
+	}
+
+	before, _ := s.Store.GetCustomerByID(strconv.Itoa(targetID))
+	source, _ := s.Store.GetCustomerByID(strconv.Itoa(payload.SourceID))
+
+	merge, err := s.Store.MergeCustomers(targetID, payload.SourceID, actorFromRequest(r))
+	if err != nil {
+		writeStoreError(w, err, "Error merging customers")
+		return
+	}
+
+	after, _ := s.Store.GetCustomerByID(strconv.Itoa(targetID))
+	s.audit(r, "merge", "customer", targetID, before, after)
+	s.audit(r, "delete", "customer", payload.SourceID, source, nil)
+	if after != nil {
+		s.publishEvent(webhooks.CustomerUpdated, after)
+	}
+	s.publishEvent(webhooks.CustomerDeleted, map[string]int{"id": payload.SourceID, "merged_into": targetID})
+
+	json.NewEncoder(w).Encode(merge)
+}
+
+func (s *ApiServer) handleGetCustomerMerges(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := s.customerFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	merges, err := s.Store.GetCustomerMerges(customerID)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching merge history")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(merges)
+}
@@ -0,0 +1,380 @@
+package api
+
+import (
+	"AAHAOMS/OMS/carriers"
+	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/pdf"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/xuri/excelize/v2"
+)
+
+// customsDocument gathers a shipment's customs details, writing an error
+// when the shipment is missing or an item has no product to declare it by
+func (s *ApiServer) customsDocument(w http.ResponseWriter, r *http.Request) (models.CustomsDocument, bool) {
+	var doc models.CustomsDocument
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "pdf" && format != "xlsx" {
+		writeBadRequest(w, "format must be pdf or xlsx")
+		return doc, false
+	}
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid shipment ID, must be an integer")
+		return doc, false
+	}
+	shipment, err := s.Store.GetShipmentByID(shipmentID)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching shipment")
+		return doc, false
+	}
+	order, err := s.shipTo(shipment)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching order details")
+		return doc, false
+	}
+	lines, err := s.Store.GetCustomsLines(shipmentID)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching shipment items")
+		return doc, false
+	}
+
+	doc = models.CustomsDocument{
+		InvoiceNumber: fmt.Sprintf("CI-%06d", shipment.ID),
+		Date:          dateOnly(shipment.ShippedDate),
+		Currency:      s.Currency,
+		Exporter: models.CustomsParty{
+			Name:    shipFrom.Name,
+			Address: shipFrom.Line1 + ", " + shipFrom.City,
+			Country: shipFrom.Country,
+			Phone:   shipFrom.Phone,
+			Email:   shipFrom.Email,
+		},
+		Consignee: models.CustomsParty{Name: order.CustomerName, Address: order.ShipmentAddress},
+		Shipment:  *shipment,
+		Lines:     lines,
+	}
+	if a := order.ShippingAddress; a != nil {
+		doc.Consignee.Address, doc.Consignee.Country = a.String(), a.Country
+	}
+	if customer, err := s.Store.GetCustomerByID(strconv.Itoa(order.CustomerID)); err == nil {
+		doc.Consignee.Phone, doc.Consignee.Email = customer.Number, customer.Email
+		if doc.Consignee.Country == "" {
+			doc.Consignee.Country = customer.Country
+		}
+	}
+
+	if errs := doc.Missing(); len(errs) > 0 {
+		writeError(w, http.StatusUnprocessableEntity, codeValidation, "Shipment items are missing customs details; add products for them", errs)
+		return doc, false
+	}
+	return doc, true
+}
+
+// handleCommercialInvoice prints a shipment's commercial invoice as
+// ?format=pdf (the default) or xlsx
+func (s *ApiServer) handleCommercialInvoice(w http.ResponseWriter, r *http.Request) {
+	doc, ok := s.customsDocument(w, r)
+	if !ok {
+		return
+	}
+	if r.URL.Query().Get("format") == "xlsx" {
+		writeCustomsExcel(w, doc, "Commercial Invoice", "invoice", invoiceExcelTable)
+		return
+	}
+	writeCustomsPDF(w, doc, "COMMERCIAL INVOICE", "invoice", invoicePDFTable)
+}
+
+// handlePackingList prints a shipment's packing list as ?format=pdf (the
+// default) or xlsx
+func (s *ApiServer) handlePackingList(w http.ResponseWriter, r *http.Request) {
+	doc, ok := s.customsDocument(w, r)
+	if !ok {
+		return
+	}
+	if r.URL.Query().Get("format") == "xlsx" {
+		writeCustomsExcel(w, doc, "Packing List", "packing-list", packingListExcelTable)
+		return
+	}
+	writeCustomsPDF(w, doc, "PACKING LIST", "packing-list", packingListPDFTable)
+}
+
+func customsDescription(l models.CustomsLine) string {
+	return l.Description + " - " + describeItem(l.Name, stringOrEmpty(l.Size), stringOrEmpty(l.Color))
+}
+
+// customsReferences are the shipment details printed under the parties
+func customsReferences(doc models.CustomsDocument) [][2]string {
+	refs := [][2]string{
+		{"Order", fmt.Sprintf("#%d", doc.Shipment.OrderID)},
+		{"Shipment", fmt.Sprintf("#%d", doc.Shipment.ID)},
+	}
+	if doc.Shipment.Carrier != "" {
+		carrier := carriers.Name(doc.Shipment.Carrier)
+		if doc.Shipment.ServiceLevel != "" {
+			carrier += " (" + doc.Shipment.ServiceLevel + ")"
+		}
+		refs = append(refs, [2]string{"Carrier", carrier})
+	}
+	if numbers := doc.Shipment.TrackingNumbers(); len(numbers) > 0 {
+		refs = append(refs, [2]string{"Tracking", strings.Join(numbers, ", ")})
+	}
+	return append(refs, [2]string{"Currency", doc.Currency})
+}
+
+func partyLines(p models.CustomsParty) []string {
+	lines := []string{p.Name, p.Address}
+	if p.Country != "" {
+		lines = append(lines, "Country: "+p.Country)
+	}
+	if p.Phone != "" {
+		lines = append(lines, "Phone: "+p.Phone)
+	}
+	if p.Email != "" {
+		lines = append(lines, p.Email)
+	}
+	return lines
+}
+
+func weight(kg float64) string {
+	return strconv.FormatFloat(kg, 'f', 3, 64)
+}
+
+func amount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func writeCustomsPDF(w http.ResponseWriter, doc models.CustomsDocument, title, file string, table func(*pdf.Document, float64, models.CustomsDocument) float64) {
+	d := pdf.New(title + " " + doc.InvoiceNumber)
+
+	y := pdf.Margin + 16
+	d.Text(pdf.Margin, y, pdf.HelveticaBold, 16, title)
+	d.TextRight(pdf.PageWidth-pdf.Margin, y-4, pdf.Helvetica, 10, "No. "+doc.InvoiceNumber)
+	d.TextRight(pdf.PageWidth-pdf.Margin, y+8, pdf.Helvetica, 10, "Date: "+doc.Date)
+	y += 28
+
+	for i, party := range []struct {
+		label string
+		p     models.CustomsParty
+	}{{"Exporter", doc.Exporter}, {"Consignee", doc.Consignee}} {
+		x := pdf.Margin + float64(i)*260
+		d.Text(x, y, pdf.HelveticaBold, 10, party.label)
+		for j, line := range partyLines(party.p) {
+			d.Text(x, y+13*float64(j+1), pdf.Helvetica, 9, line)
+		}
+	}
+	y += 13*6 + 8
+
+	for _, ref := range customsReferences(doc) {
+		d.Text(pdf.Margin, y, pdf.HelveticaBold, 9, ref[0]+":")
+		d.Text(pdf.Margin+60, y, pdf.Helvetica, 9, ref[1])
+		y += 12
+	}
+	y += 10
+
+	y = table(d, y, doc)
+
+	y = d.EnsureSpace(y+30, 60)
+	d.Text(pdf.Margin, y, pdf.Helvetica, 9, "I declare that the information in this document is true and correct.")
+	y += 36
+	d.Line(pdf.Margin, y, pdf.Margin+180, y)
+	d.Line(pdf.Margin+260, y, pdf.Margin+400, y)
+	d.Text(pdf.Margin, y+11, pdf.Helvetica, 8, "Signature")
+	d.Text(pdf.Margin+260, y+11, pdf.Helvetica, 8, "Date")
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%d.pdf", file, doc.Shipment.ID))
+	d.WriteTo(w)
+}
+
+// customsTotal is a figure printed under a document's table
+type customsTotal struct {
+	Label    string
+	Value    float64
+	Decimals int
+}
+
+// pdfTotals prints totals right aligned under a table
+func pdfTotals(d *pdf.Document, y float64, totals []customsTotal) float64 {
+	right := pdf.PageWidth - pdf.Margin
+	for _, t := range totals {
+		y = d.EnsureSpace(y, 14)
+		d.TextRight(right-90, y+9, pdf.HelveticaBold, 9, t.Label)
+		d.TextRight(right, y+9, pdf.Helvetica, 9, strconv.FormatFloat(t.Value, 'f', t.Decimals, 64))
+		y += 14
+	}
+	return y
+}
+
+func weightTotals(doc models.CustomsDocument) []customsTotal {
+	return []customsTotal{
+		{"Packages", float64(len(doc.Shipment.Packages)), 0},
+		{"Net weight (kg)", doc.NetWeightKg(), 3},
+		{"Gross weight (kg)", doc.GrossWeightKg(), 3},
+	}
+}
+
+func invoiceTotals(doc models.CustomsDocument) []customsTotal {
+	totals := []customsTotal{{"Total value", doc.TotalValue(), 2}}
+	if doc.Shipment.ShippingCost > 0 {
+		totals = append(totals,
+			customsTotal{"Shipping", doc.Shipment.ShippingCost, 2},
+			customsTotal{"Invoice total", doc.TotalValue() + doc.Shipment.ShippingCost, 2})
+	}
+	return append(totals, weightTotals(doc)...)
+}
+
+func invoicePDFTable(d *pdf.Document, y float64, doc models.CustomsDocument) float64 {
+	rows := make([][]string, len(doc.Lines))
+	for i, l := range doc.Lines {
+		rows[i] = []string{strconv.Itoa(i + 1), customsDescription(l), l.HSCode, l.OriginCountry, strconv.Itoa(l.Quantity),
+			amount(l.UnitValue), amount(l.Value()), weight(l.TotalNetWeight())}
+	}
+	y = d.Table(y, []pdf.Column{
+		{Title: "No.", Width: 25},
+		{Title: "Description", Width: 185},
+		{Title: "HS code", Width: 55},
+		{Title: "Origin", Width: 35},
+		{Title: "Qty", Width: 35, Right: true},
+		{Title: "Unit value", Width: 60, Right: true},
+		{Title: "Total", Width: 65, Right: true},
+		{Title: "Net kg", Width: 55, Right: true},
+	}, rows)
+	return pdfTotals(d, y+6, invoiceTotals(doc))
+}
+
+func packingListPDFTable(d *pdf.Document, y float64, doc models.CustomsDocument) float64 {
+	if len(doc.Shipment.Packages) > 0 {
+		rows := make([][]string, len(doc.Shipment.Packages))
+		for i, p := range doc.Shipment.Packages {
+			rows[i] = []string{strconv.Itoa(i + 1), fmt.Sprintf("%g x %g x %g", p.LengthCm, p.WidthCm, p.HeightCm), weight(p.WeightKg), p.TrackingNumber}
+		}
+		y = d.Table(y, []pdf.Column{
+			{Title: "Package", Width: 55},
+			{Title: "Dimensions (cm)", Width: 130},
+			{Title: "Gross kg", Width: 70, Right: true},
+			{Title: "Tracking", Width: 180},
+		}, rows)
+		y += 14
+	}
+
+	rows := make([][]string, len(doc.Lines))
+	for i, l := range doc.Lines {
+		rows[i] = []string{strconv.Itoa(i + 1), customsDescription(l), l.HSCode, strconv.Itoa(l.Quantity),
+			weight(l.TotalNetWeight()), weight(l.TotalGrossWeight())}
+	}
+	y = d.Table(y, []pdf.Column{
+		{Title: "No.", Width: 25},
+		{Title: "Description", Width: 250},
+		{Title: "HS code", Width: 65},
+		{Title: "Qty", Width: 45, Right: true},
+		{Title: "Net kg", Width: 65, Right: true},
+		{Title: "Gross kg", Width: 65, Right: true},
+	}, rows)
+	return pdfTotals(d, y+6, weightTotals(doc))
+}
+
+func writeCustomsExcel(w http.ResponseWriter, doc models.CustomsDocument, title, file string, table func(*excelize.File, string, int, models.CustomsDocument) int) {
+	f := excelize.NewFile()
+	sheet := title
+	f.SetSheetName(f.GetSheetName(0), sheet)
+	bold, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	heading, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}})
+
+	f.SetCellValue(sheet, "A1", strings.ToUpper(title))
+	f.SetCellStyle(sheet, "A1", "A1", heading)
+	f.SetCellValue(sheet, "F1", "No.")
+	f.SetCellValue(sheet, "G1", doc.InvoiceNumber)
+	f.SetCellValue(sheet, "F2", "Date:")
+	f.SetCellValue(sheet, "G2", doc.Date)
+
+	f.SetCellValue(sheet, "A4", "Exporter")
+	f.SetCellValue(sheet, "E4", "Consignee")
+	f.SetCellStyle(sheet, "A4", "E4", bold)
+	for i, line := range partyLines(doc.Exporter) {
+		f.SetCellValue(sheet, fmt.Sprintf("A%d", 5+i), line)
+	}
+	for i, line := range partyLines(doc.Consignee) {
+		f.SetCellValue(sheet, fmt.Sprintf("E%d", 5+i), line)
+	}
+
+	row := 11
+	for _, ref := range customsReferences(doc) {
+		f.SetCellValue(sheet, fmt.Sprintf("A%d", row), ref[0]+":")
+		f.SetCellValue(sheet, fmt.Sprintf("B%d", row), ref[1])
+		row++
+	}
+
+	row = table(f, sheet, row+1, doc)
+
+	f.SetCellValue(sheet, fmt.Sprintf("A%d", row+2), "I declare that the information in this document is true and correct.")
+	f.SetCellValue(sheet, fmt.Sprintf("A%d", row+4), "Signature:")
+	f.SetCellValue(sheet, fmt.Sprintf("E%d", row+4), "Date:")
+	f.SetColWidth(sheet, "B", "B", 45)
+	f.SetColWidth(sheet, "C", "H", 12)
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%d.xlsx", file, doc.Shipment.ID))
+	if err := f.Write(w); err != nil {
+		writeStoreError(w, err, "Error writing excel file")
+	}
+}
+
+// excelRows writes a bold header and rows from row on, returning the row
+// after the last one
+func excelRows(f *excelize.File, sheet string, row int, header []any, rows [][]any) int {
+	bold, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	cell, _ := excelize.CoordinatesToCellName(1, row)
+	end, _ := excelize.CoordinatesToCellName(len(header), row)
+	f.SetSheetRow(sheet, cell, &header)
+	f.SetCellStyle(sheet, cell, end, bold)
+	for _, values := range rows {
+		row++
+		cell, _ = excelize.CoordinatesToCellName(1, row)
+		f.SetSheetRow(sheet, cell, &values)
+	}
+	return row + 1
+}
+
+// excelTotals writes each total in column col with its label to the left
+func excelTotals(f *excelize.File, sheet string, row, col int, totals []customsTotal) int {
+	for _, t := range totals {
+		label, _ := excelize.CoordinatesToCellName(col-1, row)
+		value, _ := excelize.CoordinatesToCellName(col, row)
+		f.SetCellValue(sheet, label, t.Label)
+		f.SetCellValue(sheet, value, t.Value)
+		row++
+	}
+	return row
+}
+
+func invoiceExcelTable(f *excelize.File, sheet string, row int, doc models.CustomsDocument) int {
+	rows := make([][]any, len(doc.Lines))
+	for i, l := range doc.Lines {
+		rows[i] = []any{i + 1, customsDescription(l), l.HSCode, l.OriginCountry, l.Quantity, l.UnitValue, l.Value(), l.TotalNetWeight()}
+	}
+	row = excelRows(f, sheet, row, []any{"No.", "Description", "HS code", "Origin", "Qty", "Unit value", "Total", "Net kg"}, rows)
+	return excelTotals(f, sheet, row+1, 7, invoiceTotals(doc))
+}
+
+func packingListExcelTable(f *excelize.File, sheet string, row int, doc models.CustomsDocument) int {
+	if len(doc.Shipment.Packages) > 0 {
+		rows := make([][]any, len(doc.Shipment.Packages))
+		for i, p := range doc.Shipment.Packages {
+			rows[i] = []any{i + 1, fmt.Sprintf("%g x %g x %g cm", p.LengthCm, p.WidthCm, p.HeightCm), p.WeightKg, p.TrackingNumber}
+		}
+		row = excelRows(f, sheet, row, []any{"Package", "Dimensions", "Gross kg", "Tracking"}, rows) + 1
+	}
+
+	rows := make([][]any, len(doc.Lines))
+	for i, l := range doc.Lines {
+		rows[i] = []any{i + 1, customsDescription(l), l.HSCode, l.Quantity, l.TotalNetWeight(), l.TotalGrossWeight()}
+	}
+	row = excelRows(f, sheet, row, []any{"No.", "Description", "HS code", "Qty", "Net kg", "Gross kg"}, rows)
+	return excelTotals(f, sheet, row+1, 6, weightTotals(doc))
+}
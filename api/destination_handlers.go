@@ -0,0 +1,95 @@
+package api
+
+import (
+	"AAHAOMS/OMS/models"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *ApiServer) handleGetOrderDestinations(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid order ID")
+		return
+	}
+	destinations, err := s.Store.GetOrderDestinations(orderID)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching destinations")
+		return
+	}
+	json.NewEncoder(w).Encode(destinations)
+}
+
+// handleSetOrderDestinations splits an order that hasn't shipped yet across
+// the given destinations, replacing any it had
+func (s *ApiServer) handleSetOrderDestinations(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid order ID")
+		return
+	}
+	var req models.OrderDestinations
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, req) {
+		return
+	}
+
+	before, err := s.Store.GetOrderDestinations(orderID)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching destinations")
+		return
+	}
+	if err := s.Store.SetOrderDestinations(orderID, req); err != nil {
+		writeStoreError(w, err, "Error saving destinations")
+		return
+	}
+	destinations, _ := s.Store.GetOrderDestinations(orderID)
+	s.audit(r, "update", "order_destinations", orderID, before, destinations)
+
+	json.NewEncoder(w).Encode(destinations)
+}
+
+func (s *ApiServer) handleDeleteOrderDestinations(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid order ID")
+		return
+	}
+	before, err := s.Store.GetOrderDestinations(orderID)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching destinations")
+		return
+	}
+	if err := s.Store.DeleteOrderDestinations(orderID); err != nil {
+		writeStoreError(w, err, "Error deleting destinations")
+		return
+	}
+	s.audit(r, "delete", "order_destinations", orderID, before, nil)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Destinations deleted successfully"})
+}
+
+// shipTo returns the order a shipment belongs to, with the address of the
+// shipment's destination in place of the order's own for split orders
+func (s *ApiServer) shipTo(shipment *models.Shipment) (models.Order, error) {
+	order, err := s.Store.GetOrderByID(shipment.OrderID)
+	if err != nil || shipment.DestinationID == nil {
+		return order, err
+	}
+	destinations, err := s.Store.GetOrderDestinations(order.ID)
+	if err != nil {
+		return order, err
+	}
+	for _, d := range destinations {
+		if d.ID == *shipment.DestinationID {
+			order.ShipmentAddress, order.ShippingAddressID, order.ShippingAddress = d.ShipmentAddress, d.ShippingAddressID, d.ShippingAddress
+			break
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"AAHAOMS/OMS/carriers"
+	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/storage"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Error codes returned in the "code" field of error responses
+const (
+	codeBadRequest     = "bad_request"
+	codeInvalidPayload = "invalid_payload"
+	codeUnauthorized   = "unauthorized"
+	codeNotFound       = "not_found"
+	codeConflict       = "conflict"
+	codeValidation     = "validation_failed"
+	codeInternal       = "internal_error"
+	codeCarrier        = "carrier_error"
+)
+
+type errorResponse struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+// writeError is the single place error responses are written. Every error
+// has the shape {"error": {"code", "message", "details"}}.
+func writeError(w http.ResponseWriter, status int, code, message string, details any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Del("Content-Disposition")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: errorDetail{Code: code, Message: message, Details: details}})
+}
+
+// writeStoreError maps an error from storage to a status code using its
+// sentinel, prefixing the message with what the handler was doing.
+func writeStoreError(w http.ResponseWriter, err error, action string) {
+	status, code := http.StatusInternalServerError, codeInternal
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		status, code = http.StatusNotFound, codeNotFound
+	case errors.Is(err, storage.ErrConflict):
+		status, code = http.StatusConflict, codeConflict
+	case errors.Is(err, storage.ErrValidation):
+		status, code = http.StatusUnprocessableEntity, codeValidation
+	}
+	writeError(w, status, code, action+": "+err.Error(), nil)
+}
+
+// writeHandlerError reports an error returned by an apiFunc. Storage
+// sentinels map as in writeStoreError; anything else stays a 400 as it
+// always has been.
+func writeHandlerError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrConflict) || errors.Is(err, storage.ErrValidation) {
+		writeStoreError(w, err, "Request failed")
+		return
+	}
+	writeError(w, http.StatusBadRequest, codeBadRequest, err.Error(), nil)
+}
+
+// writeCarrierError reports a failed carrier call. Requests the carrier
+// rejects are the client's to fix; anything else is a bad gateway.
+func writeCarrierError(w http.ResponseWriter, err error, action string) {
+	status, code := http.StatusBadGateway, codeCarrier
+	switch {
+	case errors.Is(err, carriers.ErrUnknownCarrier), errors.Is(err, carriers.ErrInvalidRequest):
+		status, code = http.StatusUnprocessableEntity, codeValidation
+	case errors.Is(err, carriers.ErrNotFound):
+		status, code = http.StatusNotFound, codeNotFound
+	}
+	writeError(w, status, code, action+": "+err.Error(), nil)
+}
+
+func writeBadRequest(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusBadRequest, codeBadRequest, message, nil)
+}
+
+func writeInvalidPayload(w http.ResponseWriter) {
+	writeError(w, http.StatusBadRequest, codeInvalidPayload, "Invalid request payload", nil)
+}
+
+// writeValidationErrors responds 422 with one entry per invalid field
+func writeValidationErrors(w http.ResponseWriter, errs models.ValidationErrors) {
+	writeError(w, http.StatusUnprocessableEntity, codeValidation, "Request validation failed", errs)
+}
+
+// validateRequest checks v against its model rules and writes the field
+// errors if there are any. It reports whether the handler may go on.
+func validateRequest(w http.ResponseWriter, v any) bool {
+	if errs := models.Validate(v); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return false
+	}
+	return true
+}
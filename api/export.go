@@ -0,0 +1,166 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Column kinds decide how a value is written: CSV gets plain text, while
+// workbooks get real number and date cells with a matching format
+const (
+	colText = iota
+	colInt
+	colMoney
+	colDate
+)
+
+type exportColumn struct {
+	Name  string
+	Kind  int
+	Width float64
+}
+
+// tableWriter writes an export one row at a time
+type tableWriter interface {
+	WriteRow(values ...any) error
+	Close() error
+}
+
+// newTableWriter sets the download headers and writes the header row.
+// format is "csv" (the default) or "xlsx"; name is the file name without
+// an extension.
+func newTableWriter(w http.ResponseWriter, format, name string, columns []exportColumn) (tableWriter, error) {
+	switch format {
+	case "", "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", name))
+		cw := csv.NewWriter(w)
+		header := make([]string, len(columns))
+		for i, c := range columns {
+			header[i] = c.Name
+		}
+		return &csvTable{w: cw, columns: columns}, cw.Write(header)
+
+	case "xlsx":
+		return newXLSXTable(w, name, columns)
+	}
+	return nil, fmt.Errorf("format must be csv or xlsx")
+}
+
+type csvTable struct {
+	w       *csv.Writer
+	columns []exportColumn
+	record  []string
+}
+
+func (t *csvTable) WriteRow(values ...any) error {
+	t.record = t.record[:0]
+	for i, v := range values {
+		switch v := v.(type) {
+		case string:
+			t.record = append(t.record, v)
+		case int:
+			t.record = append(t.record, strconv.Itoa(v))
+		case float64:
+			if t.columns[i].Kind == colMoney {
+				t.record = append(t.record, strconv.FormatFloat(v, 'f', 2, 64))
+			} else {
+				t.record = append(t.record, strconv.FormatFloat(v, 'f', -1, 64))
+			}
+		case bool:
+			t.record = append(t.record, strconv.FormatBool(v))
+		default:
+			t.record = append(t.record, fmt.Sprint(v))
+		}
+	}
+	return t.w.Write(t.record)
+}
+
+func (t *csvTable) Close() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+// xlsxTable uses excelize's stream writer, which spills rows to a temporary
+// file instead of keeping the whole sheet in memory
+type xlsxTable struct {
+	http    http.ResponseWriter
+	file    *excelize.File
+	sw      *excelize.StreamWriter
+	styles  []int
+	columns []exportColumn
+	row     int
+	cells   []any
+}
+
+const exportSheet = "Sheet1"
+
+func newXLSXTable(w http.ResponseWriter, name string, columns []exportColumn) (*xlsxTable, error) {
+	f := excelize.NewFile()
+	sw, err := f.NewStreamWriter(exportSheet)
+	if err != nil {
+		return nil, err
+	}
+
+	headerStyle, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	dateFormat, moneyFormat := "yyyy-mm-dd", "#,##0.00"
+	dateStyle, _ := f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+	moneyStyle, _ := f.NewStyle(&excelize.Style{CustomNumFmt: &moneyFormat})
+
+	t := &xlsxTable{http: w, file: f, sw: sw, columns: columns, row: 1}
+	header := make([]any, len(columns))
+	for i, c := range columns {
+		header[i] = excelize.Cell{StyleID: headerStyle, Value: c.Name}
+		width := c.Width
+		if width == 0 {
+			width = 14
+		}
+		if err := sw.SetColWidth(i+1, i+1, width); err != nil {
+			return nil, err
+		}
+		switch c.Kind {
+		case colDate:
+			t.styles = append(t.styles, dateStyle)
+		case colMoney:
+			t.styles = append(t.styles, moneyStyle)
+		default:
+			t.styles = append(t.styles, 0)
+		}
+	}
+	if err := sw.SetRow("A1", header, excelize.RowOpts{StyleID: headerStyle}); err != nil {
+		return nil, err
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.xlsx", name))
+	return t, nil
+}
+
+func (t *xlsxTable) WriteRow(values ...any) error {
+	t.row++
+	t.cells = t.cells[:0]
+	for i, v := range values {
+		// Dates arrive as YYYY-MM-DD strings; empty ones stay blank
+		if s, ok := v.(string); ok && t.columns[i].Kind == colDate && s != "" {
+			if d, err := time.Parse("2006-01-02", s); err == nil {
+				v = d
+			}
+		}
+		t.cells = append(t.cells, excelize.Cell{StyleID: t.styles[i], Value: v})
+	}
+	cell, _ := excelize.CoordinatesToCellName(1, t.row)
+	return t.sw.SetRow(cell, t.cells)
+}
+
+func (t *xlsxTable) Close() error {
+	defer t.file.Close()
+	if err := t.sw.Flush(); err != nil {
+		return err
+	}
+	return t.file.Write(t.http)
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"AAHAOMS/OMS/models"
+	"log"
+	"net/http"
+)
+
+var orderExportColumns = []exportColumn{
+	{Name: "order_id", Kind: colInt, Width: 10},
+	{Name: "external_ref", Width: 16},
+	{Name: "customer_id", Kind: colInt, Width: 12},
+	{Name: "customer_name", Width: 28},
+	{Name: "order_date", Kind: colDate, Width: 12},
+	{Name: "shipment_due", Kind: colDate, Width: 12},
+	{Name: "order_status", Width: 16},
+	{Name: "shipment_address", Width: 40},
+	{Name: "item_id", Kind: colInt, Width: 10},
+	{Name: "item_name", Width: 28},
+	{Name: "size", Width: 10},
+	{Name: "color", Width: 12},
+	{Name: "price", Kind: colMoney, Width: 12},
+	{Name: "quantity", Kind: colInt, Width: 10},
+	{Name: "line_total", Kind: colMoney, Width: 12},
+}
+
+var shipmentExportColumns = []exportColumn{
+	{Name: "shipment_id", Kind: colInt, Width: 12},
+	{Name: "shipped_date", Kind: colDate, Width: 12},
+	{Name: "due_order_type", Width: 14},
+	{Name: "carrier", Width: 14},
+	{Name: "service_level", Width: 14},
+	{Name: "tracking_numbers", Width: 24},
+	{Name: "order_id", Kind: colInt, Width: 10},
+	{Name: "customer_id", Kind: colInt, Width: 12},
+	{Name: "customer_name", Width: 28},
+	{Name: "order_status", Width: 16},
+	{Name: "item_id", Kind: colInt, Width: 10},
+	{Name: "item_name", Width: 28},
+	{Name: "size", Width: 10},
+	{Name: "color", Width: 12},
+	{Name: "price", Kind: colMoney, Width: 12},
+	{Name: "quantity", Kind: colInt, Width: 10},
+	{Name: "line_total", Kind: colMoney, Width: 12},
+}
+
+var customerExportColumns = []exportColumn{
+	{Name: "id", Kind: colInt, Width: 8},
+	{Name: "name", Width: 28},
+	{Name: "number", Width: 18},
+	{Name: "email", Width: 28},
+	{Name: "country", Width: 10},
+	{Name: "address", Width: 40},
+	{Name: "notifications_opt_out", Width: 12},
+}
+
+// handleExportOrders streams one row per order line, filtered like GET /orders
+func (s *ApiServer) handleExportOrders(w http.ResponseWriter, r *http.Request) {
+	filter, err := orderFilterFromQuery(r)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	t, err := newTableWriter(w, r.URL.Query().Get("format"), "orders", orderExportColumns)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	err = s.Store.StreamOrderLines(filter, func(l models.OrderLine) error {
+		return t.WriteRow(l.OrderID, l.ExternalRef, l.CustomerID, l.CustomerName, l.OrderDate, l.ShipmentDue,
+			l.OrderStatus, l.ShipmentAddress, l.ItemID, l.ItemName, l.Size, l.Color, l.Price, l.Quantity,
+			l.Price*float64(l.Quantity))
+	})
+	finishExport(t, "Order", err)
+}
+
+// handleExportShipments streams one row per shipped line, filtered like GET /shipments
+func (s *ApiServer) handleExportShipments(w http.ResponseWriter, r *http.Request) {
+	filter, err := shipmentFilterFromQuery(r)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	t, err := newTableWriter(w, r.URL.Query().Get("format"), "shipments", shipmentExportColumns)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	err = s.Store.StreamShipmentLines(filter, func(l models.ShipmentLine) error {
+		return t.WriteRow(l.ShipmentID, l.ShippedDate, l.DueOrderType, l.Carrier, l.ServiceLevel, l.TrackingNumbers, l.OrderID, l.CustomerID, l.CustomerName,
+			l.OrderStatus, l.ItemID, l.ItemName, l.Size, l.Color, l.Price, l.Quantity, l.Price*float64(l.Quantity))
+	})
+	finishExport(t, "Shipment", err)
+}
+
+// handleExportCustomers streams customers, filtered by ?q= like GET /customers
+func (s *ApiServer) handleExportCustomers(w http.ResponseWriter, r *http.Request) {
+	t, err := newTableWriter(w, r.URL.Query().Get("format"), "customers", customerExportColumns)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	err = s.Store.StreamCustomers(r.URL.Query().Get("q"), func(c models.Customer) error {
+		return t.WriteRow(c.ID, c.Name, c.Number, c.Email, c.Country, c.Address, c.NotificationsOptOut)
+	})
+	finishExport(t, "Customer", err)
+}
+
+// finishExport closes the table. Headers are already sent by now, so a
+// failure part way through can only be logged.
+func finishExport(t tableWriter, kind string, err error) {
+	if closeErr := t.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("%s export aborted: %v", kind, err)
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"AAHAOMS/OMS/models"
+	"fmt"
+	"net/http"
+)
+
+// dateParam reads an optional YYYY-MM-DD query parameter
+func dateParam(r *http.Request, name string) (string, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return "", nil
+	}
+	if _, err := models.ParseDate(raw); err != nil {
+		return "", fmt.Errorf("%s must be a date in YYYY-MM-DD format", name)
+	}
+	return raw, nil
+}
+
+// orderFilterFromQuery reads ?from=&to=&status=&customer_id= as used by
+// both the order listing and its export
+func orderFilterFromQuery(r *http.Request) (models.OrderFilter, error) {
+	var f models.OrderFilter
+	var err error
+	if f.From, err = dateParam(r, "from"); err != nil {
+		return f, err
+	}
+	if f.To, err = dateParam(r, "to"); err != nil {
+		return f, err
+	}
+	if f.CustomerID, err = intParam(r, "customer_id", 0, 1, 1<<31-1); err != nil {
+		return f, err
+	}
+	f.Status = r.URL.Query().Get("status")
+	return f, nil
+}
+
+// shipmentFilterFromQuery reads ?from=&to=&status=&customer_id=&order_id=
+func shipmentFilterFromQuery(r *http.Request) (models.ShipmentFilter, error) {
+	var f models.ShipmentFilter
+	var err error
+	if f.From, err = dateParam(r, "from"); err != nil {
+		return f, err
+	}
+	if f.To, err = dateParam(r, "to"); err != nil {
+		return f, err
+	}
+	if f.CustomerID, err = intParam(r, "customer_id", 0, 1, 1<<31-1); err != nil {
+		return f, err
+	}
+	if f.OrderID, err = intParam(r, "order_id", 0, 1, 1<<31-1); err != nil {
+		return f, err
+	}
+	f.Status = r.URL.Query().Get("status")
+	return f, nil
+}
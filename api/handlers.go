@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -18,6 +19,15 @@ func init() {
 
 type apiFunc func(w http.ResponseWriter, r *http.Request) error
 
+type contextKey int
+
+const actorKey contextKey = iota
+
+// withActor records who the request's credential belongs to, for the audit log
+func withActor(r *http.Request, actor string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), actorKey, actor))
+}
+
 func wrapHandler(fn func(w http.ResponseWriter, r *http.Request)) apiFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
 		fn(w, r)
@@ -40,39 +50,42 @@ func makeHandler(fn apiFunc) http.HandlerFunc {
 
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, `{"error": "Missing Authorization header"}`, http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "Missing Authorization header", nil)
 			return
 		}
 
 		if !strings.HasPrefix(authHeader, "Bearer ") {
-			http.Error(w, `{"error": "Invalid Authorization header format"}`, http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "Invalid Authorization header format", nil)
 			return
 		}
 
 		tokenString := strings.TrimSpace(authHeader[len("Bearer "):])
 		if err := verifyToken(tokenString); err != nil {
-			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, err.Error(), nil)
 			return
 		}
+		// The shared token carries no identity of its own
+		r = withActor(r, "api-token")
 
 		if err := fn(w, r); err != nil {
-			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
+			writeHandlerError(w, err)
 		}
 	}
 }
 
-func (s *ApiServer) verifyJWTToken(tokenString string) error {
+// verifyJWTToken checks a login key and returns the email it was issued to
+func (s *ApiServer) verifyJWTToken(tokenString string) (string, error) {
 
-	exists, err := s.Store.IsKeyInStorage(tokenString)
+	email, err := s.Store.GetKeyEmail(tokenString)
 	if err != nil {
-		return fmt.Errorf("error checking key in storage: %s", err.Error())
+		return "", fmt.Errorf("error checking key in storage: %s", err.Error())
 	}
 
-	if !exists {
-		return fmt.Errorf("invalid or expired key")
+	if email == "" {
+		return "", fmt.Errorf("invalid or expired key")
 	}
 
-	return nil
+	return email, nil
 }
 
 func makeJWTHandler(fn apiFunc, s *ApiServer) http.HandlerFunc {
@@ -81,23 +94,25 @@ func makeJWTHandler(fn apiFunc, s *ApiServer) http.HandlerFunc {
 
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, `{"error": "Missing Authorization header"}`, http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "Missing Authorization header", nil)
 			return
 		}
 
 		if !strings.HasPrefix(authHeader, "Bearer ") {
-			http.Error(w, `{"error": "Invalid Authorization header format"}`, http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "Invalid Authorization header format", nil)
 			return
 		}
 
 		tokenString := strings.TrimSpace(authHeader[len("Bearer "):])
-		if err := s.verifyJWTToken(tokenString); err != nil {
-			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusUnauthorized)
+		email, err := s.verifyJWTToken(tokenString)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, err.Error(), nil)
 			return
 		}
+		r = withActor(r, email)
 
 		if err := fn(w, r); err != nil {
-			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
+			writeHandlerError(w, err)
 		}
 	}
 }
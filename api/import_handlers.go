@@ -0,0 +1,113 @@
+package api
+
+import (
+	"AAHAOMS/OMS/importer"
+	"AAHAOMS/OMS/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const maxImportSize = 20 << 20
+
+type importFunc func(r io.Reader, format string, opts models.ImportOptions) (models.ImportResult, error)
+
+// importOptionsFromQuery reads ?dry_run= and ?on_duplicate=
+func importOptionsFromQuery(r *http.Request) (models.ImportOptions, error) {
+	opts := models.ImportOptions{OnDuplicate: models.DuplicateError}
+	query := r.URL.Query()
+	if raw := query.Get("dry_run"); raw != "" {
+		dryRun, err := strconv.ParseBool(raw)
+		if err != nil {
+			return opts, fmt.Errorf("dry_run must be true or false")
+		}
+		opts.DryRun = dryRun
+	}
+	switch d := query.Get("on_duplicate"); d {
+	case "":
+	case models.DuplicateError, models.DuplicateSkip, models.DuplicateUpdate:
+		opts.OnDuplicate = d
+	default:
+		return opts, fmt.Errorf("on_duplicate must be one of: error, skip, update")
+	}
+	return opts, nil
+}
+
+// readUpload returns the uploaded file and its format. The file is either a
+// multipart "file" field or the raw request body, with the format taken
+// from ?format=, the file name or the Content-Type.
+func readUpload(w http.ResponseWriter, r *http.Request) (io.Reader, string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	format := strings.ToLower(r.URL.Query().Get("format"))
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, "", fmt.Errorf("multipart upload needs a \"file\" field: %v", err)
+		}
+		body = file
+		if format == "" {
+			format = importer.FormatFromName(header.Filename)
+		}
+	}
+
+	if format == "" {
+		switch ct := r.Header.Get("Content-Type"); {
+		case strings.Contains(ct, "spreadsheetml"):
+			format = importer.FormatXLSX
+		case strings.Contains(ct, "csv"), strings.HasPrefix(ct, "text/plain"):
+			format = importer.FormatCSV
+		default:
+			return nil, "", fmt.Errorf("can't tell the file format, pass ?format=csv or ?format=xlsx")
+		}
+	}
+	return body, format, nil
+}
+
+func (s *ApiServer) handleImportCustomers(w http.ResponseWriter, r *http.Request) {
+	s.runImport(w, r, "customer", func(body io.Reader, format string, opts models.ImportOptions) (models.ImportResult, error) {
+		return importer.Customers(s.Store, body, format, opts)
+	})
+}
+
+func (s *ApiServer) handleImportOrders(w http.ResponseWriter, r *http.Request) {
+	s.runImport(w, r, "order", func(body io.Reader, format string, opts models.ImportOptions) (models.ImportResult, error) {
+		return importer.Orders(s.Store, body, format, opts)
+	})
+}
+
+// runImport responds with the import result: 200 when it committed or was a
+// clean dry run, 422 with the row errors when nothing was saved.
+func (s *ApiServer) runImport(w http.ResponseWriter, r *http.Request, entityType string, run importFunc) {
+	opts, err := importOptionsFromQuery(r)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	body, format, err := readUpload(w, r)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	result, err := run(body, format, opts)
+	if err != nil {
+		writeStoreError(w, err, "Import failed")
+		return
+	}
+	if len(result.Errors) > 0 {
+		writeError(w, http.StatusUnprocessableEntity, codeValidation, "Import has errors, nothing was saved", result)
+		return
+	}
+
+	if result.Committed {
+		s.audit(r, "import", entityType, "bulk", nil, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
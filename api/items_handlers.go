@@ -2,7 +2,6 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
 
@@ -13,18 +12,13 @@ func (s *ApiServer) handleGetItemByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	itemID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		writeBadRequest(w, "Invalid item ID")
 		return
 	}
 
 	item, err := s.Store.GetItemByID(itemID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching item: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	if item.ID == 0 {
-		http.Error(w, "Item not found", http.StatusNotFound)
+		writeStoreError(w, err, "Error fetching item")
 		return
 	}
 
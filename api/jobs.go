@@ -0,0 +1,191 @@
+package api
+
+import (
+	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/storage"
+	"AAHAOMS/OMS/webhooks"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// registerJobs wires every background job kind to its handler. The order
+// and shipment jobs come from the outbox and fan out into separately
+// retried email jobs, so a flaky mail server never re-fires webhooks.
+func (s *ApiServer) registerJobs() {
+	s.Jobs.Register(models.JobOrderCreated, s.runOrderCreatedJob)
+	s.Jobs.Register(models.JobShipmentCreated, s.runShipmentCreatedJob)
+
+	s.Jobs.Register(models.JobEmailOrderCreated, func(ctx context.Context, job models.Job) error {
+		var p struct {
+			OrderID int `json:"order_id"`
+		}
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			return err
+		}
+		return s.Notifier.OrderCreated(p.OrderID)
+	})
+
+	s.Jobs.Register(models.JobEmailShipmentDispatched, func(ctx context.Context, job models.Job) error {
+		var p models.ShipmentJobPayload
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			return err
+		}
+
+		// Prefer the stored shipment for its carrier and tracking details,
+		// falling back to the payload if it has since been deleted
+		shipment := models.Shipment{ID: p.ShipmentID, OrderID: p.OrderID, Items: p.Items, ShippedDate: p.ShippedDate}
+		if stored, err := s.Store.GetShipmentByID(p.ShipmentID); err == nil {
+			shipment = *stored
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		return s.Notifier.ShipmentDispatched(shipment)
+	})
+
+	s.Jobs.Register(models.JobEmailOrderShipped, func(ctx context.Context, job models.Job) error {
+		var p struct {
+			OrderID int `json:"order_id"`
+		}
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			return err
+		}
+		return s.Notifier.OrderShipped(p.OrderID)
+	})
+
+	s.Jobs.Register(models.JobOverdueDigest, func(ctx context.Context, job models.Job) error {
+		var p models.OverdueDigestPayload
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			return err
+		}
+		report, err := s.Store.GetBacklog(models.BacklogFilter{AsOf: p.Date, OverdueOnly: true})
+		if err != nil {
+			return err
+		}
+		return s.Notifier.OverdueDigest(s.DigestRecipients, report)
+	})
+}
+
+func (s *ApiServer) runOrderCreatedJob(ctx context.Context, job models.Job) error {
+	var p struct {
+		OrderID int `json:"order_id"`
+	}
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return err
+	}
+
+	order, err := s.Store.GetOrderByID(p.OrderID)
+	if err != nil {
+		return fmt.Errorf("failed to load order %d: %v", p.OrderID, err)
+	}
+
+	if err := s.publishEventWithID(jobEventID(job), webhooks.OrderCreated, order); err != nil {
+		return err
+	}
+
+	dedupe := fmt.Sprintf("%s:%d", models.JobEmailOrderCreated, p.OrderID)
+	_, err = s.Store.EnqueueJob(models.JobEmailOrderCreated, p, dedupe)
+	return err
+}
+
+func (s *ApiServer) runShipmentCreatedJob(ctx context.Context, job models.Job) error {
+	var p models.ShipmentJobPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return err
+	}
+
+	shipment, err := s.Store.GetShipmentByID(p.ShipmentID)
+	if err != nil {
+		return fmt.Errorf("failed to load shipment %d: %v", p.ShipmentID, err)
+	}
+	if err := s.publishEventWithID(jobEventID(job), webhooks.ShipmentCreated, shipment); err != nil {
+		return err
+	}
+	if p.OrderStatus != "" && p.OrderStatus != p.PreviousOrderStatus {
+		data := map[string]any{"order_id": p.OrderID, "status": p.OrderStatus}
+		if err := s.publishEventWithID(jobEventID(job)+"_order", webhooks.OrderStatusUpdated, data); err != nil {
+			return err
+		}
+	}
+
+	p.ShippedDate = shipment.ShippedDate
+	dedupe := fmt.Sprintf("%s:%d", models.JobEmailShipmentDispatched, p.ShipmentID)
+	if _, err := s.Store.EnqueueJob(models.JobEmailShipmentDispatched, p, dedupe); err != nil {
+		return err
+	}
+
+	order, err := s.Store.GetOrderByID(p.OrderID)
+	if err != nil {
+		return fmt.Errorf("failed to load order %d: %v", p.OrderID, err)
+	}
+	if order.OrderStatus == "shipped" {
+		// Once per order, however many shipments it took or were redone
+		dedupe := fmt.Sprintf("%s:%d", models.JobEmailOrderShipped, p.OrderID)
+		_, err := s.Store.EnqueueJob(models.JobEmailOrderShipped, map[string]int{"order_id": p.OrderID}, dedupe)
+		return err
+	}
+	return nil
+}
+
+// jobEventID derives a webhook event ID from the job that publishes it
+func jobEventID(job models.Job) string {
+	return fmt.Sprintf("evt_job_%d", job.ID)
+}
+
+func (s *ApiServer) handleGetJobs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	limit := 100
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= 1000 {
+		limit = l
+	}
+
+	jobs, err := s.Store.GetJobs(q.Get("status"), q.Get("kind"), limit)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching jobs")
+		return
+	}
+
+	counts, err := s.Store.GetJobCounts()
+	if err != nil {
+		writeStoreError(w, err, "Error counting jobs")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"counts": counts, "jobs": jobs})
+}
+
+func (s *ApiServer) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid job ID")
+		return
+	}
+
+	job, err := s.Store.GetJobByID(id)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching job")
+		return
+	}
+	json.NewEncoder(w).Encode(job)
+}
+
+func (s *ApiServer) handleRetryJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid job ID")
+		return
+	}
+
+	if err := s.Store.RetryJob(id); err != nil {
+		writeStoreError(w, err, "Error retrying job")
+		return
+	}
+	s.audit(r, "update", "job", id, map[string]string{"status": "dead"}, map[string]string{"status": "queued"})
+	json.NewEncoder(w).Encode(map[string]string{"message": "Job queued for retry"})
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"AAHAOMS/OMS/carriers"
+	"AAHAOMS/OMS/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// shipFrom is the address labels are bought from, as printed on invoices
+var shipFrom = carriers.Address{
+	Name:    "AAHA FELT",
+	Line1:   "Bhanyatar, 8 Tokha",
+	City:    "Kathmandu",
+	Country: "NP",
+	Phone:   "015159015",
+	Email:   "aahafelt@gmail.com",
+}
+
+// rateRequest describes a shipment's packages and destination for a
+// carrier; order is the shipment's order as returned by shipTo. Every
+// package needs a weight before it can be quoted.
+func (s *ApiServer) rateRequest(shipment *models.Shipment, order models.Order) (carriers.RateRequest, error) {
+	req := carriers.RateRequest{From: shipFrom}
+	req.To.Name = order.CustomerName
+	if a := order.ShippingAddress; a != nil {
+		req.To.Line1, req.To.Line2, req.To.City = a.Line1, a.Line2, a.City
+		req.To.Region, req.To.Postcode, req.To.Country = a.Region, a.Postcode, a.Country
+	}
+	if customer, err := s.Store.GetCustomerByID(strconv.Itoa(order.CustomerID)); err == nil {
+		req.To.Phone, req.To.Email = customer.Number, customer.Email
+		if req.To.Country == "" {
+			req.To.Line1, req.To.Country = order.ShipmentAddress, customer.Country
+		}
+	}
+
+	if len(shipment.Packages) == 0 {
+		return req, fmt.Errorf("shipment %d has no packages: %w", shipment.ID, carriers.ErrInvalidRequest)
+	}
+	for i, p := range shipment.Packages {
+		if p.WeightKg <= 0 {
+			return req, fmt.Errorf("package %d has no weight: %w", i+1, carriers.ErrInvalidRequest)
+		}
+		req.Parcels = append(req.Parcels, carriers.Parcel{WeightKg: p.WeightKg, LengthCm: p.LengthCm, WidthCm: p.WidthCm, HeightCm: p.HeightCm})
+	}
+	return req, nil
+}
+
+func (s *ApiServer) shipmentFromPath(w http.ResponseWriter, r *http.Request) (*models.Shipment, bool) {
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid shipment ID, must be an integer")
+		return nil, false
+	}
+	shipment, err := s.Store.GetShipmentByID(shipmentID)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching shipment")
+		return nil, false
+	}
+	return shipment, true
+}
+
+// handleShipmentRates quotes every service of ?carrier= for the shipment's packages
+func (s *ApiServer) handleShipmentRates(w http.ResponseWriter, r *http.Request) {
+	shipment, ok := s.shipmentFromPath(w, r)
+	if !ok {
+		return
+	}
+	carrier, err := carriers.Get(r.URL.Query().Get("carrier"))
+	if err != nil {
+		writeCarrierError(w, err, "Error fetching rates")
+		return
+	}
+
+	order, err := s.shipTo(shipment)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching order details")
+		return
+	}
+	req, err := s.rateRequest(shipment, order)
+	if err != nil {
+		writeCarrierError(w, err, "Error fetching rates")
+		return
+	}
+	rates, err := carrier.Rates(r.Context(), req)
+	if err != nil {
+		writeCarrierError(w, err, "Error fetching rates")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rates)
+}
+
+// handleCreateShipmentLabel buys a label and stores it with its tracking
+// numbers. A label that can't be saved is cancelled at the carrier again.
+func (s *ApiServer) handleCreateShipmentLabel(w http.ResponseWriter, r *http.Request) {
+	var purchase models.LabelPurchase
+	if err := json.NewDecoder(r.Body).Decode(&purchase); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, purchase) {
+		return
+	}
+
+	shipment, ok := s.shipmentFromPath(w, r)
+	if !ok {
+		return
+	}
+	if _, err := s.Store.GetShipmentLabel(shipment.ID); err == nil {
+		writeError(w, http.StatusConflict, codeConflict, fmt.Sprintf("Shipment %d already has a label", shipment.ID), nil)
+		return
+	}
+	carrier, err := carriers.Get(purchase.Carrier)
+	if err != nil {
+		writeCarrierError(w, err, "Error buying label")
+		return
+	}
+
+	order, err := s.shipTo(shipment)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching order details")
+		return
+	}
+	req, err := s.rateRequest(shipment, order)
+	if err != nil {
+		writeCarrierError(w, err, "Error buying label")
+		return
+	}
+	label, err := carrier.CreateLabel(r.Context(), carriers.LabelRequest{
+		RateRequest:  req,
+		ServiceLevel: purchase.ServiceLevel,
+		Reference:    fmt.Sprintf("Order %d", shipment.OrderID),
+	})
+	if err != nil {
+		writeCarrierError(w, err, "Error buying label")
+		return
+	}
+
+	stored := models.ShipmentLabel{
+		ShipmentID:      shipment.ID,
+		Carrier:         carrier.Code(),
+		ServiceLevel:    purchase.ServiceLevel,
+		TrackingNumbers: label.TrackingNumbers,
+		Format:          label.Format,
+		Data:            label.Data,
+		Cost:            label.Amount,
+		Currency:        label.Currency,
+	}
+	if stored.ID, err = s.Store.SaveShipmentLabel(stored); err != nil {
+		// Void what was just bought so the shipment isn't left paying for a
+		// label we have no record of
+		for _, number := range label.TrackingNumbers {
+			if cancelErr := carrier.Cancel(r.Context(), number); cancelErr != nil {
+				log.Printf("Error cancelling unsaved %s label %s: %v", carrier.Code(), number, cancelErr)
+			}
+		}
+		writeStoreError(w, err, "Error saving label")
+		return
+	}
+	if updated, err := s.Store.GetShipmentByID(shipment.ID); err == nil {
+		s.audit(r, "update", "shipment", shipment.ID, shipment, updated)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(stored)
+}
+
+// handleDownloadShipmentLabel sends the stored label file
+func (s *ApiServer) handleDownloadShipmentLabel(w http.ResponseWriter, r *http.Request) {
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid shipment ID, must be an integer")
+		return
+	}
+	label, err := s.Store.GetShipmentLabel(shipmentID)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching label")
+		return
+	}
+
+	contentType := mime.TypeByExtension("." + label.Format)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=label-%d.%s", shipmentID, label.Format))
+	w.Write(label.Data)
+}
+
+// handleCancelShipmentLabel voids the label at the carrier, then clears its
+// tracking numbers from the shipment
+func (s *ApiServer) handleCancelShipmentLabel(w http.ResponseWriter, r *http.Request) {
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid shipment ID, must be an integer")
+		return
+	}
+	label, err := s.Store.GetShipmentLabel(shipmentID)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching label")
+		return
+	}
+	carrier, err := carriers.Get(label.Carrier)
+	if err != nil {
+		writeCarrierError(w, err, "Error cancelling label")
+		return
+	}
+	for _, number := range label.TrackingNumbers {
+		if err := carrier.Cancel(r.Context(), number); err != nil {
+			writeCarrierError(w, err, "Error cancelling label")
+			return
+		}
+	}
+
+	if err := s.Store.CancelShipmentLabel(shipmentID); err != nil {
+		writeStoreError(w, err, "Error cancelling label")
+		return
+	}
+	s.audit(r, "delete", "shipment_label", label.ID, label, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Label cancelled"})
+}
@@ -2,7 +2,10 @@ package api
 
 import (
 	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/storage"
+	"AAHAOMS/OMS/webhooks"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -14,7 +17,7 @@ import (
 func (s *ApiServer) handleTotalOrderCount(w http.ResponseWriter, r *http.Request) {
 	totalOrderCount, err := s.Store.TotalOrderCount()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching total order count: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error fetching total order count")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -23,7 +26,7 @@ func (s *ApiServer) handleTotalOrderCount(w http.ResponseWriter, r *http.Request
 func (s *ApiServer) handlerRecentOrders(w http.ResponseWriter, r *http.Request) {
 	orders, err := s.Store.GetRecentOrders(5)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching recent orders: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error fetching recent orders")
 		return
 	}
 
@@ -36,31 +39,52 @@ func (s *ApiServer) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
 	var order models.Order
 
 	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		writeInvalidPayload(w)
+		return
+	}
+	errs := models.Validate(order)
+	if order.CustomerID > 0 {
+		if _, err := s.Store.GetCustomerByID(strconv.Itoa(order.CustomerID)); errors.Is(err, storage.ErrNotFound) {
+			errs.Add("customer_id", "does not match an existing customer")
+		} else if err != nil {
+			writeStoreError(w, err, "Error fetching customer")
+			return
+		}
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
 		return
 	}
 
 	orderID, err := s.Store.CreateOrder(order)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creating order: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error creating order")
 		return
 	}
 
+	if created, err := s.Store.GetOrderByID(orderID); err == nil {
+		s.audit(r, "create", "order", orderID, nil, created)
+	}
+
 	json.NewEncoder(w).Encode(map[string]int{"order_id": orderID})
 }
 func (s *ApiServer) handlerDeleteOrder(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	orderID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		writeBadRequest(w, "Invalid order ID")
 		return
 	}
 
+	before, _ := s.Store.GetOrderByID(orderID)
+
 	err = s.Store.DeleteOrder(orderID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error deleting order: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error deleting order")
 		return
 	}
+	s.publishEvent(webhooks.OrderDeleted, map[string]int{"order_id": orderID})
+	s.audit(r, "delete", "order", orderID, before, nil)
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Order deleted successfully"})
@@ -69,51 +93,45 @@ func (s *ApiServer) handlerDeleteOrder(w http.ResponseWriter, r *http.Request) {
 func (s *ApiServer) handleGetTotalSales(w http.ResponseWriter, r *http.Request) {
 	totalSales, err := s.Store.GetTotalSalesForShippedOrders()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching total sales: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error fetching total sales")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(map[string]float64{"total_sales": totalSales})
-	if err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
-	}
+	json.NewEncoder(w).Encode(map[string]float64{"total_sales": totalSales})
 }
 func (s *ApiServer) handleGetTotalSalesByCustomer(w http.ResponseWriter, r *http.Request) {
 	// Extract the customer name from the route parameters
 	vars := mux.Vars(r)
 	customerName, ok := vars["customerName"]
 	if !ok || customerName == "" {
-		http.Error(w, "Customer name is required", http.StatusBadRequest)
+		writeBadRequest(w, "Customer name is required")
 		return
 	}
 
 	// Fetch total sales for the customer
 	totalSales, err := s.Store.GetTotalSalesForShippedOrdersByCustomer(customerName)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching total sales for customer %s: %v", customerName, err), http.StatusInternalServerError)
+		writeStoreError(w, err, fmt.Sprintf("Error fetching total sales for customer %s", customerName))
 		return
 	}
 
 	// Return the result as JSON
 	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(map[string]float64{"total_sales": totalSales})
-	if err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
-	}
+	json.NewEncoder(w).Encode(map[string]float64{"total_sales": totalSales})
 }
 
 func (s *ApiServer) handleGetOrderByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	orderID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		writeBadRequest(w, "Invalid order ID")
 		return
 	}
 
 	order, err := s.Store.GetOrderByID(orderID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching order: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error fetching order")
 		return
 	}
 
@@ -125,14 +143,14 @@ func (s *ApiServer) handleGetOrderHistoryByCustomerName(w http.ResponseWriter, r
 	vars := mux.Vars(r)
 	customerName, ok := vars["customer_name"]
 	if !ok || customerName == "" {
-		http.Error(w, "Customer name is required", http.StatusBadRequest)
+		writeBadRequest(w, "Customer name is required")
 		return
 	}
 
 	// Fetch the order history from the store
 	orders, err := s.Store.GetOrderHistoryByCustomerName(customerName)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching order history: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error fetching order history")
 		return
 	}
 
@@ -144,7 +162,7 @@ func (s *ApiServer) handleGetOrderHistoryByCustomerName(w http.ResponseWriter, r
 func (s *ApiServer) handleGetLatestOrderID(w http.ResponseWriter, r *http.Request) {
 	latestOrderID, err := s.Store.GetLatestOrderID()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching latest order ID: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error fetching latest order ID")
 		return
 	}
 
@@ -153,10 +171,18 @@ func (s *ApiServer) handleGetLatestOrderID(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(response)
 }
 
+// handleGetAllOrders lists orders, optionally filtered by
+// ?from=&to=&status=&customer_id=
 func (s *ApiServer) handleGetAllOrders(w http.ResponseWriter, r *http.Request) {
-	orders, err := s.Store.GetAllOrders()
+	filter, err := orderFilterFromQuery(r)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching orders: %v", err), http.StatusInternalServerError)
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	orders, err := s.Store.GetOrders(filter)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching orders")
 		return
 	}
 
@@ -168,23 +194,30 @@ func (s *ApiServer) UpdateOrderStatusHandler(w http.ResponseWriter, r *http.Requ
 	vars := mux.Vars(r)
 	orderID, err := strconv.Atoi(vars["id"])
 	if err != nil || orderID <= 0 {
-		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		writeBadRequest(w, "Invalid order ID")
 		return
 	}
 
-	var payload struct {
-		Status string `json:"status"`
+	var payload models.OrderStatusUpdate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeInvalidPayload(w)
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Status == "" {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if !validateRequest(w, payload) {
 		return
 	}
 
+	before, _ := s.Store.GetOrderByID(orderID)
+
 	err = s.Store.UpdateOrderStatus(orderID, payload.Status)
 	if err != nil {
-		http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+		writeStoreError(w, err, "Failed to update order status")
 		return
 	}
+	s.publishEvent(webhooks.OrderStatusUpdated, map[string]any{"order_id": orderID, "status": payload.Status})
+
+	after, _ := s.Store.GetOrderByID(orderID)
+	s.audit(r, "update", "order", orderID, before, after)
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
@@ -198,7 +231,7 @@ func (s *ApiServer) handleTotalOrderValueByCustomerName(w http.ResponseWriter, r
 
 	totalValue, err := s.Store.GetTotalOrderValueByCustomerName(customerName)
 	if err != nil {
-		http.Error(w, "Failed to calculate total order value", http.StatusInternalServerError)
+		writeStoreError(w, err, "Failed to calculate total order value")
 		return
 	}
 
@@ -214,13 +247,13 @@ func (s *ApiServer) handleOrderCountByCustomerName(w http.ResponseWriter, r *htt
 	customerName := vars["customer_name"]
 
 	if customerName == "" {
-		http.Error(w, "Customer name is required", http.StatusBadRequest)
+		writeBadRequest(w, "Customer name is required")
 		return
 	}
 
 	orderCount, err := s.Store.GetOrderCountByCustomerName(customerName)
 	if err != nil {
-		http.Error(w, "Failed to fetch order count", http.StatusInternalServerError)
+		writeStoreError(w, err, "Failed to fetch order count")
 		return
 	}
 
@@ -235,7 +268,7 @@ func (s *ApiServer) handlePendingOrderCount(w http.ResponseWriter, r *http.Reque
 	pendingCount, err := s.Store.GetPendingOrderCount()
 	log.Println(pendingCount)
 	if err != nil {
-		http.Error(w, "Failed to fetch pending order count", http.StatusInternalServerError)
+		writeStoreError(w, err, "Failed to fetch pending order count")
 		return
 	}
 
@@ -252,9 +285,26 @@ func (s *ApiServer) handleOrderByDateAndName(w http.ResponseWriter, r *http.Requ
 
 	orders, err := s.Store.GetOrdersByNameAndDate(customerName, orderDate)
 	if err != nil {
-		http.Error(w, "Failed to fetch orders", http.StatusInternalServerError)
+		writeStoreError(w, err, "Failed to fetch orders")
 		return
 	}
 
 	json.NewEncoder(w).Encode(orders)
 }
+
+func (s *ApiServer) handleGetOrderNotifications(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orderID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid order ID")
+		return
+	}
+
+	notifications, err := s.Store.GetOrderNotifications(orderID)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching notifications")
+		return
+	}
+
+	json.NewEncoder(w).Encode(notifications)
+}
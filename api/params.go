@@ -0,0 +1,21 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// intParam reads an optional integer query parameter, returning def when it
+// is absent and an error when it isn't a whole number in [min, max].
+func intParam(r *http.Request, name string, def, min, max int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%s must be a whole number between %d and %d", name, min, max)
+	}
+	return n, nil
+}
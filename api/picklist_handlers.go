@@ -0,0 +1,345 @@
+package api
+
+import (
+	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/pdf"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/xuri/excelize/v2"
+)
+
+func pickListID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid pick list ID, must be an integer")
+		return 0, false
+	}
+	return id, true
+}
+
+// handleCreatePickList makes a pick list from {"order_ids": [...]}
+func (s *ApiServer) handleCreatePickList(w http.ResponseWriter, r *http.Request) {
+	var req models.PickListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, req) {
+		return
+	}
+
+	id, err := s.Store.CreatePickList(req.OrderIDs)
+	if err != nil {
+		writeStoreError(w, err, "Error creating pick list")
+		return
+	}
+	list, err := s.Store.GetPickList(id)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching pick list")
+		return
+	}
+	s.audit(r, "create", "pick_list", id, nil, list)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(list)
+}
+
+// handleGetPickLists lists pick lists, optionally filtered by ?status=
+func (s *ApiServer) handleGetPickLists(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.PickListOpen, models.PickListPacked, models.PickListShipped, models.PickListCancelled:
+	default:
+		writeBadRequest(w, "status must be one of: open, packed, shipped, cancelled")
+		return
+	}
+
+	lists, err := s.Store.GetPickLists(status)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching pick lists")
+		return
+	}
+	json.NewEncoder(w).Encode(lists)
+}
+
+func (s *ApiServer) handleGetPickList(w http.ResponseWriter, r *http.Request) {
+	id, ok := pickListID(w, r)
+	if !ok {
+		return
+	}
+	list, err := s.Store.GetPickList(id)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching pick list")
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// handlePackPickList saves the parcels staff packed for some of the orders
+func (s *ApiServer) handlePackPickList(w http.ResponseWriter, r *http.Request) {
+	id, ok := pickListID(w, r)
+	if !ok {
+		return
+	}
+	var req models.PackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, req) {
+		return
+	}
+
+	before, err := s.Store.GetPickList(id)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching pick list")
+		return
+	}
+	if err := s.Store.SavePickListPacking(id, req); err != nil {
+		writeStoreError(w, err, "Error saving packing")
+		return
+	}
+	list, err := s.Store.GetPickList(id)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching pick list")
+		return
+	}
+	s.audit(r, "update", "pick_list", id, before, list)
+	json.NewEncoder(w).Encode(list)
+}
+
+// handleShipPickList creates the shipments for each packed order that
+// hasn't shipped yet, one per destination of a split order. Orders are
+// shipped one at a time, so one failing doesn't stop the rest; failures are
+// listed in the response and can be retried.
+func (s *ApiServer) handleShipPickList(w http.ResponseWriter, r *http.Request) {
+	id, ok := pickListID(w, r)
+	if !ok {
+		return
+	}
+	var req models.PickListShipment
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, req) {
+		return
+	}
+
+	list, err := s.Store.GetPickList(id)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching pick list")
+		return
+	}
+	if list.Status != models.PickListPacked {
+		writeError(w, http.StatusConflict, codeConflict, fmt.Sprintf("Pick list %d is %s, not packed", id, list.Status), nil)
+		return
+	}
+
+	type shipped struct {
+		OrderID    int `json:"order_id"`
+		ShipmentID int `json:"shipment_id"`
+	}
+	type failed struct {
+		OrderID int    `json:"order_id"`
+		Error   string `json:"error"`
+	}
+	result := struct {
+		Shipments []shipped `json:"shipments"`
+		Errors    []failed  `json:"errors"`
+	}{Shipments: []shipped{}, Errors: []failed{}}
+
+	for _, order := range list.Orders {
+		if order.ShipmentID != nil || len(order.Parcels) == 0 {
+			continue
+		}
+		shipmentIDs, err := s.Store.ShipPickListOrder(id, order.OrderID, order.Shipments(req))
+		if err != nil {
+			result.Errors = append(result.Errors, failed{OrderID: order.OrderID, Error: err.Error()})
+			continue
+		}
+		for _, shipmentID := range shipmentIDs {
+			if created, err := s.Store.GetShipmentByID(shipmentID); err == nil {
+				s.audit(r, "create", "shipment", shipmentID, nil, created)
+			}
+			result.Shipments = append(result.Shipments, shipped{OrderID: order.OrderID, ShipmentID: shipmentID})
+		}
+	}
+
+	if len(result.Shipments) == 0 && len(result.Errors) > 0 {
+		writeError(w, http.StatusUnprocessableEntity, codeValidation, "No orders could be shipped", result.Errors)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *ApiServer) handleCancelPickList(w http.ResponseWriter, r *http.Request) {
+	id, ok := pickListID(w, r)
+	if !ok {
+		return
+	}
+	if err := s.Store.CancelPickList(id); err != nil {
+		writeStoreError(w, err, "Error cancelling pick list")
+		return
+	}
+	s.audit(r, "delete", "pick_list", id, nil, nil)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Pick list cancelled"})
+}
+
+// handleDownloadPickList prints a pick list as ?format=pdf (the default) or
+// xlsx: the grouped items to gather, then each order's share to pack
+func (s *ApiServer) handleDownloadPickList(w http.ResponseWriter, r *http.Request) {
+	id, ok := pickListID(w, r)
+	if !ok {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "pdf" && format != "xlsx" {
+		writeBadRequest(w, "format must be pdf or xlsx")
+		return
+	}
+	list, err := s.Store.GetPickList(id)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching pick list")
+		return
+	}
+
+	if format == "xlsx" {
+		writePickListExcel(w, list)
+		return
+	}
+	writePickListPDF(w, list)
+}
+
+// describeItem is an item's name with its size and color, if any
+func describeItem(name string, size, color string) string {
+	var extra []string
+	for _, v := range []string{size, color} {
+		if v != "" {
+			extra = append(extra, v)
+		}
+	}
+	if len(extra) == 0 {
+		return name
+	}
+	return name + " (" + strings.Join(extra, ", ") + ")"
+}
+
+func pickLineOrders(line models.PickLine) string {
+	parts := make([]string, len(line.Orders))
+	for i, o := range line.Orders {
+		parts[i] = fmt.Sprintf("#%d x%d", o.OrderID, o.Quantity)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// dateOnly cuts a timestamp read from the database down to its date
+func dateOnly(s string) string {
+	if len(s) > 10 {
+		return s[:10]
+	}
+	return s
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func writePickListPDF(w http.ResponseWriter, list models.PickList) {
+	doc := pdf.New(fmt.Sprintf("Pick list %d", list.ID))
+	y := pdf.Margin + 16
+	doc.Text(pdf.Margin, y, pdf.HelveticaBold, 16, fmt.Sprintf("Pick List #%d", list.ID))
+	doc.TextRight(pdf.PageWidth-pdf.Margin, y, pdf.Helvetica, 10, "AAHA FELT")
+	y += 16
+	doc.Text(pdf.Margin, y, pdf.Helvetica, 10, fmt.Sprintf("Created %s  ·  %d orders  ·  %s", dateOnly(list.CreatedAt), len(list.Orders), list.Status))
+	y += 24
+
+	doc.Text(pdf.Margin, y, pdf.HelveticaBold, 12, "Items to pick")
+	y += 8
+	rows := make([][]string, len(list.Lines))
+	for i, line := range list.Lines {
+		rows[i] = []string{"[  ]", describeItem(line.Name, line.Size, line.Color), strconv.Itoa(line.Quantity), pickLineOrders(line)}
+	}
+	y = doc.Table(y, []pdf.Column{
+		{Title: "Picked", Width: 45},
+		{Title: "Item", Width: 230},
+		{Title: "Qty", Width: 40, Right: true},
+		{Title: "Orders", Width: 200},
+	}, rows)
+
+	y = doc.EnsureSpace(y+20, 40)
+	doc.Text(pdf.Margin, y, pdf.HelveticaBold, 12, "By order")
+	y += 8
+	rows = nil
+	for _, o := range list.Orders {
+		for _, item := range o.Items {
+			rows = append(rows, []string{
+				fmt.Sprintf("#%d", o.OrderID), o.CustomerName,
+				describeItem(item.Name, stringOrEmpty(item.Size), stringOrEmpty(item.Color)),
+				strconv.Itoa(item.Quantity), strconv.Itoa(item.Packed),
+			})
+		}
+	}
+	doc.Table(y, []pdf.Column{
+		{Title: "Order", Width: 50},
+		{Title: "Customer", Width: 140},
+		{Title: "Item", Width: 215},
+		{Title: "Qty", Width: 50, Right: true},
+		{Title: "Packed", Width: 60, Right: true},
+	}, rows)
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=picklist-%d.pdf", list.ID))
+	doc.WriteTo(w)
+}
+
+func writePickListExcel(w http.ResponseWriter, list models.PickList) {
+	f := excelize.NewFile()
+	sheet := "Pick list"
+	f.SetSheetName(f.GetSheetName(0), sheet)
+	bold, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+
+	f.SetCellValue(sheet, "A1", fmt.Sprintf("Pick List #%d", list.ID))
+	f.SetCellValue(sheet, "A2", "Created:")
+	f.SetCellValue(sheet, "B2", dateOnly(list.CreatedAt))
+	f.SetCellValue(sheet, "C2", "Status:")
+	f.SetCellValue(sheet, "D2", list.Status)
+	f.SetCellStyle(sheet, "A1", "A1", bold)
+
+	f.SetSheetRow(sheet, "A4", &[]any{"Picked", "Item", "Size", "Color", "Qty", "Orders"})
+	f.SetCellStyle(sheet, "A4", "F4", bold)
+	for i, line := range list.Lines {
+		cell, _ := excelize.CoordinatesToCellName(1, i+5)
+		f.SetSheetRow(sheet, cell, &[]any{"", line.Name, line.Size, line.Color, line.Quantity, pickLineOrders(line)})
+	}
+	f.SetColWidth(sheet, "B", "B", 30)
+	f.SetColWidth(sheet, "F", "F", 40)
+
+	byOrder := "By order"
+	f.NewSheet(byOrder)
+	f.SetSheetRow(byOrder, "A1", &[]any{"Order", "Customer", "Item", "Size", "Color", "Qty", "Packed"})
+	f.SetCellStyle(byOrder, "A1", "G1", bold)
+	row := 2
+	for _, o := range list.Orders {
+		for _, item := range o.Items {
+			cell, _ := excelize.CoordinatesToCellName(1, row)
+			f.SetSheetRow(byOrder, cell, &[]any{o.OrderID, o.CustomerName, item.Name, stringOrEmpty(item.Size), stringOrEmpty(item.Color), item.Quantity, item.Packed})
+			row++
+		}
+	}
+	f.SetColWidth(byOrder, "B", "C", 30)
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=picklist-%d.xlsx", list.ID))
+	if err := f.Write(w); err != nil {
+		writeStoreError(w, err, "Error writing excel file")
+	}
+}
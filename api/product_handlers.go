@@ -0,0 +1,110 @@
+package api
+
+import (
+	"AAHAOMS/OMS/models"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func productID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid product ID, must be an integer")
+		return 0, false
+	}
+	return id, true
+}
+
+func (s *ApiServer) handleGetProducts(w http.ResponseWriter, r *http.Request) {
+	products, err := s.Store.GetProducts()
+	if err != nil {
+		writeStoreError(w, err, "Error fetching products")
+		return
+	}
+	json.NewEncoder(w).Encode(products)
+}
+
+func (s *ApiServer) handleGetProduct(w http.ResponseWriter, r *http.Request) {
+	id, ok := productID(w, r)
+	if !ok {
+		return
+	}
+	product, err := s.Store.GetProduct(id)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching product")
+		return
+	}
+	json.NewEncoder(w).Encode(product)
+}
+
+func (s *ApiServer) handleCreateProduct(w http.ResponseWriter, r *http.Request) {
+	var product models.Product
+	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, product) {
+		return
+	}
+
+	id, err := s.Store.CreateProduct(product)
+	if err != nil {
+		writeStoreError(w, err, "Error creating product")
+		return
+	}
+	created, _ := s.Store.GetProduct(id)
+	s.audit(r, "create", "product", id, nil, created)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (s *ApiServer) handleUpdateProduct(w http.ResponseWriter, r *http.Request) {
+	id, ok := productID(w, r)
+	if !ok {
+		return
+	}
+	var product models.Product
+	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, product) {
+		return
+	}
+	product.ID = id
+
+	before, err := s.Store.GetProduct(id)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching product")
+		return
+	}
+	if err := s.Store.UpdateProduct(product); err != nil {
+		writeStoreError(w, err, "Error updating product")
+		return
+	}
+	updated, _ := s.Store.GetProduct(id)
+	s.audit(r, "update", "product", id, before, updated)
+	json.NewEncoder(w).Encode(updated)
+}
+
+func (s *ApiServer) handleDeleteProduct(w http.ResponseWriter, r *http.Request) {
+	id, ok := productID(w, r)
+	if !ok {
+		return
+	}
+	before, err := s.Store.GetProduct(id)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching product")
+		return
+	}
+	if err := s.Store.DeleteProduct(id); err != nil {
+		writeStoreError(w, err, "Error deleting product")
+		return
+	}
+	s.audit(r, "delete", "product", id, before, nil)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Product deleted successfully"})
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/storage"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// handleSalesReport serves
+// /reports/sales?from=&to=&granularity=day|week|month&group_by=customer|country|product&top=
+func (s *ApiServer) handleSalesReport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.SalesFilter{
+		Granularity: query.Get("granularity"),
+		GroupBy:     query.Get("group_by"),
+	}
+
+	var err error
+	if filter.From, err = dateParam(r, "from"); err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	if filter.To, err = dateParam(r, "to"); err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	if filter.Top, err = intParam(r, "top", 10, 1, 100); err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	switch filter.Granularity {
+	case "":
+		filter.Granularity = models.GranularityMonth
+	case models.GranularityDay, models.GranularityWeek, models.GranularityMonth:
+	default:
+		writeBadRequest(w, "granularity must be day, week or month")
+		return
+	}
+	switch filter.GroupBy {
+	case "", models.GroupByCustomer, models.GroupByCountry, models.GroupByProduct:
+	default:
+		writeBadRequest(w, "group_by must be customer, country or product")
+		return
+	}
+
+	// The filter is checked above, so a validation error here is a range
+	// spanning too many periods
+	report, err := s.Store.GetSalesReport(filter)
+	if errors.Is(err, storage.ErrValidation) {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err, "Error building sales report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// handleBacklogReport lists every pending and shipped and due order with
+// its aging bucket, ?as_of= (default today) and ?customer_id=
+func (s *ApiServer) handleBacklogReport(w http.ResponseWriter, r *http.Request) {
+	s.writeBacklog(w, r, false)
+}
+
+// handleOverdueOrders is the backlog limited to orders past shipment_due
+func (s *ApiServer) handleOverdueOrders(w http.ResponseWriter, r *http.Request) {
+	s.writeBacklog(w, r, true)
+}
+
+func (s *ApiServer) writeBacklog(w http.ResponseWriter, r *http.Request, overdueOnly bool) {
+	filter := models.BacklogFilter{OverdueOnly: overdueOnly}
+
+	var err error
+	if filter.AsOf, err = dateParam(r, "as_of"); err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	if filter.AsOf == "" {
+		filter.AsOf = time.Now().Format("2006-01-02")
+	}
+	if filter.CustomerID, err = intParam(r, "customer_id", 0, 1, 1<<31-1); err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	report, err := s.Store.GetBacklog(filter)
+	if err != nil {
+		writeStoreError(w, err, "Error building backlog report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// handleFulfillmentReport serves /reports/fulfillment?from=&to=&customer_id=
+// where the dates bound the order date
+func (s *ApiServer) handleFulfillmentReport(w http.ResponseWriter, r *http.Request) {
+	var filter models.FulfillmentFilter
+	var err error
+	if filter.From, err = dateParam(r, "from"); err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	if filter.To, err = dateParam(r, "to"); err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	if filter.CustomerID, err = intParam(r, "customer_id", 0, 1, 1<<31-1); err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	report, err := s.Store.GetFulfillmentReport(filter)
+	if err != nil {
+		writeStoreError(w, err, "Error building fulfillment report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -60,23 +60,96 @@ package api
 //MARK: TODO: Delete Customer function
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 
+	"AAHAOMS/OMS/carriers/mock"
+	"AAHAOMS/OMS/jobs"
+	"AAHAOMS/OMS/mailer"
+	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/notifications"
 	"AAHAOMS/OMS/storage"
+	"AAHAOMS/OMS/webhooks"
 
 	"github.com/gorilla/mux"
 )
 
 // ApiServer struct
 type ApiServer struct {
-	Address string
-	Store   storage.Storage
+	Address  string
+	Store    storage.Storage
+	Mailer   mailer.Mailer
+	Notifier *notifications.Notifier
+	Webhooks *webhooks.Dispatcher
+	Jobs     *jobs.Runner
+
+	// DigestRecipients get the daily overdue orders email at DigestHour
+	DigestRecipients []string
+	DigestHour       int
+
+	// CarrierWebhookSecret signs tracking webhooks from carriers; they are
+	// refused while it is empty
+	CarrierWebhookSecret string
+
+	// Currency is what order prices are in, as declared on customs documents
+	Currency string
 }
 
 // NewApiServer creates a new server instance
 func NewApiServer(address string, store storage.Storage) *ApiServer {
-	return &ApiServer{Address: address, Store: store}
+	m, err := mailer.FromEnv()
+	if err != nil {
+		fmt.Printf("Invalid mail configuration, logging mail instead: %v\n", err)
+		m = mailer.NewFileMailer("", "")
+	}
+	workers, err := strconv.Atoi(os.Getenv("JOB_WORKERS"))
+	if err != nil || workers < 1 {
+		workers = 4
+	}
+	digestHour, err := strconv.Atoi(os.Getenv("OVERDUE_DIGEST_HOUR"))
+	if err != nil || digestHour < 0 || digestHour > 23 {
+		digestHour = 7
+	}
+
+	s := &ApiServer{
+		Address:  address,
+		Store:    store,
+		Mailer:   m,
+		Notifier: notifications.NewNotifier(store, m),
+		Webhooks: webhooks.NewDispatcher(store),
+		Jobs:     jobs.NewRunner(store, workers),
+
+		DigestRecipients: splitList(os.Getenv("OVERDUE_DIGEST_TO")),
+		DigestHour:       digestHour,
+
+		CarrierWebhookSecret: os.Getenv("CARRIER_WEBHOOK_SECRET"),
+		Currency:             os.Getenv("CURRENCY"),
+	}
+	if s.Currency == "" {
+		s.Currency = "USD"
+	}
+	if os.Getenv("CARRIER_MOCK") != "" {
+		if err := mock.Register(); err != nil {
+			fmt.Printf("Mock carrier unavailable: %v\n", err)
+		}
+	}
+	s.registerJobs()
+	return s
+}
+
+// splitList splits a comma separated setting, dropping blank entries
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
 }
 
 // CORS Middleware
@@ -84,8 +157,8 @@ func enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Allow requests from React frontend on port 8082
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Actor")
 
 		// Handle preflight OPTIONS request
 		if r.Method == http.MethodOptions {
@@ -99,15 +172,53 @@ func enableCORS(next http.Handler) http.Handler {
 
 // Start initializes the server
 func (s *ApiServer) Start() {
+	// Apply CORS middleware to all routes
+	corsRouter := enableCORS(s.routes())
+
+	go s.Webhooks.Run(context.Background())
+	go s.Jobs.Run(context.Background())
+	if len(s.DigestRecipients) > 0 {
+		go s.Jobs.Daily(context.Background(), models.JobOverdueDigest, s.DigestHour, "digest", func(date string) any {
+			return models.OverdueDigestPayload{Date: date}
+		})
+	}
+
+	fmt.Printf("Server starting on %s...\n", s.Address)
+	if err := http.ListenAndServe(s.Address, corsRouter); err != nil {
+		fmt.Printf("Error starting server: %v\n", err)
+	}
+}
+
+// routes registers every endpoint. mux tries routes in the order they are
+// added, so specific paths go before catch-alls that would also match them.
+func (s *ApiServer) routes() *mux.Router {
 	router := mux.NewRouter()
 
 	// MARK: Customers
 	router.HandleFunc("/customers", makeHandler(wrapHandler(s.handleCustomers))).Methods("POST")
 	router.HandleFunc("/customers", makeHandler(wrapHandler(s.getAllCustomers))).Methods("GET")
+	router.HandleFunc("/customers/typeahead", makeHandler(wrapHandler(s.handleCustomerTypeahead))).Methods("GET")
+	router.HandleFunc("/customers/export", makeHandler(wrapHandler(s.handleExportCustomers))).Methods("GET")
+	router.HandleFunc("/customers/duplicates", makeHandler(wrapHandler(s.handleGetDuplicateCustomers))).Methods("GET")
 	router.HandleFunc("/customers/{id:[0-9]+}", makeHandler(wrapHandler(s.getCustomerByID))).Methods("GET")
 	router.HandleFunc("/customer/totalCount", makeHandler(wrapHandler(s.getCustumerCount))).Methods("GET")
 	router.HandleFunc("/customers/{id}", makeHandler(wrapHandler(s.handleEditCustomers))).Methods("PUT")
 	router.HandleFunc("/customers/{id}", makeHandler(wrapHandler(s.handleDeleteCustomer))).Methods("DELETE")
+	router.HandleFunc("/customers/{id:[0-9]+}/notifications", makeHandler(wrapHandler(s.handleCustomerNotificationPreference))).Methods("PUT")
+	router.HandleFunc("/customers/{id:[0-9]+}/orders", makeHandler(wrapHandler(s.handleGetCustomerOrders))).Methods("GET")
+	router.HandleFunc("/customers/{id:[0-9]+}/shipments", makeHandler(wrapHandler(s.handleGetCustomerShipments))).Methods("GET")
+	router.HandleFunc("/customers/{id:[0-9]+}/sales", makeHandler(wrapHandler(s.handleGetCustomerSales))).Methods("GET")
+	router.HandleFunc("/customers/{id:[0-9]+}/summary", makeHandler(wrapHandler(s.handleGetCustomerSummary))).Methods("GET")
+	router.HandleFunc("/customers/{id:[0-9]+}/addresses", makeHandler(wrapHandler(s.handleGetCustomerAddresses))).Methods("GET")
+	router.HandleFunc("/customers/{id:[0-9]+}/addresses", makeHandler(wrapHandler(s.handleCreateCustomerAddress))).Methods("POST")
+	router.HandleFunc("/customers/{id:[0-9]+}/addresses/{address_id:[0-9]+}", makeHandler(wrapHandler(s.handleUpdateCustomerAddress))).Methods("PUT")
+	router.HandleFunc("/customers/{id:[0-9]+}/addresses/{address_id:[0-9]+}", makeHandler(wrapHandler(s.handleDeleteCustomerAddress))).Methods("DELETE")
+	router.HandleFunc("/customers/{id:[0-9]+}/contacts", makeHandler(wrapHandler(s.handleGetCustomerContacts))).Methods("GET")
+	router.HandleFunc("/customers/{id:[0-9]+}/contacts", makeHandler(wrapHandler(s.handleCreateCustomerContact))).Methods("POST")
+	router.HandleFunc("/customers/{id:[0-9]+}/contacts/{contact_id:[0-9]+}", makeHandler(wrapHandler(s.handleUpdateCustomerContact))).Methods("PUT")
+	router.HandleFunc("/customers/{id:[0-9]+}/contacts/{contact_id:[0-9]+}", makeHandler(wrapHandler(s.handleDeleteCustomerContact))).Methods("DELETE")
+	router.HandleFunc("/customers/{id:[0-9]+}/merge", makeHandler(wrapHandler(s.handleMergeCustomer))).Methods("POST")
+	router.HandleFunc("/customers/{id:[0-9]+}/merges", makeHandler(wrapHandler(s.handleGetCustomerMerges))).Methods("GET")
 
 	// MARK: Orders
 
@@ -119,11 +230,19 @@ func (s *ApiServer) Start() {
 	router.HandleFunc("/orders/total-value/{customer_name}", makeHandler(wrapHandler(s.handleTotalOrderValueByCustomerName))).Methods("GET")
 	router.HandleFunc("/order/totalordercount", makeHandler(wrapHandler(s.handleTotalOrderCount))).Methods("GET")
 	router.HandleFunc("/orders/recentorders", makeHandler(wrapHandler(s.handlerRecentOrders))).Methods("GET")
+	router.HandleFunc("/orders/export", makeHandler(wrapHandler(s.handleExportOrders))).Methods("GET")
+	router.HandleFunc("/orders/overdue", makeHandler(wrapHandler(s.handleOverdueOrders))).Methods("GET")
 
 	router.HandleFunc("/orders/history/{customer_name}", makeHandler(wrapHandler(s.handleGetOrderHistoryByCustomerName))).Methods("GET")
 	router.HandleFunc("/orders/pending-count", makeHandler(wrapHandler(s.handlePendingOrderCount))).Methods("GET")
 	router.HandleFunc("/orders/count/{customer_name}", makeHandler(wrapHandler(s.handleOrderCountByCustomerName))).Methods("GET")
 	router.HandleFunc("/orders/latestOrderId", makeHandler(wrapHandler(s.handleGetLatestOrderID))).Methods("GET")
+	router.HandleFunc("/orders/{id:[0-9]+}/notifications", makeHandler(wrapHandler(s.handleGetOrderNotifications))).Methods("GET")
+	router.HandleFunc("/orders/{id:[0-9]+}/destinations", makeHandler(wrapHandler(s.handleGetOrderDestinations))).Methods("GET")
+	router.HandleFunc("/orders/{id:[0-9]+}/destinations", makeHandler(wrapHandler(s.handleSetOrderDestinations))).Methods("PUT")
+	router.HandleFunc("/orders/{id:[0-9]+}/destinations", makeHandler(wrapHandler(s.handleDeleteOrderDestinations))).Methods("DELETE")
+	// Any other two-segment GET is a customer name and order date, so this
+	// has to come after the /orders/{id}/... routes
 	router.HandleFunc("/orders/{customer_name}/{order_date}", makeHandler(wrapHandler(s.handleOrderByDateAndName))).Methods("GET")
 	router.HandleFunc("/due_items/{order_id}", makeHandler(wrapHandler(s.handleGetDueItems))).Methods("GET")
 
@@ -131,8 +250,19 @@ func (s *ApiServer) Start() {
 	router.HandleFunc("/shipments", makeHandler(wrapHandler(s.handlePostShipment))).Methods("POST")
 	router.HandleFunc("/shipments", makeHandler(wrapHandler(s.handleGetAllShipments))).Methods("GET")
 	router.HandleFunc("/shipments/completed", makeHandler(wrapHandler(s.handleGetCompletedShipments))).Methods("GET")
+	router.HandleFunc("/shipments/export", makeHandler(wrapHandler(s.handleExportShipments))).Methods("GET")
+	router.HandleFunc("/carriers", makeHandler(wrapHandler(s.handleGetCarriers))).Methods("GET")
 	router.HandleFunc("/shipments/{id}", makeHandler(wrapHandler(s.handleGetShipmentByID))).Methods("GET")
 	router.HandleFunc("/shipments/{id}/download", s.handleDownloadShipmentExcel).Methods("GET")
+	router.HandleFunc("/shipments/{id:[0-9]+}/commercial-invoice", makeHandler(wrapHandler(s.handleCommercialInvoice))).Methods("GET")
+	router.HandleFunc("/shipments/{id:[0-9]+}/packing-list", makeHandler(wrapHandler(s.handlePackingList))).Methods("GET")
+	router.HandleFunc("/shipments/{id:[0-9]+}/rates", makeHandler(wrapHandler(s.handleShipmentRates))).Methods("GET")
+	router.HandleFunc("/shipments/{id:[0-9]+}/label", makeHandler(wrapHandler(s.handleCreateShipmentLabel))).Methods("POST")
+	router.HandleFunc("/shipments/{id:[0-9]+}/label", makeHandler(wrapHandler(s.handleDownloadShipmentLabel))).Methods("GET")
+	router.HandleFunc("/shipments/{id:[0-9]+}/label", makeHandler(wrapHandler(s.handleCancelShipmentLabel))).Methods("DELETE")
+	router.HandleFunc("/shipments/{id:[0-9]+}/events", makeHandler(wrapHandler(s.handleGetTrackingEvents))).Methods("GET")
+	router.HandleFunc("/shipments/{id:[0-9]+}/events", makeHandler(wrapHandler(s.handleAddTrackingEvent))).Methods("POST")
+	router.HandleFunc("/carriers/{code}/tracking", s.handleCarrierTrackingWebhook).Methods("POST")
 
 	router.HandleFunc("/shipments/shipped-pending", makeHandler(wrapHandler(s.handleGetShippedButPendingShipments))).Methods("GET")
 	router.Handle("/shipments/{customer_name}", makeHandler(wrapHandler(s.handleGetShipmentHistoryByCustomerName))).Methods("GET")
@@ -143,11 +273,47 @@ func (s *ApiServer) Start() {
 	router.HandleFunc("/totalSales", makeHandler(wrapHandler(s.handleGetTotalSales))).Methods("GET")
 	router.HandleFunc("/totalSales/{customerName}", makeHandler(wrapHandler(s.handleGetTotalSalesByCustomer))).Methods("GET")
 
-	// Apply CORS middleware to all routes
-	corsRouter := enableCORS(router)
+	// MARK: Products
+	router.HandleFunc("/products", makeHandler(wrapHandler(s.handleCreateProduct))).Methods("POST")
+	router.HandleFunc("/products", makeHandler(wrapHandler(s.handleGetProducts))).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}", makeHandler(wrapHandler(s.handleGetProduct))).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}", makeHandler(wrapHandler(s.handleUpdateProduct))).Methods("PUT")
+	router.HandleFunc("/products/{id:[0-9]+}", makeHandler(wrapHandler(s.handleDeleteProduct))).Methods("DELETE")
 
-	fmt.Printf("Server starting on %s...\n", s.Address)
-	if err := http.ListenAndServe(s.Address, corsRouter); err != nil {
-		fmt.Printf("Error starting server: %v\n", err)
-	}
+	// MARK: Pick lists
+	router.HandleFunc("/picklists", makeHandler(wrapHandler(s.handleCreatePickList))).Methods("POST")
+	router.HandleFunc("/picklists", makeHandler(wrapHandler(s.handleGetPickLists))).Methods("GET")
+	router.HandleFunc("/picklists/{id:[0-9]+}", makeHandler(wrapHandler(s.handleGetPickList))).Methods("GET")
+	router.HandleFunc("/picklists/{id:[0-9]+}", makeHandler(wrapHandler(s.handleCancelPickList))).Methods("DELETE")
+	router.HandleFunc("/picklists/{id:[0-9]+}/download", makeHandler(wrapHandler(s.handleDownloadPickList))).Methods("GET")
+	router.HandleFunc("/picklists/{id:[0-9]+}/pack", makeHandler(wrapHandler(s.handlePackPickList))).Methods("PUT")
+	router.HandleFunc("/picklists/{id:[0-9]+}/ship", makeHandler(wrapHandler(s.handleShipPickList))).Methods("POST")
+
+	// MARK: Imports
+	router.HandleFunc("/import/customers", makeHandler(wrapHandler(s.handleImportCustomers))).Methods("POST")
+	router.HandleFunc("/import/orders", makeHandler(wrapHandler(s.handleImportOrders))).Methods("POST")
+
+	// MARK: Reports
+	router.HandleFunc("/reports/sales", makeHandler(wrapHandler(s.handleSalesReport))).Methods("GET")
+	router.HandleFunc("/reports/backlog", makeHandler(wrapHandler(s.handleBacklogReport))).Methods("GET")
+	router.HandleFunc("/reports/fulfillment", makeHandler(wrapHandler(s.handleFulfillmentReport))).Methods("GET")
+
+	// MARK: Webhooks
+	router.HandleFunc("/webhooks", makeHandler(wrapHandler(s.handleCreateWebhook))).Methods("POST")
+	router.HandleFunc("/webhooks", makeHandler(wrapHandler(s.handleGetWebhooks))).Methods("GET")
+	router.HandleFunc("/webhooks/{id:[0-9]+}", makeHandler(wrapHandler(s.handleUpdateWebhook))).Methods("PATCH")
+	router.HandleFunc("/webhooks/{id:[0-9]+}", makeHandler(wrapHandler(s.handleDeleteWebhook))).Methods("DELETE")
+	router.HandleFunc("/webhooks/{id:[0-9]+}/deliveries", makeHandler(wrapHandler(s.handleGetWebhookDeliveries))).Methods("GET")
+	router.HandleFunc("/webhooks/deliveries/{id:[0-9]+}/replay", makeHandler(wrapHandler(s.handleReplayWebhookDelivery))).Methods("POST")
+
+	// MARK: Audit
+	router.HandleFunc("/audit", makeHandler(wrapHandler(s.handleGetAudit))).Methods("GET")
+	router.HandleFunc("/audit/export", makeHandler(wrapHandler(s.handleExportAudit))).Methods("GET")
+
+	// MARK: Admin
+	router.HandleFunc("/admin/jobs", makeHandler(wrapHandler(s.handleGetJobs))).Methods("GET")
+	router.HandleFunc("/admin/jobs/{id:[0-9]+}", makeHandler(wrapHandler(s.handleGetJob))).Methods("GET")
+	router.HandleFunc("/admin/jobs/{id:[0-9]+}/retry", makeHandler(wrapHandler(s.handleRetryJob))).Methods("POST")
+
+	return router
 }
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestOrderRoutesAheadOfNameAndDate(t *testing.T) {
+	router := (&ApiServer{}).routes()
+
+	for _, tc := range []struct {
+		method, path, template string
+	}{
+		{"GET", "/orders/42/destinations", "/orders/{id:[0-9]+}/destinations"},
+		{"PUT", "/orders/42/destinations", "/orders/{id:[0-9]+}/destinations"},
+		{"DELETE", "/orders/42/destinations", "/orders/{id:[0-9]+}/destinations"},
+		{"GET", "/orders/42/notifications", "/orders/{id:[0-9]+}/notifications"},
+		{"GET", "/orders/Sita%20Rai/2026-10-19", "/orders/{customer_name}/{order_date}"},
+	} {
+		var match mux.RouteMatch
+		if !router.Match(httptest.NewRequest(tc.method, tc.path, nil), &match) {
+			t.Errorf("%s %s: no route", tc.method, tc.path)
+			continue
+		}
+		if got, _ := match.Route.GetPathTemplate(); got != tc.template {
+			t.Errorf("%s %s: routed to %s, want %s", tc.method, tc.path, got, tc.template)
+		}
+	}
+}
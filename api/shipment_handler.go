@@ -1,11 +1,14 @@
 package api
 
 import (
+	"AAHAOMS/OMS/carriers"
 	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/webhooks"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/xuri/excelize/v2"
@@ -16,24 +19,39 @@ func (s *ApiServer) handlePostShipment(w http.ResponseWriter, r *http.Request) {
 	var shipment models.Shipment
 
 	if err := json.NewDecoder(r.Body).Decode(&shipment); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, shipment) {
 		return
 	}
 
-	err := s.Store.HandleShipment(shipment)
+	shipmentID, err := s.Store.HandleShipment(shipment)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error processing shipment: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error processing shipment")
 		return
 	}
 
+	if created, err := s.Store.GetShipmentByID(shipmentID); err == nil {
+		s.audit(r, "create", "shipment", shipmentID, nil, created)
+	}
+
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "shipment processed successfully"})
+	json.NewEncoder(w).Encode(map[string]any{"status": "shipment processed successfully", "shipment_id": shipmentID})
 }
 
+// handleGetAllShipments lists shipments, optionally filtered by
+// ?from=&to=&status=&customer_id=&order_id=
 func (s *ApiServer) handleGetAllShipments(w http.ResponseWriter, r *http.Request) {
-	shipments, err := s.Store.GetAllShipments()
+	filter, err := shipmentFilterFromQuery(r)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error retrieving all shipments: %v", err), http.StatusInternalServerError)
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	shipments, err := s.Store.GetShipments(filter)
+	if err != nil {
+		writeStoreError(w, err, "Error retrieving all shipments")
 		return
 	}
 	json.NewEncoder(w).Encode(shipments)
@@ -42,7 +60,7 @@ func (s *ApiServer) handleGetAllShipments(w http.ResponseWriter, r *http.Request
 func (s *ApiServer) handleGetCompletedShipments(w http.ResponseWriter, r *http.Request) {
 	shipments, err := s.Store.GetCompletedShipments()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error retrieving completed shipments: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error retrieving completed shipments")
 		return
 	}
 	json.NewEncoder(w).Encode(shipments)
@@ -51,7 +69,7 @@ func (s *ApiServer) handleGetCompletedShipments(w http.ResponseWriter, r *http.R
 func (s *ApiServer) handleGetShippedButPendingShipments(w http.ResponseWriter, r *http.Request) {
 	shipments, err := s.Store.GetShippedButPendingShipments()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error retrieving shipped but pending shipments: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error retrieving shipped but pending shipments")
 		return
 	}
 	json.NewEncoder(w).Encode(shipments)
@@ -61,16 +79,33 @@ func (s *ApiServer) handleDeleteShipment(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	shipmentID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		writeBadRequest(w, "Invalid shipment ID")
 		return
 	}
 
+	// Look up the shipment before the row disappears, for the webhook and audit log
+	payload := map[string]int{"shipment_id": shipmentID}
+	existing, err := s.Store.GetShipmentByID(shipmentID)
+	var before models.Order
+	if err == nil {
+		payload["order_id"] = existing.OrderID
+		before, _ = s.Store.GetOrderByID(existing.OrderID)
+	}
+
 	// Call the DeleteShipment function
 	err = s.Store.DeleteShipment(shipmentID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error deleting shipment: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error deleting shipment")
 		return
 	}
+	s.publishEvent(webhooks.ShipmentDeleted, payload)
+	// Taking a shipment back can put the order back to pending or due
+	if existing != nil {
+		if after, err := s.Store.GetOrderByID(existing.OrderID); err == nil && after.OrderStatus != before.OrderStatus {
+			s.publishEvent(webhooks.OrderStatusUpdated, map[string]any{"order_id": after.ID, "status": after.OrderStatus})
+		}
+	}
+	s.audit(r, "delete", "shipment", shipmentID, existing, nil)
 
 	// Respond with success
 	w.WriteHeader(http.StatusOK)
@@ -81,21 +116,21 @@ func (s *ApiServer) handleGetDueItems(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	orderIDStr, ok := vars["order_id"]
 	if !ok {
-		http.Error(w, "Missing order_id path parameter", http.StatusBadRequest)
+		writeBadRequest(w, "Missing order_id path parameter")
 		return
 	}
 
 	// Converting order_id to an integer
 	orderID, err := strconv.Atoi(orderIDStr)
 	if err != nil {
-		http.Error(w, "Invalid order_id, must be an integer", http.StatusBadRequest)
+		writeBadRequest(w, "Invalid order_id, must be an integer")
 		return
 	}
 
 	// Fetching due items from the database
 	dueItems, err := s.Store.GetDueItems(orderID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error retrieving due items: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error retrieving due items")
 		return
 	}
 
@@ -109,14 +144,14 @@ func (s *ApiServer) handleGetShipmentHistoryByCustomerName(w http.ResponseWriter
 	vars := mux.Vars(r)
 	customerName, ok := vars["customer_name"]
 	if !ok || customerName == "" {
-		http.Error(w, "Customer name is required", http.StatusBadRequest)
+		writeBadRequest(w, "Customer name is required")
 		return
 	}
 
 	// Fetch the order history from the store
 	shipment, err := s.Store.GetShipmentByName(customerName)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching order history: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error fetching order history")
 		return
 	}
 
@@ -130,23 +165,19 @@ func (s *ApiServer) handleGetShipmentByID(w http.ResponseWriter, r *http.Request
 	vars := mux.Vars(r)
 	shipmentIDStr, ok := vars["id"]
 	if !ok {
-		http.Error(w, "Missing shipment ID in the URL", http.StatusBadRequest)
+		writeBadRequest(w, "Missing shipment ID in the URL")
 		return
 	}
 
 	shipmentID, err := strconv.Atoi(shipmentIDStr)
 	if err != nil {
-		http.Error(w, "Invalid shipment ID, must be an integer", http.StatusBadRequest)
+		writeBadRequest(w, "Invalid shipment ID, must be an integer")
 		return
 	}
 
 	shipment, err := s.Store.GetShipmentByID(shipmentID)
 	if err != nil {
-		if err.Error() == "shipment not found" {
-			http.Error(w, "Shipment not found", http.StatusNotFound)
-		} else {
-			http.Error(w, fmt.Sprintf("Error fetching shipment: %v", err), http.StatusInternalServerError)
-		}
+		writeStoreError(w, err, "Error fetching shipment")
 		return
 	}
 
@@ -160,31 +191,27 @@ func (s *ApiServer) handleDownloadShipmentExcel(w http.ResponseWriter, r *http.R
 	vars := mux.Vars(r)
 	shipmentIDStr, ok := vars["id"]
 	if !ok {
-		http.Error(w, "Missing shipment ID in the URL", http.StatusBadRequest)
+		writeBadRequest(w, "Missing shipment ID in the URL")
 		return
 	}
 
 	shipmentID, err := strconv.Atoi(shipmentIDStr)
 	if err != nil {
-		http.Error(w, "Invalid shipment ID, must be an integer", http.StatusBadRequest)
+		writeBadRequest(w, "Invalid shipment ID, must be an integer")
 		return
 	}
 
 	// Get the shipment details.
 	shipment, err := s.Store.GetShipmentByID(shipmentID)
 	if err != nil {
-		if err.Error() == "shipment not found" {
-			http.Error(w, "Shipment not found", http.StatusNotFound)
-		} else {
-			http.Error(w, fmt.Sprintf("Error fetching shipment: %v", err), http.StatusInternalServerError)
-		}
+		writeStoreError(w, err, "Error fetching shipment")
 		return
 	}
 
 	// Since shipment does not have customer details, fetch the order using shipment.OrderID.
 	order, err := s.Store.GetOrderByID(shipment.OrderID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching order details: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error fetching order details")
 		return
 	}
 
@@ -233,6 +260,21 @@ func (s *ApiServer) handleDownloadShipmentExcel(w http.ResponseWriter, r *http.R
 
 	f.SetCellValue(sheetName, "A8", "Shipment Date:")
 	f.SetCellValue(sheetName, "B8", shippedDate)
+	if shipment.Carrier != "" {
+		f.SetCellValue(sheetName, "D8", "Carrier:")
+		carrier := carriers.Name(shipment.Carrier)
+		if shipment.ServiceLevel != "" {
+			carrier += " (" + shipment.ServiceLevel + ")"
+		}
+		f.SetCellValue(sheetName, "E8", carrier)
+	}
+	if numbers := shipment.TrackingNumbers(); len(numbers) > 0 {
+		f.SetCellValue(sheetName, "A9", "Tracking:")
+		f.SetCellValue(sheetName, "B9", strings.Join(numbers, ", "))
+		if len(numbers) == 1 && shipment.Packages[0].TrackingURL != "" {
+			f.SetCellHyperLink(sheetName, "B9", shipment.Packages[0].TrackingURL, "External")
+		}
+	}
 
 	// --- Table Header for Shipment Items ---
 	tableHeaderRow := 10
@@ -256,6 +298,13 @@ func (s *ApiServer) handleDownloadShipmentExcel(w http.ResponseWriter, r *http.R
 		currentRow++
 	}
 
+	if shipment.ShippingCost > 0 {
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", currentRow), "Shipping")
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", currentRow), shipment.ShippingCost)
+		grandTotal += shipment.ShippingCost
+		currentRow++
+	}
+
 	f.SetCellValue(sheetName, fmt.Sprintf("D%d", currentRow), "Grand Total")
 	f.SetCellValue(sheetName, fmt.Sprintf("E%d", currentRow), grandTotal)
 
@@ -263,7 +312,13 @@ func (s *ApiServer) handleDownloadShipmentExcel(w http.ResponseWriter, r *http.R
 	w.Header().Set("Content-Disposition", "attachment; filename=shipment.xlsx")
 
 	if err := f.Write(w); err != nil {
-		http.Error(w, fmt.Sprintf("Error writing excel file: %v", err), http.StatusInternalServerError)
+		writeStoreError(w, err, "Error writing excel file")
 		return
 	}
 }
+
+// handleGetCarriers lists the known carrier codes and their tracking links
+func (s *ApiServer) handleGetCarriers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(carriers.All())
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"AAHAOMS/OMS/carriers"
+	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/storage"
+	"AAHAOMS/OMS/webhooks"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// carrierWebhookTolerance is how old a signed carrier webhook may be
+const carrierWebhookTolerance = 5 * time.Minute
+
+// recordTrackingEvent stores an event and publishes the shipment's new
+// status, and the order's when the event changed it (e.g. to delivered)
+func (s *ApiServer) recordTrackingEvent(event models.TrackingEvent) (int, error) {
+	shipment, err := s.Store.GetShipmentByID(event.ShipmentID)
+	if err != nil {
+		return 0, err
+	}
+	before, err := s.Store.GetOrderByID(shipment.OrderID)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := s.Store.AddTrackingEvent(event)
+	if err != nil {
+		return 0, err
+	}
+
+	if updated, err := s.Store.GetShipmentByID(event.ShipmentID); err == nil && updated.Status != shipment.Status {
+		s.publishEvent(webhooks.ShipmentStatusUpdated, map[string]any{
+			"shipment_id":    updated.ID,
+			"order_id":       updated.OrderID,
+			"status":         updated.Status,
+			"delivered_date": updated.DeliveredDate,
+		})
+	}
+	if after, err := s.Store.GetOrderByID(shipment.OrderID); err == nil && after.OrderStatus != before.OrderStatus {
+		s.publishEvent(webhooks.OrderStatusUpdated, map[string]any{"order_id": after.ID, "status": after.OrderStatus})
+	}
+	return id, nil
+}
+
+func (s *ApiServer) handleGetTrackingEvents(w http.ResponseWriter, r *http.Request) {
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid shipment ID, must be an integer")
+		return
+	}
+	if _, err := s.Store.GetShipmentByID(shipmentID); err != nil {
+		writeStoreError(w, err, "Error fetching shipment")
+		return
+	}
+
+	events, err := s.Store.GetTrackingEvents(shipmentID)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching tracking events")
+		return
+	}
+	json.NewEncoder(w).Encode(events)
+}
+
+// handleAddTrackingEvent records an event entered by hand, e.g. a delivery
+// confirmed by phone
+func (s *ApiServer) handleAddTrackingEvent(w http.ResponseWriter, r *http.Request) {
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid shipment ID, must be an integer")
+		return
+	}
+
+	var event models.TrackingEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, event) {
+		return
+	}
+	event.ShipmentID = shipmentID
+	event.Source = models.TrackingManual
+
+	if event.ID, err = s.recordTrackingEvent(event); err != nil {
+		writeStoreError(w, err, "Error recording tracking event")
+		return
+	}
+	s.audit(r, "create", "tracking_event", event.ID, nil, event)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"status": "tracking event recorded", "event_id": event.ID})
+}
+
+// handleCarrierTrackingWebhook takes a batch of tracking updates from a
+// carrier. Requests are signed like our outgoing webhooks, with
+// CarrierWebhookSecret. Events already recorded are counted as duplicates
+// and tracking numbers we don't know are listed back, so a carrier can
+// resend a batch safely.
+func (s *ApiServer) handleCarrierTrackingWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	code := mux.Vars(r)["code"]
+	if _, ok := carriers.Lookup(code); !ok {
+		writeError(w, http.StatusNotFound, codeNotFound, "Unknown carrier "+code, nil)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+	if s.CarrierWebhookSecret == "" {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "Carrier webhooks are not configured", nil)
+		return
+	}
+	err = webhooks.Verify(s.CarrierWebhookSecret, r.Header.Get(webhooks.HeaderSignature), r.Header.Get(webhooks.HeaderTimestamp), body, carrierWebhookTolerance)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, err.Error(), nil)
+		return
+	}
+	// The signature vouches for the carrier, so events are audited as theirs
+	r = withActor(r, "carrier:"+code)
+
+	var payload models.CarrierTrackingWebhook
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&payload); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, payload) {
+		return
+	}
+
+	result := struct {
+		Recorded   int      `json:"recorded"`
+		Duplicates int      `json:"duplicates"`
+		Unknown    []string `json:"unknown_tracking_numbers"`
+	}{Unknown: []string{}}
+
+	for _, u := range payload.Events {
+		shipmentID, err := s.Store.FindShipmentByTrackingNumber(u.TrackingNumber)
+		if errors.Is(err, storage.ErrNotFound) {
+			result.Unknown = append(result.Unknown, u.TrackingNumber)
+			continue
+		}
+		if err != nil {
+			writeStoreError(w, err, "Error finding shipment")
+			return
+		}
+
+		status, _ := carriers.ShipmentStatus(u.Status)
+		event := models.TrackingEvent{
+			ShipmentID:     shipmentID,
+			TrackingNumber: u.TrackingNumber,
+			Status:         status,
+			Description:    u.Description,
+			Location:       u.Location,
+			OccurredAt:     u.OccurredAt,
+			Source:         code,
+		}
+		event.ID, err = s.recordTrackingEvent(event)
+		switch {
+		case errors.Is(err, storage.ErrConflict):
+			result.Duplicates++
+		case err != nil:
+			log.Printf("Error recording %s tracking event for %s: %v", code, u.TrackingNumber, err)
+			writeStoreError(w, err, "Error recording tracking event")
+			return
+		default:
+			s.audit(r, "create", "tracking_event", event.ID, nil, event)
+			result.Recorded++
+		}
+	}
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/webhooks"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// publishEvent queues a webhook event for all matching subscriptions.
+// Failures are only logged because the change behind the event is already
+// committed by the time it is published.
+func (s *ApiServer) publishEvent(eventType string, data any) {
+	if err := s.publishEventWithID(webhooks.NewEventID(), eventType, data); err != nil {
+		log.Printf("Error queueing %s webhook event: %v", eventType, err)
+	}
+}
+
+// publishEventWithID queues an event under a fixed ID, so publishing it
+// again (e.g. from a retried job) does not deliver it twice.
+func (s *ApiServer) publishEventWithID(eventID, eventType string, data any) error {
+	body, err := webhooks.NewEnvelope(eventID, eventType, data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %v", eventType, err)
+	}
+	_, err = s.Store.EnqueueWebhookEvent(eventID, eventType, body)
+	return err
+}
+
+func (s *ApiServer) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var sub models.WebhookSubscription
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+
+	errs := models.Validate(sub)
+	for i, e := range sub.EventTypes {
+		if !webhooks.IsKnownEvent(e) {
+			errs.Add(fmt.Sprintf("event_types[%d]", i), "unknown event type %q", e)
+		}
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+	if sub.Secret == "" {
+		sub.Secret = generateUniqueKey()
+	}
+
+	id, err := s.Store.CreateWebhookSubscription(sub)
+	if err != nil {
+		writeStoreError(w, err, "Error creating webhook")
+		return
+	}
+
+	sub.ID = id
+	sub.Active = true
+	s.audit(r, "create", "webhook", id, nil, models.WebhookSubscription{ID: id, URL: sub.URL, EventTypes: sub.EventTypes, Active: true})
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sub)
+}
+
+func (s *ApiServer) handleGetWebhooks(w http.ResponseWriter, r *http.Request) {
+	subs, err := s.Store.GetWebhookSubscriptions()
+	if err != nil {
+		writeStoreError(w, err, "Error fetching webhooks")
+		return
+	}
+	json.NewEncoder(w).Encode(subs)
+}
+
+// findWebhook returns the subscription with id for audit snapshots, or nil
+func (s *ApiServer) findWebhook(id int) *models.WebhookSubscription {
+	subs, err := s.Store.GetWebhookSubscriptions()
+	if err != nil {
+		return nil
+	}
+	for i := range subs {
+		if subs[i].ID == id {
+			return &subs[i]
+		}
+	}
+	return nil
+}
+
+// handleUpdateWebhook pauses or resumes a subscription with {"active": bool}
+func (s *ApiServer) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid webhook ID")
+		return
+	}
+	var patch models.WebhookSubscriptionPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		writeInvalidPayload(w)
+		return
+	}
+	if !validateRequest(w, patch) {
+		return
+	}
+
+	before := s.findWebhook(id)
+	if err := s.Store.SetWebhookSubscriptionActive(id, *patch.Active); err != nil {
+		writeStoreError(w, err, "Error updating webhook")
+		return
+	}
+	after := s.findWebhook(id)
+	s.audit(r, "update", "webhook", id, before, after)
+	json.NewEncoder(w).Encode(after)
+}
+
+func (s *ApiServer) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid webhook ID")
+		return
+	}
+
+	before := s.findWebhook(id)
+
+	if err := s.Store.DeleteWebhookSubscription(id); err != nil {
+		writeStoreError(w, err, "Error deleting webhook")
+		return
+	}
+	s.audit(r, "delete", "webhook", id, before, nil)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Webhook deleted successfully"})
+}
+
+func (s *ApiServer) handleGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid webhook ID")
+		return
+	}
+
+	limit := 100
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 1000 {
+		limit = l
+	}
+
+	deliveries, err := s.Store.GetWebhookDeliveries(id, limit)
+	if err != nil {
+		writeStoreError(w, err, "Error fetching deliveries")
+		return
+	}
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+func (s *ApiServer) handleReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeBadRequest(w, "Invalid delivery ID")
+		return
+	}
+
+	newID, err := s.Store.ReplayWebhookDelivery(id)
+	if err != nil {
+		writeStoreError(w, err, "Error replaying delivery")
+		return
+	}
+	s.audit(r, "replay", "webhook_delivery", id, nil, map[string]int{"delivery_id": newID, "replay_of": id})
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]int{"delivery_id": newID})
+}
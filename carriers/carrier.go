@@ -0,0 +1,155 @@
+package carriers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"AAHAOMS/OMS/models"
+)
+
+var (
+	// ErrUnknownCarrier is returned for a carrier code with no integration
+	ErrUnknownCarrier = errors.New("unknown carrier")
+	// ErrInvalidRequest means the carrier rejected what it was asked to do
+	ErrInvalidRequest = errors.New("invalid carrier request")
+	// ErrNotFound means the carrier has no record of a tracking number
+	ErrNotFound = errors.New("not found at carrier")
+)
+
+// Carrier is an integration with a shipping company's API
+type Carrier interface {
+	// Code matches the Info code the carrier is registered under
+	Code() string
+	Rates(ctx context.Context, req RateRequest) ([]Rate, error)
+	CreateLabel(ctx context.Context, req LabelRequest) (*Label, error)
+	Track(ctx context.Context, trackingNumber string) ([]TrackingEvent, error)
+	Cancel(ctx context.Context, trackingNumber string) error
+}
+
+type Address struct {
+	Name     string `json:"name"`
+	Line1    string `json:"line1"`
+	Line2    string `json:"line2,omitempty"`
+	City     string `json:"city"`
+	Region   string `json:"region,omitempty"`
+	Postcode string `json:"postcode,omitempty"`
+	Country  string `json:"country"`
+	Phone    string `json:"phone,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+type Parcel struct {
+	WeightKg float64 `json:"weight_kg"`
+	LengthCm float64 `json:"length_cm"`
+	WidthCm  float64 `json:"width_cm"`
+	HeightCm float64 `json:"height_cm"`
+}
+
+type RateRequest struct {
+	From    Address  `json:"from"`
+	To      Address  `json:"to"`
+	Parcels []Parcel `json:"parcels"`
+}
+
+// Rate is a quote for one service level
+type Rate struct {
+	Carrier       string  `json:"carrier"`
+	ServiceLevel  string  `json:"service_level"`
+	Amount        float64 `json:"amount"`
+	Currency      string  `json:"currency"`
+	EstimatedDays int     `json:"estimated_days"`
+}
+
+// LabelRequest books a service level for the parcels. Reference is printed
+// on the label, e.g. the order number.
+type LabelRequest struct {
+	RateRequest
+	ServiceLevel string `json:"service_level"`
+	Reference    string `json:"reference"`
+}
+
+// Label is a booked shipment: one tracking number per parcel, in order,
+// and the printable label file.
+type Label struct {
+	TrackingNumbers []string `json:"tracking_numbers"`
+	Format          string   `json:"format"`
+	Data            []byte   `json:"-"`
+	Amount          float64  `json:"amount"`
+	Currency        string   `json:"currency"`
+}
+
+// Statuses a carrier reports on a TrackingEvent. They are finer grained
+// than a shipment's; ShipmentStatus maps one to the other.
+const (
+	StatusLabelCreated   = "label_created"
+	StatusPickedUp       = "picked_up"
+	StatusInTransit      = "in_transit"
+	StatusOutForDelivery = "out_for_delivery"
+	StatusDelivered      = "delivered"
+	StatusException      = "exception"
+	StatusCancelled      = "cancelled"
+)
+
+var shipmentStatuses = map[string]string{
+	StatusLabelCreated:     models.ShipmentCreated,
+	models.ShipmentCreated: models.ShipmentCreated,
+	StatusPickedUp:         models.ShipmentPickedUp,
+	StatusInTransit:        models.ShipmentInTransit,
+	StatusOutForDelivery:   models.ShipmentInTransit,
+	StatusDelivered:        models.ShipmentDelivered,
+	StatusException:        models.ShipmentException,
+	StatusCancelled:        models.ShipmentException,
+}
+
+// ShipmentStatus maps a carrier tracking status to the shipment status it
+// is recorded as. Shipment statuses map to themselves; ok is false for a
+// status the carrier vocabulary doesn't have.
+func ShipmentStatus(status string) (shipmentStatus string, ok bool) {
+	shipmentStatus, ok = shipmentStatuses[status]
+	return shipmentStatus, ok
+}
+
+// TrackingEvent is one scan or status change reported by a carrier
+type TrackingEvent struct {
+	// Status is one of the Status constants
+	Status      string    `json:"status"`
+	Description string    `json:"description"`
+	Location    string    `json:"location,omitempty"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+var (
+	integrationsMu sync.RWMutex
+	integrations   = map[string]Carrier{}
+)
+
+// RegisterCarrier makes an integration available under its code
+func RegisterCarrier(c Carrier) {
+	integrationsMu.Lock()
+	defer integrationsMu.Unlock()
+	integrations[normalize(c.Code())] = c
+}
+
+// Get returns the integration for a carrier code
+func Get(code string) (Carrier, error) {
+	integrationsMu.RLock()
+	defer integrationsMu.RUnlock()
+	c, ok := integrations[normalize(code)]
+	if !ok {
+		return nil, ErrUnknownCarrier
+	}
+	return c, nil
+}
+
+// Integrations lists the registered integrations
+func Integrations() []Carrier {
+	integrationsMu.RLock()
+	defer integrationsMu.RUnlock()
+	list := make([]Carrier, 0, len(integrations))
+	for _, c := range integrations {
+		list = append(list, c)
+	}
+	return list
+}
@@ -0,0 +1,85 @@
+package carriers
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Info describes a shipping company. TrackingURL is a template where
+// {tracking} is replaced with the escaped tracking number.
+type Info struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	TrackingURL string `json:"tracking_url"`
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Info{}
+)
+
+func init() {
+	for _, c := range []Info{
+		{Code: "dhl", Name: "DHL Express", TrackingURL: "https://www.dhl.com/global-en/home/tracking/tracking-express.html?tracking-id={tracking}"},
+		{Code: "fedex", Name: "FedEx", TrackingURL: "https://www.fedex.com/fedextrack/?trknbr={tracking}"},
+		{Code: "ups", Name: "UPS", TrackingURL: "https://www.ups.com/track?tracknum={tracking}"},
+		{Code: "usps", Name: "USPS", TrackingURL: "https://tools.usps.com/go/TrackConfirmAction?tLabels={tracking}"},
+		{Code: "aramex", Name: "Aramex", TrackingURL: "https://www.aramex.com/track/results?ShipmentNumber={tracking}"},
+		{Code: "nepal_post", Name: "Nepal Post", TrackingURL: "https://www.17track.net/en/track?nums={tracking}"},
+	} {
+		Register(c)
+	}
+}
+
+// Register adds or replaces a carrier, e.g. a local courier
+func Register(c Info) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[normalize(c.Code)] = c
+}
+
+// Lookup finds a carrier by code, ignoring case
+func Lookup(code string) (Info, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := registry[normalize(code)]
+	return c, ok
+}
+
+// All lists the registered carriers by code
+func All() []Info {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]Info, 0, len(registry))
+	for _, c := range registry {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// Name returns the carrier's display name, or code itself when the carrier
+// isn't registered
+func Name(code string) string {
+	if c, ok := Lookup(code); ok {
+		return c.Name
+	}
+	return code
+}
+
+// TrackingURL builds the tracking link for a parcel. It is empty when the
+// carrier is unknown or has no template, or there is no tracking number.
+func TrackingURL(code, trackingNumber string) string {
+	trackingNumber = strings.TrimSpace(trackingNumber)
+	c, ok := Lookup(code)
+	if !ok || c.TrackingURL == "" || trackingNumber == "" {
+		return ""
+	}
+	return strings.ReplaceAll(c.TrackingURL, "{tracking}", url.QueryEscape(trackingNumber))
+}
+
+func normalize(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
@@ -0,0 +1,235 @@
+// Package mock is a carrier integration that never leaves the process.
+// Rates, tracking history and the label file come from the embedded
+// fixtures, so local development and tests can book and track shipments
+// without courier credentials.
+package mock
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"AAHAOMS/OMS/carriers"
+)
+
+// Code is the carrier code the mock registers under
+const Code = "mock"
+
+//go:embed fixtures/*
+var fixtureFS embed.FS
+
+type rateTable struct {
+	Currency          string             `json:"currency"`
+	VolumetricDivisor float64            `json:"volumetric_divisor"`
+	CountrySurcharges map[string]float64 `json:"country_surcharges"`
+	Services          []struct {
+		ServiceLevel  string  `json:"service_level"`
+		Base          float64 `json:"base"`
+		PerKg         float64 `json:"per_kg"`
+		EstimatedDays int     `json:"estimated_days"`
+	} `json:"services"`
+}
+
+type trackingStep struct {
+	AfterHours  int    `json:"after_hours"`
+	Status      string `json:"status"`
+	Description string `json:"description"`
+	Location    string `json:"location"`
+}
+
+// Carrier is the mock integration. Tracking numbers encode when the label
+// was created, so tracking works across restarts without any state.
+type Carrier struct {
+	// Now is the clock used for labels and tracking; tests may replace it
+	Now func() time.Time
+
+	rates    rateTable
+	tracking []trackingStep
+	label    []byte
+
+	mu        sync.Mutex
+	seq       int
+	cancelled map[string]bool
+}
+
+// New loads the fixtures
+func New() (*Carrier, error) {
+	c := &Carrier{Now: time.Now, cancelled: map[string]bool{}}
+	if err := readFixture("fixtures/rates.json", &c.rates); err != nil {
+		return nil, err
+	}
+	if err := readFixture("fixtures/tracking.json", &c.tracking); err != nil {
+		return nil, err
+	}
+	for _, step := range c.tracking {
+		if _, ok := carriers.ShipmentStatus(step.Status); !ok {
+			return nil, fmt.Errorf("fixtures/tracking.json: unknown status %q", step.Status)
+		}
+	}
+	label, err := fixtureFS.ReadFile("fixtures/label.pdf")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mock label: %v", err)
+	}
+	c.label = label
+	return c, nil
+}
+
+// Register adds the mock to the carriers registry
+func Register() error {
+	c, err := New()
+	if err != nil {
+		return err
+	}
+	carriers.Register(carriers.Info{Code: Code, Name: "Mock Carrier"})
+	carriers.RegisterCarrier(c)
+	return nil
+}
+
+func readFixture(name string, v any) error {
+	data, err := fixtureFS.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", name, err)
+	}
+	return nil
+}
+
+func (c *Carrier) Code() string { return Code }
+
+// Rates prices each service on chargeable weight, the greater of actual and
+// volumetric weight, plus any surcharge for the destination country.
+func (c *Carrier) Rates(ctx context.Context, req carriers.RateRequest) ([]carriers.Rate, error) {
+	weight, err := c.chargeableWeight(req)
+	if err != nil {
+		return nil, err
+	}
+	surcharge := c.rates.CountrySurcharges[strings.ToUpper(req.To.Country)]
+
+	rates := make([]carriers.Rate, 0, len(c.rates.Services))
+	for _, s := range c.rates.Services {
+		rates = append(rates, carriers.Rate{
+			Carrier:       Code,
+			ServiceLevel:  s.ServiceLevel,
+			Amount:        math.Round((s.Base+s.PerKg*weight+surcharge)*100) / 100,
+			Currency:      c.rates.Currency,
+			EstimatedDays: s.EstimatedDays,
+		})
+	}
+	return rates, nil
+}
+
+func (c *Carrier) chargeableWeight(req carriers.RateRequest) (float64, error) {
+	if strings.TrimSpace(req.To.Country) == "" {
+		return 0, fmt.Errorf("destination country is required: %w", carriers.ErrInvalidRequest)
+	}
+	if len(req.Parcels) == 0 {
+		return 0, fmt.Errorf("at least one parcel is required: %w", carriers.ErrInvalidRequest)
+	}
+
+	var total float64
+	for i, p := range req.Parcels {
+		if p.WeightKg <= 0 {
+			return 0, fmt.Errorf("parcel %d has no weight: %w", i+1, carriers.ErrInvalidRequest)
+		}
+		volumetric := p.LengthCm * p.WidthCm * p.HeightCm / c.rates.VolumetricDivisor
+		total += math.Max(p.WeightKg, volumetric)
+	}
+	return total, nil
+}
+
+// CreateLabel books the service level and returns the fixture label with a
+// tracking number per parcel
+func (c *Carrier) CreateLabel(ctx context.Context, req carriers.LabelRequest) (*carriers.Label, error) {
+	rates, err := c.Rates(ctx, req.RateRequest)
+	if err != nil {
+		return nil, err
+	}
+	var rate *carriers.Rate
+	for i := range rates {
+		if rates[i].ServiceLevel == req.ServiceLevel {
+			rate = &rates[i]
+		}
+	}
+	if rate == nil {
+		return nil, fmt.Errorf("unknown service level %q: %w", req.ServiceLevel, carriers.ErrInvalidRequest)
+	}
+
+	c.mu.Lock()
+	c.seq = (c.seq + 1) % 100
+	seq := c.seq
+	c.mu.Unlock()
+
+	created := c.Now().Unix()
+	label := &carriers.Label{Format: "pdf", Data: c.label, Amount: rate.Amount, Currency: rate.Currency}
+	for i := range req.Parcels {
+		label.TrackingNumbers = append(label.TrackingNumbers, fmt.Sprintf("MK%d%02d%02d", created, seq, (i+1)%100))
+	}
+	return label, nil
+}
+
+// Track replays the fixture history from the label's creation time up to now
+func (c *Carrier) Track(ctx context.Context, trackingNumber string) ([]carriers.TrackingEvent, error) {
+	created, err := parseTrackingNumber(trackingNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	cancelled := c.cancelled[trackingNumber]
+	c.mu.Unlock()
+
+	now := c.Now()
+	var events []carriers.TrackingEvent
+	for _, step := range c.tracking {
+		at := created.Add(time.Duration(step.AfterHours) * time.Hour)
+		if at.After(now) || (cancelled && step.AfterHours > 0) {
+			break
+		}
+		events = append(events, carriers.TrackingEvent{
+			Status:      step.Status,
+			Description: step.Description,
+			Location:    step.Location,
+			OccurredAt:  at,
+		})
+	}
+	if cancelled {
+		events = append(events, carriers.TrackingEvent{Status: carriers.StatusCancelled, Description: "Label cancelled", OccurredAt: created})
+	}
+	return events, nil
+}
+
+// Cancel voids a label that has not been picked up yet
+func (c *Carrier) Cancel(ctx context.Context, trackingNumber string) error {
+	events, err := c.Track(ctx, trackingNumber)
+	if err != nil {
+		return err
+	}
+	if len(events) > 1 {
+		return fmt.Errorf("%s is already %s: %w", trackingNumber, events[len(events)-1].Status, carriers.ErrInvalidRequest)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cancelled[trackingNumber] = true
+	return nil
+}
+
+// parseTrackingNumber recovers the creation time from MK<unix><seq><parcel>
+func parseTrackingNumber(n string) (time.Time, error) {
+	if !strings.HasPrefix(n, "MK") || len(n) < 7 {
+		return time.Time{}, fmt.Errorf("tracking number %q: %w", n, carriers.ErrNotFound)
+	}
+	unix, err := strconv.ParseInt(n[2:len(n)-4], 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("tracking number %q: %w", n, carriers.ErrNotFound)
+	}
+	return time.Unix(unix, 0), nil
+}
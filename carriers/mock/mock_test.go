@@ -0,0 +1,166 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"AAHAOMS/OMS/carriers"
+)
+
+func newTestCarrier(t *testing.T, now time.Time) *Carrier {
+	t.Helper()
+	c, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Now = func() time.Time { return now }
+	return c
+}
+
+func rateRequest(country string, parcels ...carriers.Parcel) carriers.RateRequest {
+	return carriers.RateRequest{To: carriers.Address{Country: country}, Parcels: parcels}
+}
+
+func TestRatesUseChargeableWeightAndSurcharge(t *testing.T) {
+	c := newTestCarrier(t, time.Now())
+
+	// 50x40x30 cm is 12 kg volumetric, more than its 2 kg; the small box
+	// is charged its actual 3 kg. 15 kg in all.
+	parcels := []carriers.Parcel{
+		{WeightKg: 2, LengthCm: 50, WidthCm: 40, HeightCm: 30},
+		{WeightKg: 3, LengthCm: 10, WidthCm: 10, HeightCm: 10},
+	}
+	for _, tc := range []struct {
+		country string
+		want    map[string]float64
+	}{
+		{"us", map[string]float64{"economy": 77.5, "standard": 116.75, "express": 172.5}},
+		{"NP", map[string]float64{"economy": 72.5, "standard": 111.75, "express": 167.5}},
+	} {
+		rates, err := c.Rates(context.Background(), rateRequest(tc.country, parcels...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rates) != len(tc.want) {
+			t.Fatalf("%s: got %d rates, want %d", tc.country, len(rates), len(tc.want))
+		}
+		for _, r := range rates {
+			if want := tc.want[r.ServiceLevel]; math.Abs(r.Amount-want) > 0.001 {
+				t.Errorf("%s %s: got %.2f, want %.2f", tc.country, r.ServiceLevel, r.Amount, want)
+			}
+			if r.Carrier != Code || r.Currency != "USD" {
+				t.Errorf("got %+v", r)
+			}
+		}
+	}
+}
+
+func TestRatesRejectIncompleteRequests(t *testing.T) {
+	c := newTestCarrier(t, time.Now())
+	for name, req := range map[string]carriers.RateRequest{
+		"no country": rateRequest("", carriers.Parcel{WeightKg: 1}),
+		"no parcels": rateRequest("US"),
+		"no weight":  rateRequest("US", carriers.Parcel{WeightKg: 1}, carriers.Parcel{LengthCm: 10}),
+	} {
+		if _, err := c.Rates(context.Background(), req); !errors.Is(err, carriers.ErrInvalidRequest) {
+			t.Errorf("%s: got %v, want ErrInvalidRequest", name, err)
+		}
+	}
+}
+
+func TestTrackingNumbersEncodeCreationTime(t *testing.T) {
+	created := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+	c := newTestCarrier(t, created)
+
+	label, err := c.CreateLabel(context.Background(), carriers.LabelRequest{
+		RateRequest:  rateRequest("GB", carriers.Parcel{WeightKg: 1}, carriers.Parcel{WeightKg: 2}),
+		ServiceLevel: "standard",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(label.TrackingNumbers) != 2 || label.TrackingNumbers[0] == label.TrackingNumbers[1] {
+		t.Fatalf("got tracking numbers %v, want two distinct", label.TrackingNumbers)
+	}
+	for _, n := range label.TrackingNumbers {
+		at, err := parseTrackingNumber(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !at.Equal(created) {
+			t.Errorf("%s: got %v, want %v", n, at, created)
+		}
+	}
+
+	for _, n := range []string{"", "MK12", "XX17608662000101", "MKsoon0101"} {
+		if _, err := parseTrackingNumber(n); !errors.Is(err, carriers.ErrNotFound) {
+			t.Errorf("%q: got %v, want ErrNotFound", n, err)
+		}
+	}
+}
+
+func TestTrackReplaysFixtureUpToNow(t *testing.T) {
+	created := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	c := newTestCarrier(t, created)
+	label, err := c.CreateLabel(context.Background(), carriers.LabelRequest{
+		RateRequest:  rateRequest("NP", carriers.Parcel{WeightKg: 1}),
+		ServiceLevel: "economy",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Now = func() time.Time { return created.Add(31 * time.Hour) }
+	events, err := c.Track(context.Background(), label.TrackingNumbers[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{carriers.StatusLabelCreated, carriers.StatusPickedUp, carriers.StatusInTransit}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i, e := range events {
+		if e.Status != want[i] {
+			t.Errorf("event %d: got %s, want %s", i, e.Status, want[i])
+		}
+		if _, ok := carriers.ShipmentStatus(e.Status); !ok {
+			t.Errorf("event %d: status %s has no shipment status", i, e.Status)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	created := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	c := newTestCarrier(t, created)
+	label, err := c.CreateLabel(context.Background(), carriers.LabelRequest{
+		RateRequest:  rateRequest("AU", carriers.Parcel{WeightKg: 1}, carriers.Parcel{WeightKg: 1}),
+		ServiceLevel: "express",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := label.TrackingNumbers[0], label.TrackingNumbers[1]
+
+	// Before pickup the label can be voided, and stays voided
+	c.Now = func() time.Time { return created.Add(time.Hour) }
+	if err := c.Cancel(context.Background(), first); err != nil {
+		t.Fatal(err)
+	}
+	c.Now = func() time.Time { return created.Add(100 * time.Hour) }
+	events, err := c.Track(context.Background(), first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[1].Status != carriers.StatusCancelled {
+		t.Errorf("got %+v, want label_created then cancelled", events)
+	}
+
+	// Once picked up it can't be
+	c.Now = func() time.Time { return created.Add(7 * time.Hour) }
+	if err := c.Cancel(context.Background(), second); !errors.Is(err, carriers.ErrInvalidRequest) {
+		t.Errorf("got %v, want ErrInvalidRequest", err)
+	}
+}
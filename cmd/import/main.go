@@ -0,0 +1,85 @@
+// Command import loads customers or orders from a CSV or .xlsx file using
+// the same rules as the /import endpoints. It reads the POSTGRES_* settings
+// like the server does.
+//
+//	go run ./cmd/import [-dry-run] [-on-duplicate error|skip|update] customers customers.xlsx
+//	go run ./cmd/import -dry-run orders orders.csv
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"AAHAOMS/OMS/importer"
+	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/storage"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "check the file and report what would happen without saving")
+	onDuplicate := flag.String("on-duplicate", models.DuplicateError, "what to do with existing records: error, skip or update")
+	format := flag.String("format", "", "csv or xlsx, by default taken from the file extension")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: import [flags] customers|orders <file>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	switch *onDuplicate {
+	case models.DuplicateError, models.DuplicateSkip, models.DuplicateUpdate:
+	default:
+		fmt.Fprintln(os.Stderr, "-on-duplicate must be one of: error, skip, update")
+		os.Exit(2)
+	}
+	kind, path := flag.Arg(0), flag.Arg(1)
+	if *format == "" {
+		*format = importer.FormatFromName(path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open file:", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	store, err := storage.NewPostgresStorage()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to initialize storage:", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	if err := store.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to initialize database:", err)
+		os.Exit(1)
+	}
+
+	opts := models.ImportOptions{DryRun: *dryRun, OnDuplicate: *onDuplicate}
+	var result models.ImportResult
+	switch kind {
+	case "customers":
+		result, err = importer.Customers(store, file, *format, opts)
+	case "orders":
+		result, err = importer.Orders(store, file, *format, opts)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Import failed:", err)
+		os.Exit(1)
+	}
+
+	out, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(out))
+	if len(result.Errors) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,55 @@
+// Command webhook-receiver is a local HTTP endpoint for trying out webhook
+// subscriptions. It verifies each delivery's signature and prints the event.
+//
+//	go run ./cmd/webhook-receiver -addr :9090 -secret <subscription secret>
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"AAHAOMS/OMS/webhooks"
+)
+
+func main() {
+	addr := flag.String("addr", ":9090", "address to listen on")
+	secret := flag.String("secret", "", "subscription secret used to verify signatures")
+	status := flag.Int("status", http.StatusOK, "status code to respond with, to exercise retries")
+	flag.Parse()
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		verified := "not checked"
+		if *secret != "" {
+			err := webhooks.Verify(*secret, r.Header.Get(webhooks.HeaderSignature), r.Header.Get(webhooks.HeaderTimestamp), body, 5*time.Minute)
+			if err != nil {
+				log.Printf("rejected delivery %s: %v", r.Header.Get(webhooks.HeaderDelivery), err)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			verified = "ok"
+		}
+
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, body, "", "  "); err != nil {
+			pretty.Write(body)
+		}
+		fmt.Printf("--- %s delivery=%s signature=%s\n%s\n", r.Header.Get(webhooks.HeaderEvent), r.Header.Get(webhooks.HeaderDelivery), verified, pretty.String())
+
+		w.WriteHeader(*status)
+	})
+
+	log.Printf("Webhook receiver listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
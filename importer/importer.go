@@ -0,0 +1,158 @@
+package importer
+
+import (
+	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/storage"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Customers reads a customer file and imports it. Columns: name, number
+// (or phone), email, country, address.
+func Customers(store storage.Storage, r io.Reader, format string, opts models.ImportOptions) (models.ImportResult, error) {
+	result := models.ImportResult{Kind: "customers", DryRun: opts.DryRun, Errors: []models.ImportError{}}
+
+	rows, err := ReadTable(r, format)
+	if err != nil {
+		return result, err
+	}
+
+	seen := make(map[string]int)
+	var customers []models.CustomerImport
+	for _, row := range rows {
+		c := models.Customer{
+			Name:    row.Get("name", "customer", "customer_name"),
+			Number:  row.Get("number", "phone"),
+			Email:   row.Get("email"),
+			Country: row.Get("country"),
+			Address: row.Get("address"),
+		}
+		// Files often spell countries out; store them as codes
+		if code, ok := models.NormalizeCountry(c.Country); ok {
+			c.Country = code
+		}
+		for _, e := range models.Validate(c) {
+			result.AddError(row.Line, e.Field, e.Message)
+		}
+
+		key := strings.ToLower(c.Name)
+		if first, ok := seen[key]; ok && key != "" {
+			result.AddError(row.Line, "name", fmt.Sprintf("duplicates row %d", first))
+			continue
+		}
+		seen[key] = row.Line
+		customers = append(customers, models.CustomerImport{Row: row.Line, Customer: c})
+	}
+	result.Records = len(customers)
+
+	if len(result.Errors) > 0 {
+		sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Row < result.Errors[j].Row })
+		return result, nil
+	}
+	return store.ImportCustomers(customers, opts)
+}
+
+var itemFieldPattern = regexp.MustCompile(`^items\[(\d+)\]\.(.+)$`)
+
+// Orders reads an order file and imports it. Each row is one line item;
+// rows sharing an order_ref make up one order, whose header columns are
+// taken from its first row. Columns: order_ref, customer_id or customer,
+// order_date, shipment_due, shipment_address, order_status, item_name,
+// size, color, price, quantity. total_price and no_of_items are worked out
+// from the lines.
+func Orders(store storage.Storage, r io.Reader, format string, opts models.ImportOptions) (models.ImportResult, error) {
+	result := models.ImportResult{Kind: "orders", DryRun: opts.DryRun, Errors: []models.ImportError{}}
+
+	rows, err := ReadTable(r, format)
+	if err != nil {
+		return result, err
+	}
+
+	var orders []*models.OrderImport
+	byRef := make(map[string]*models.OrderImport)
+	for _, row := range rows {
+		ref := row.Get("order_ref", "external_ref", "ref")
+		imp, ok := byRef[ref]
+		if !ok || ref == "" {
+			imp = &models.OrderImport{Row: row.Line, CustomerName: row.Get("customer", "customer_name")}
+			imp.Order = models.Order{
+				ExternalRef:     ref,
+				OrderDate:       normalizeDate(row.Get("order_date")),
+				ShipmentDue:     normalizeDate(row.Get("shipment_due")),
+				ShipmentAddress: row.Get("shipment_address"),
+				OrderStatus:     strings.ToLower(row.Get("order_status", "status")),
+			}
+			if raw := row.Get("customer_id"); raw != "" {
+				id, err := strconv.Atoi(raw)
+				if err != nil {
+					result.AddError(row.Line, "customer_id", "must be a whole number")
+				}
+				imp.Order.CustomerID = id
+			} else if imp.CustomerName == "" {
+				result.AddError(row.Line, "customer_id", "customer_id or customer is required")
+			}
+			orders = append(orders, imp)
+			if ref != "" {
+				byRef[ref] = imp
+			}
+		}
+
+		item := models.Item{Name: row.Get("item_name", "item", "name")}
+		if v := row.Get("size"); v != "" {
+			item.Size = &v
+		}
+		if v := row.Get("color", "colour"); v != "" {
+			item.Color = &v
+		}
+		if raw := row.Get("price"); raw != "" {
+			if item.Price, err = strconv.ParseFloat(raw, 64); err != nil {
+				result.AddError(row.Line, "price", "must be a number")
+			}
+		}
+		if raw := row.Get("quantity", "qty"); raw != "" {
+			if item.Quantity, err = strconv.Atoi(raw); err != nil {
+				result.AddError(row.Line, "quantity", "must be a whole number")
+			}
+		}
+		imp.Order.Items = append(imp.Order.Items, item)
+		imp.ItemRows = append(imp.ItemRows, row.Line)
+		imp.Order.TotalPrice += item.Price * float64(item.Quantity)
+		imp.Order.NoOfItems += item.Quantity
+	}
+
+	// Validation only adds problems the parsing above didn't already report
+	reported := make(map[string]bool)
+	for _, e := range result.Errors {
+		reported[fmt.Sprint(e.Row, e.Field)] = true
+	}
+
+	imports := make([]models.OrderImport, 0, len(orders))
+	for _, imp := range orders {
+		for _, e := range models.Validate(imp.Order) {
+			// Orders given by customer name get their ID during the import
+			if e.Field == "customer_id" && imp.CustomerName != "" && imp.Order.CustomerID == 0 {
+				continue
+			}
+			line, field := imp.Row, e.Field
+			if m := itemFieldPattern.FindStringSubmatch(e.Field); m != nil {
+				i, _ := strconv.Atoi(m[1])
+				line, field = imp.ItemRows[i], m[2]
+			}
+			if !reported[fmt.Sprint(line, field)] {
+				result.AddError(line, field, e.Message)
+			}
+		}
+		imports = append(imports, *imp)
+	}
+	result.Records = len(imports)
+
+	if len(result.Errors) > 0 {
+		sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Row < result.Errors[j].Row })
+		return result, nil
+	}
+	return store.ImportOrders(imports, opts)
+}
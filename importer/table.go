@@ -0,0 +1,146 @@
+// Package importer reads customers and orders from CSV or .xlsx files and
+// saves them through storage in a single all-or-nothing transaction.
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// File formats
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Row is one data row keyed by normalized header, e.g. "Item Name" becomes
+// "item_name". Line is the row or line number in the file.
+type Row struct {
+	Line   int
+	Values map[string]string
+}
+
+// Get returns the first non-empty value among the given column names
+func (r Row) Get(names ...string) string {
+	for _, name := range names {
+		if v := strings.TrimSpace(r.Values[name]); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// FormatFromName picks the format from a file name's extension
+func FormatFromName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".xlsx":
+		return FormatXLSX
+	case ".csv", ".txt":
+		return FormatCSV
+	}
+	return ""
+}
+
+// ReadTable reads the header and data rows of a CSV file or the first sheet
+// of a workbook. Blank rows are dropped.
+func ReadTable(r io.Reader, format string) ([]Row, error) {
+	var records [][]string
+	var lines []int
+	switch format {
+	case FormatCSV:
+		// The CSV reader skips blank lines, so keep each record's own line number
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		cr.TrimLeadingSpace = true
+		for {
+			record, err := cr.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid CSV: %v", err)
+			}
+			line, _ := cr.FieldPos(0)
+			records = append(records, record)
+			lines = append(lines, line)
+		}
+	case FormatXLSX:
+		f, err := excelize.OpenReader(r, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return nil, fmt.Errorf("invalid workbook: %v", err)
+		}
+		defer f.Close()
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, fmt.Errorf("workbook has no sheets")
+		}
+		if records, err = f.GetRows(sheets[0]); err != nil {
+			return nil, fmt.Errorf("failed to read sheet %q: %v", sheets[0], err)
+		}
+		for i := range records {
+			lines = append(lines, i+1)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format %q, use csv or xlsx", format)
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+
+	header := make([]string, len(records[0]))
+	for i, h := range records[0] {
+		header[i] = normalizeHeader(h)
+	}
+
+	var rows []Row
+	for i, record := range records[1:] {
+		row := Row{Line: lines[i+1], Values: make(map[string]string, len(header))}
+		blank := true
+		for j, v := range record {
+			if j >= len(header) || header[j] == "" {
+				continue
+			}
+			row.Values[header[j]] = v
+			if strings.TrimSpace(v) != "" {
+				blank = false
+			}
+		}
+		if !blank {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func normalizeHeader(h string) string {
+	h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+	return strings.Join(strings.FieldsFunc(h, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}), "_")
+}
+
+// normalizeDate accepts YYYY-MM-DD, or an Excel date serial number as
+// workbooks store dates, and returns YYYY-MM-DD. Anything else is returned
+// unchanged for validation to reject.
+func normalizeDate(s string) string {
+	if s == "" {
+		return s
+	}
+	if _, err := time.Parse("2006-01-02", s); err == nil {
+		return s
+	}
+	if serial, err := strconv.ParseFloat(s, 64); err == nil && serial > 0 {
+		if t, err := excelize.ExcelDateToTime(serial, false); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+	return s
+}
@@ -0,0 +1,124 @@
+package importer
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestReadTableCSV(t *testing.T) {
+	// Line 3 is empty and line 5 has only separators; both are dropped but
+	// the rows after them keep their own line numbers
+	csv := "\ufeffCustomer Name, E-mail ,Order Date,\n" +
+		"Sita Rai,sita@example.com,2026-10-01,ignored\n" +
+		"\n" +
+		"Ram Thapa,,2026-10-02\n" +
+		" , ,\n" +
+		"Maya Gurung,maya@example.com,2026-10-03\n"
+
+	rows, err := ReadTable(strings.NewReader(csv), FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Row{
+		{Line: 2, Values: map[string]string{"customer_name": "Sita Rai", "e_mail": "sita@example.com", "order_date": "2026-10-01"}},
+		{Line: 4, Values: map[string]string{"customer_name": "Ram Thapa", "e_mail": "", "order_date": "2026-10-02"}},
+		{Line: 6, Values: map[string]string{"customer_name": "Maya Gurung", "e_mail": "maya@example.com", "order_date": "2026-10-03"}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %+v\nwant %+v", rows, want)
+	}
+}
+
+func TestReadTableXLSX(t *testing.T) {
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+	for cell, v := range map[string]any{
+		"A1": "Item Name", "B1": "Qty", "C1": "Order Date",
+		"A2": "Felt slippers", "B2": 2, "C2": time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+		"A4": "Felt ball garland", "B4": 3, "C4": "2026-10-20",
+	} {
+		if err := f.SetCellValue(sheet, cell, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := ReadTable(&buf, FormatXLSX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2: %+v", len(rows), rows)
+	}
+	if rows[0].Line != 2 || rows[1].Line != 4 {
+		t.Errorf("got lines %d and %d, want 2 and 4", rows[0].Line, rows[1].Line)
+	}
+	if got := rows[0].Get("item_name"); got != "Felt slippers" {
+		t.Errorf("item_name %q", got)
+	}
+	// The date cell comes back as its serial number, which normalizeDate reads
+	if got := normalizeDate(rows[0].Get("order_date")); got != "2026-10-19" {
+		t.Errorf("order_date %q normalized to %q", rows[0].Get("order_date"), got)
+	}
+	if got := normalizeDate(rows[1].Get("order_date")); got != "2026-10-20" {
+		t.Errorf("order_date %q", got)
+	}
+}
+
+func TestReadTableErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		input, format string
+	}{
+		"empty":       {"", FormatCSV},
+		"bad quoting": {"a,b\n\"x,y\n", FormatCSV},
+		"not a zip":   {"a,b\n", FormatXLSX},
+		"format":      {"a,b\n", "ods"},
+	} {
+		if _, err := ReadTable(strings.NewReader(tc.input), tc.format); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestNormalizeHeader(t *testing.T) {
+	for in, want := range map[string]string{
+		"Customer Name":    "customer_name",
+		"\ufeffemail":      "email",
+		"  Shipment Due ":  "shipment_due",
+		"Price (USD)":      "price_usd",
+		"Address--Line  2": "address_line_2",
+		"":                 "",
+		"#":                "",
+	} {
+		if got := normalizeHeader(in); got != want {
+			t.Errorf("normalizeHeader(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNormalizeDate(t *testing.T) {
+	for in, want := range map[string]string{
+		"":           "",
+		"2026-10-19": "2026-10-19",
+		"46314":      "2026-10-19",
+		"46314.75":   "2026-10-19",
+		"45000":      "2023-03-15",
+		"0":          "0",
+		"-3":         "-3",
+		"19/10/2026": "19/10/2026",
+		"2026-13-01": "2026-13-01",
+		"soon":       "soon",
+	} {
+		if got := normalizeDate(in); got != want {
+			t.Errorf("normalizeDate(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
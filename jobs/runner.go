@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/storage"
+)
+
+// Handler does the work for one job. Returning an error schedules a retry
+// until the job runs out of attempts, after which it is dead-lettered.
+type Handler func(ctx context.Context, job models.Job) error
+
+// Runner pulls jobs from the Postgres queue with a fixed pool of workers
+type Runner struct {
+	Store        storage.Storage
+	Concurrency  int
+	PollInterval time.Duration
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	// StaleAfter is how long a job may stay running before it is assumed
+	// lost and put back in the queue
+	StaleAfter time.Duration
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// NewRunner creates a runner with the given number of workers
+func NewRunner(store storage.Storage, concurrency int) *Runner {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Runner{
+		Store:        store,
+		Concurrency:  concurrency,
+		PollInterval: 2 * time.Second,
+		BaseBackoff:  10 * time.Second,
+		MaxBackoff:   time.Hour,
+		StaleAfter:   10 * time.Minute,
+		handlers:     make(map[string]Handler),
+	}
+}
+
+// Register sets the handler for a job kind. Jobs of kinds without a handler
+// stay queued, so they can be picked up by a process that knows them.
+func (r *Runner) Register(kind string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[kind] = h
+}
+
+func (r *Runner) kinds() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	kinds := make([]string, 0, len(r.handlers))
+	for k := range r.handlers {
+		kinds = append(kinds, k)
+	}
+	return kinds
+}
+
+func (r *Runner) handler(kind string) (Handler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h, ok := r.handlers[kind]
+	return h, ok
+}
+
+// Run starts the workers and blocks until ctx is cancelled and every
+// in-flight job has finished.
+func (r *Runner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < r.Concurrency; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			r.work(ctx, worker)
+		}(i + 1)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.reap(ctx)
+	}()
+
+	wg.Wait()
+}
+
+func (r *Runner) work(ctx context.Context, worker int) {
+	for {
+		ran, err := r.RunNext(ctx)
+		if err != nil {
+			log.Printf("Job worker %d: %v", worker, err)
+		}
+		if ran {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+// RunNext claims and runs a single job. It reports whether a job was found.
+func (r *Runner) RunNext(ctx context.Context) (bool, error) {
+	job, err := r.Store.ClaimJob(r.kinds())
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	h, ok := r.handler(job.Kind)
+	if !ok {
+		return true, r.Store.FailJob(job.ID, "no handler registered", nil)
+	}
+
+	if err := r.safeRun(ctx, h, *job); err != nil {
+		var retryAt *time.Time
+		if job.Attempts < job.MaxAttempts {
+			next := time.Now().Add(r.backoff(job.Attempts))
+			retryAt = &next
+		}
+		log.Printf("Job %d (%s) attempt %d/%d failed: %v", job.ID, job.Kind, job.Attempts, job.MaxAttempts, err)
+		return true, r.Store.FailJob(job.ID, err.Error(), retryAt)
+	}
+
+	return true, r.Store.CompleteJob(job.ID)
+}
+
+// safeRun turns a panicking handler into an ordinary failure
+func (r *Runner) safeRun(ctx context.Context, h Handler, job models.Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h(ctx, job)
+}
+
+func (r *Runner) reap(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if n, err := r.Store.RequeueStaleJobs(r.StaleAfter); err != nil {
+			log.Printf("Error requeueing stale jobs: %v", err)
+		} else if n > 0 {
+			log.Printf("Requeued %d stale jobs", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) backoff(attempts int) time.Duration {
+	wait := r.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= r.MaxBackoff {
+			return r.MaxBackoff
+		}
+	}
+	return wait
+}
@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Daily enqueues a job of kind once a day, at or after hour local time,
+// until ctx is cancelled. Each job is keyed "<keyPrefix>:<YYYY-MM-DD>" so
+// restarts and other instances don't enqueue the same day twice. The
+// payload gets that date from payload.
+func (r *Runner) Daily(ctx context.Context, kind string, hour int, keyPrefix string, payload func(date string) any) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	var scheduled string
+	for {
+		now := time.Now()
+		if date := now.Format("2006-01-02"); now.Hour() >= hour && date != scheduled {
+			dedupe := fmt.Sprintf("%s:%s", keyPrefix, date)
+			if _, err := r.Store.EnqueueJob(kind, payload(date), dedupe); err != nil {
+				log.Printf("Error scheduling %s job for %s: %v", kind, date, err)
+			} else {
+				scheduled = date
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailer writes every message to Dir as an .eml file, or to the log when
+// Dir is empty. It is meant for local development where no SMTP relay exists.
+type FileMailer struct {
+	Dir  string
+	From string
+
+	seq atomic.Int64
+}
+
+// NewFileMailer creates a mailer that writes messages to dir
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{Dir: dir, From: from}
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	raw := build(m.From, msg)
+
+	if m.Dir == "" {
+		log.Printf("mail to %v:\n%s", msg.To, raw)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail directory: %v", err)
+	}
+
+	name := fmt.Sprintf("%s-%04d.eml", time.Now().Format("20060102-150405"), m.seq.Add(1))
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+
+	log.Printf("mail to %v written to %s", msg.To, path)
+	return nil
+}
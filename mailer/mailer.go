@@ -0,0 +1,80 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Message is a single outgoing email. HTML is optional; when it is set the
+// message is sent as multipart/alternative with Text as the plain part.
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers outgoing email
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// FromEnv builds the mailer selected by MAIL_DRIVER (smtp, file or memory).
+// SMTP is the default so existing deployments keep sending real mail.
+func FromEnv() (Mailer, error) {
+	driver := strings.ToLower(strings.TrimSpace(os.Getenv("MAIL_DRIVER")))
+
+	switch driver {
+	case "", "smtp":
+		return smtpFromEnv()
+	case "file", "log":
+		return NewFileMailer(os.Getenv("MAIL_DIR"), envOr("MAIL_FROM", defaultFrom)), nil
+	case "memory":
+		return NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER %q", driver)
+	}
+}
+
+const (
+	defaultHost = "smtp.mailtrap.io"
+	defaultPort = 587
+	defaultFrom = "no-reply@example.com"
+)
+
+func smtpFromEnv() (*SMTPMailer, error) {
+	port := defaultPort
+	if p := strings.TrimSpace(os.Getenv("SMTP_PORT")); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMTP_PORT %q: %v", p, err)
+		}
+		port = n
+	}
+
+	tlsMode := TLSMode(strings.ToLower(envOr("SMTP_TLS", string(TLSStartTLS))))
+	switch tlsMode {
+	case TLSNone, TLSStartTLS, TLSImplicit:
+	default:
+		return nil, fmt.Errorf("invalid SMTP_TLS %q, expected none, starttls or tls", tlsMode)
+	}
+
+	// MAILTRAP_* are still honoured so older .env files keep working.
+	return &SMTPMailer{
+		Host:     envOr("SMTP_HOST", defaultHost),
+		Port:     port,
+		Username: envOr("SMTP_USER", os.Getenv("MAILTRAP_USER")),
+		Password: envOr("SMTP_PASS", os.Getenv("MAILTRAP_PASS")),
+		From:     envOr("MAIL_FROM", defaultFrom),
+		TLS:      tlsMode,
+	}, nil
+}
+
+func envOr(key, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return fallback
+}
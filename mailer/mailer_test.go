@@ -0,0 +1,171 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func clearMailEnv(t *testing.T) {
+	for _, key := range []string{
+		"MAIL_DRIVER", "MAIL_DIR", "MAIL_FROM",
+		"SMTP_HOST", "SMTP_PORT", "SMTP_USER", "SMTP_PASS", "SMTP_TLS",
+		"MAILTRAP_USER", "MAILTRAP_PASS",
+	} {
+		t.Setenv(key, "")
+	}
+}
+
+func TestFromEnvSMTPDefaults(t *testing.T) {
+	clearMailEnv(t)
+
+	m, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	smtp, ok := m.(*SMTPMailer)
+	if !ok {
+		t.Fatalf("got %T, want *SMTPMailer", m)
+	}
+	if smtp.Host != defaultHost || smtp.Port != defaultPort || smtp.From != defaultFrom || smtp.TLS != TLSStartTLS {
+		t.Errorf("got %+v, want the defaults", smtp)
+	}
+}
+
+func TestFromEnvSMTPConfig(t *testing.T) {
+	clearMailEnv(t)
+	t.Setenv("MAIL_DRIVER", "SMTP")
+	t.Setenv("SMTP_HOST", "mail.example.com")
+	t.Setenv("SMTP_PORT", "465")
+	t.Setenv("SMTP_TLS", "TLS")
+	t.Setenv("MAIL_FROM", "orders@example.com")
+	t.Setenv("MAILTRAP_USER", "legacy-user")
+	t.Setenv("SMTP_PASS", "secret")
+
+	m, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SMTPMailer{
+		Host:     "mail.example.com",
+		Port:     465,
+		Username: "legacy-user",
+		Password: "secret",
+		From:     "orders@example.com",
+		TLS:      TLSImplicit,
+	}
+	if got := *m.(*SMTPMailer); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestFromEnvRejectsBadConfig(t *testing.T) {
+	for name, env := range map[string]map[string]string{
+		"driver": {"MAIL_DRIVER": "pigeon"},
+		"port":   {"SMTP_PORT": "twenty-five"},
+		"tls":    {"SMTP_TLS": "ssl"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clearMailEnv(t)
+			for k, v := range env {
+				t.Setenv(k, v)
+			}
+			if _, err := FromEnv(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestFromEnvOtherDrivers(t *testing.T) {
+	clearMailEnv(t)
+	t.Setenv("MAIL_DRIVER", "file")
+	t.Setenv("MAIL_DIR", "/tmp/mail")
+
+	m, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := m.(*FileMailer); !ok || f.Dir != "/tmp/mail" || f.From != defaultFrom {
+		t.Errorf("got %#v, want a file mailer writing to /tmp/mail", m)
+	}
+
+	t.Setenv("MAIL_DRIVER", "memory")
+	if m, err := FromEnv(); err != nil {
+		t.Fatal(err)
+	} else if _, ok := m.(*MemoryMailer); !ok {
+		t.Errorf("got %T, want *MemoryMailer", m)
+	}
+}
+
+func TestFileMailerWritesEML(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	m := NewFileMailer(dir, "shop@example.com")
+
+	msg := Message{To: []string{"a@example.com", "b@example.com"}, Subject: "Order #7 received", Text: "Thanks", HTML: "<p>Thanks</p>"}
+	if err := m.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Send(Message{To: []string{"a@example.com"}, Subject: "Plain", Text: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %d files, want 2", len(files))
+	}
+	if !strings.HasSuffix(files[0], "-0001.eml") || !strings.HasSuffix(files[1], "-0002.eml") {
+		t.Errorf("files not numbered in order: %v", files)
+	}
+
+	raw, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	eml := string(raw)
+	for _, want := range []string{
+		"From: shop@example.com\r\n",
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: Order #7 received\r\n",
+		"Content-Type: multipart/alternative",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Type: text/html; charset=utf-8",
+		"<p>Thanks</p>",
+	} {
+		if !strings.Contains(eml, want) {
+			t.Errorf("message is missing %q:\n%s", want, eml)
+		}
+	}
+
+	raw, err = os.ReadFile(files[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "multipart") {
+		t.Errorf("a text-only message should not be multipart:\n%s", raw)
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+	m.Send(Message{To: []string{"a@example.com"}, Subject: "one"})
+	m.Send(Message{To: []string{"b@example.com"}, Subject: "two"})
+
+	sent := m.Messages()
+	if len(sent) != 2 || sent[0].Subject != "one" || sent[1].Subject != "two" {
+		t.Fatalf("got %+v", sent)
+	}
+	sent[0].Subject = "changed"
+	if m.Messages()[0].Subject != "one" {
+		t.Error("Messages should return a copy")
+	}
+
+	m.Reset()
+	if n := len(m.Messages()); n != 0 {
+		t.Errorf("got %d messages after Reset, want 0", n)
+	}
+}
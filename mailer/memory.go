@@ -0,0 +1,37 @@
+package mailer
+
+import "sync"
+
+// MemoryMailer keeps sent messages in memory so callers can assert on them
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+// NewMemoryMailer creates an empty in-memory mailer
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// Messages returns a copy of everything sent so far
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Message, len(m.sent))
+	copy(out, m.sent)
+	return out
+}
+
+// Reset forgets all sent messages
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = nil
+}
@@ -0,0 +1,91 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+)
+
+// TLSMode controls how the SMTP connection is secured
+type TLSMode string
+
+const (
+	TLSNone     TLSMode = "none"
+	TLSStartTLS TLSMode = "starttls"
+	TLSImplicit TLSMode = "tls"
+)
+
+// SMTPMailer sends mail through an SMTP relay
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	TLS      TLSMode
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	if len(msg.To) == 0 {
+		return fmt.Errorf("message has no recipients")
+	}
+
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	tlsConfig := &tls.Config{ServerName: m.Host}
+
+	var conn net.Conn
+	var err error
+	if m.TLS == TLSImplicit {
+		conn, err = tls.Dial("tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", addr, err)
+	}
+
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %v", err)
+	}
+	defer client.Close()
+
+	if m.TLS == TLSStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS failed: %v", err)
+		}
+	}
+
+	if m.Username != "" {
+		auth := smtp.PlainAuth("", m.Username, m.Password, m.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP auth failed: %v", err)
+		}
+	}
+
+	if err := client.Mail(m.From); err != nil {
+		return fmt.Errorf("MAIL FROM rejected: %v", err)
+	}
+	for _, rcpt := range msg.To {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("RCPT TO %s rejected: %v", rcpt, err)
+		}
+	}
+
+	wc, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("DATA failed: %v", err)
+	}
+	if _, err := wc.Write(build(m.From, msg)); err != nil {
+		wc.Close()
+		return fmt.Errorf("failed to write message: %v", err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %v", err)
+	}
+
+	return client.Quit()
+}
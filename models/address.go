@@ -0,0 +1,68 @@
+package models
+
+import "strings"
+
+// Address types
+const (
+	AddressBilling  = "billing"
+	AddressShipping = "shipping"
+)
+
+// CustomerAddress is one structured billing or shipping address. A customer
+// has at most one default address of each type.
+type CustomerAddress struct {
+	ID         int    `json:"id"`
+	CustomerID int    `json:"customer_id"`
+	Type       string `json:"type" validate:"required,oneof=billing|shipping"`
+	Label      string `json:"label" validate:"max=100"`
+	Line1      string `json:"line1" validate:"required,max=200"`
+	Line2      string `json:"line2" validate:"max=200"`
+	City       string `json:"city" validate:"required,max=100"`
+	Region     string `json:"region" validate:"max=100"`
+	Postcode   string `json:"postcode" validate:"max=20"`
+	Country    string `json:"country" validate:"required,country"`
+	IsDefault  bool   `json:"is_default"`
+	// UnverifiedCountry keeps a legacy country that couldn't be turned into
+	// a code when the address was imported; Country is blank until it's fixed
+	UnverifiedCountry string `json:"unverified_country,omitempty"`
+}
+
+// String formats the address on one line, e.g. for Order.ShipmentAddress
+func (a CustomerAddress) String() string {
+	var parts []string
+	for _, p := range []string{a.Line1, a.Line2, strings.TrimSpace(a.City + " " + a.Postcode), a.Region, a.Country} {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Contact roles
+const (
+	ContactPrimary  = "primary"
+	ContactBilling  = "billing"
+	ContactShipping = "shipping"
+	ContactSales    = "sales"
+	ContactOther    = "other"
+)
+
+// CustomerContact is a person to reach at a customer. A customer has at
+// most one primary contact.
+type CustomerContact struct {
+	ID         int    `json:"id"`
+	CustomerID int    `json:"customer_id"`
+	Name       string `json:"name" validate:"required,max=100"`
+	Role       string `json:"role" validate:"required,oneof=primary|billing|shipping|sales|other"`
+	Email      string `json:"email" validate:"email,max=150"`
+	Phone      string `json:"phone" validate:"phone"`
+	IsPrimary  bool   `json:"is_primary"`
+}
+
+func (c CustomerContact) validate() ValidationErrors {
+	var errs ValidationErrors
+	if c.Email == "" && c.Phone == "" {
+		errs.Add("email", "or phone is required")
+	}
+	return errs
+}
@@ -0,0 +1,35 @@
+package models
+
+import "encoding/json"
+
+// AuditEntry is one row of the append-only audit log.
+// Action can be one of: "create", "update", "delete", "merge", "import"
+type AuditEntry struct {
+	ID    int    `json:"id"`
+	Actor string `json:"actor"`
+	// ActorHint is the unverified X-Actor header the client sent, if any
+	ActorHint  string          `json:"actor_hint,omitempty"`
+	RemoteAddr string          `json:"remote_addr,omitempty"`
+	OccurredAt string          `json:"occurred_at"`
+	Method     string          `json:"method"`
+	Route      string          `json:"route"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Action     string          `json:"action"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Diff       json.RawMessage `json:"diff,omitempty"`
+}
+
+// AuditFilter selects audit entries. Entries come oldest first unless
+// NewestFirst is set; BeforeID keeps only entries older than that one.
+type AuditFilter struct {
+	EntityType  string
+	EntityID    string
+	Actor       string
+	From        string
+	To          string
+	Limit       int
+	BeforeID    int
+	NewestFirst bool
+}
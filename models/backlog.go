@@ -0,0 +1,89 @@
+package models
+
+// Aging buckets for open orders, by days past shipment_due
+const (
+	BucketNotDue = "not_due"
+	Bucket0To7   = "0-7"
+	Bucket8To30  = "8-30"
+	BucketOver30 = "30+"
+)
+
+// AgingBuckets lists the buckets in report order
+var AgingBuckets = []string{BucketNotDue, Bucket0To7, Bucket8To30, BucketOver30}
+
+// AgingBucket places an order by how many days it is past due. Orders
+// without a due date, or due today or later, are not_due, matching what
+// GET /orders/overdue counts as overdue.
+func AgingBucket(daysOverdue *int) string {
+	switch {
+	case daysOverdue == nil || *daysOverdue <= 0:
+		return BucketNotDue
+	case *daysOverdue <= 7:
+		return Bucket0To7
+	case *daysOverdue <= 30:
+		return Bucket8To30
+	default:
+		return BucketOver30
+	}
+}
+
+// BacklogFilter selects open orders as of a date. OverdueOnly keeps just
+// the orders whose shipment_due is before AsOf.
+type BacklogFilter struct {
+	AsOf        string
+	CustomerID  int
+	OverdueOnly bool
+}
+
+// BacklogOrder is an order that is pending or shipped and due, with what is
+// still to ship. DaysOverdue is nil when the order has no due date and
+// negative when it is not due yet.
+type BacklogOrder struct {
+	OrderID             int     `json:"order_id"`
+	CustomerID          int     `json:"customer_id"`
+	CustomerName        string  `json:"customer_name"`
+	OrderDate           string  `json:"order_date"`
+	ShipmentDue         string  `json:"shipment_due,omitempty"`
+	OrderStatus         string  `json:"order_status"`
+	DaysOverdue         *int    `json:"days_overdue"`
+	Bucket              string  `json:"bucket"`
+	OutstandingQuantity int     `json:"outstanding_quantity"`
+	OutstandingValue    float64 `json:"outstanding_value"`
+}
+
+type BacklogBucket struct {
+	Bucket              string  `json:"bucket"`
+	OrderCount          int     `json:"order_count"`
+	OutstandingQuantity int     `json:"outstanding_quantity"`
+	OutstandingValue    float64 `json:"outstanding_value"`
+}
+
+type BacklogReport struct {
+	AsOf                string          `json:"as_of"`
+	OrderCount          int             `json:"order_count"`
+	OutstandingQuantity int             `json:"outstanding_quantity"`
+	OutstandingValue    float64         `json:"outstanding_value"`
+	Buckets             []BacklogBucket `json:"buckets"`
+	Orders              []BacklogOrder  `json:"orders"`
+}
+
+// AddOrder appends an order and adds it to its bucket and the totals
+func (r *BacklogReport) AddOrder(o BacklogOrder) {
+	o.Bucket = AgingBucket(o.DaysOverdue)
+	r.Orders = append(r.Orders, o)
+	r.OrderCount++
+	r.OutstandingQuantity += o.OutstandingQuantity
+	r.OutstandingValue += o.OutstandingValue
+	for i := range r.Buckets {
+		if r.Buckets[i].Bucket == o.Bucket {
+			r.Buckets[i].OrderCount++
+			r.Buckets[i].OutstandingQuantity += o.OutstandingQuantity
+			r.Buckets[i].OutstandingValue += o.OutstandingValue
+		}
+	}
+}
+
+// OverdueDigestPayload is the payload for JobOverdueDigest
+type OverdueDigestPayload struct {
+	Date string `json:"date"`
+}
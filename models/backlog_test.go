@@ -0,0 +1,56 @@
+package models
+
+import "testing"
+
+func TestAgingBucket(t *testing.T) {
+	for _, tc := range []struct {
+		days *int
+		want string
+	}{
+		{nil, BucketNotDue},
+		{intPtr(-5), BucketNotDue},
+		{intPtr(0), BucketNotDue},
+		{intPtr(1), Bucket0To7},
+		{intPtr(7), Bucket0To7},
+		{intPtr(8), Bucket8To30},
+		{intPtr(30), Bucket8To30},
+		{intPtr(31), BucketOver30},
+		{intPtr(400), BucketOver30},
+	} {
+		if got := AgingBucket(tc.days); got != tc.want {
+			if tc.days == nil {
+				t.Errorf("AgingBucket(nil) = %s, want %s", got, tc.want)
+			} else {
+				t.Errorf("AgingBucket(%d) = %s, want %s", *tc.days, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestBacklogReportAddOrder(t *testing.T) {
+	var r BacklogReport
+	for _, b := range AgingBuckets {
+		r.Buckets = append(r.Buckets, BacklogBucket{Bucket: b})
+	}
+	r.AddOrder(BacklogOrder{OrderID: 1, OutstandingQuantity: 2, OutstandingValue: 50})
+	r.AddOrder(BacklogOrder{OrderID: 2, DaysOverdue: intPtr(7), OutstandingQuantity: 1, OutstandingValue: 12})
+	r.AddOrder(BacklogOrder{OrderID: 3, DaysOverdue: intPtr(8), OutstandingQuantity: 3, OutstandingValue: 36})
+
+	if r.OrderCount != 3 || r.OutstandingQuantity != 6 || r.OutstandingValue != 98 {
+		t.Errorf("got totals %d, %d, %.2f", r.OrderCount, r.OutstandingQuantity, r.OutstandingValue)
+	}
+	want := []BacklogBucket{
+		{Bucket: BucketNotDue, OrderCount: 1, OutstandingQuantity: 2, OutstandingValue: 50},
+		{Bucket: Bucket0To7, OrderCount: 1, OutstandingQuantity: 1, OutstandingValue: 12},
+		{Bucket: Bucket8To30, OrderCount: 1, OutstandingQuantity: 3, OutstandingValue: 36},
+		{Bucket: BucketOver30},
+	}
+	for i, b := range r.Buckets {
+		if b != want[i] {
+			t.Errorf("bucket %d: got %+v, want %+v", i, b, want[i])
+		}
+	}
+	if r.Orders[1].Bucket != Bucket0To7 {
+		t.Errorf("order 2 is in bucket %s, want %s", r.Orders[1].Bucket, Bucket0To7)
+	}
+}
@@ -2,9 +2,56 @@ package models
 
 type Customer struct {
 	ID      int    `json:"id"`
-	Name    string `json:"name"`
-	Number  string `json:"number"`
-	Email   string `json:"email"`
-	Country string `json:"country"`
-	Address string `json:"address"`
+	Name    string `json:"name" validate:"required,max=100"`
+	Number  string `json:"number" validate:"phone"`
+	Email   string `json:"email" validate:"email,max=150"`
+	Country string `json:"country" validate:"country"`
+	// UnverifiedCountry keeps a legacy free-text country that couldn't be
+	// turned into a code; Country is blank until someone sets it. It is
+	// only loaded when fetching a single customer.
+	UnverifiedCountry   string `json:"unverified_country,omitempty"`
+	Address             string `json:"address"`
+	NotificationsOptOut bool   `json:"notifications_opt_out"`
+
+	// Addresses and Contacts are only loaded when fetching a single customer
+	Addresses []CustomerAddress `json:"addresses,omitempty"`
+	Contacts  []CustomerContact `json:"contacts,omitempty"`
+}
+
+// CustomerSales totals a customer's orders
+type CustomerSales struct {
+	CustomerID      int     `json:"customer_id"`
+	OrderCount      int     `json:"order_count"`
+	TotalOrderValue float64 `json:"total_order_value"`
+	ShippedSales    float64 `json:"shipped_sales"`
+}
+
+// CustomerPage is one page of customer search results
+type CustomerPage struct {
+	Customers []Customer `json:"customers"`
+	Total     int        `json:"total"`
+	Page      int        `json:"page"`
+	PageSize  int        `json:"page_size"`
+}
+
+// CustomerOption is the ID and name pair used by typeahead pickers
+type CustomerOption struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// CustomerSummary is a customer's lifetime activity in one response.
+// Outstanding counts ordered units not yet shipped.
+type CustomerSummary struct {
+	Customer          *Customer  `json:"customer"`
+	OrderCount        int        `json:"order_count"`
+	OrderedValue      float64    `json:"ordered_value"`
+	ShippedValue      float64    `json:"shipped_value"`
+	OutstandingItems  int        `json:"outstanding_items"`
+	OutstandingValue  float64    `json:"outstanding_value"`
+	AverageOrderValue float64    `json:"average_order_value"`
+	FirstOrderDate    *string    `json:"first_order_date"`
+	LastOrderDate     *string    `json:"last_order_date"`
+	RecentOrders      []Order    `json:"recent_orders"`
+	RecentShipments   []Shipment `json:"recent_shipments"`
 }
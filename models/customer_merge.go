@@ -0,0 +1,34 @@
+package models
+
+import "encoding/json"
+
+// DuplicateCandidate pairs two customers that may be the same business.
+// Reasons can include: "similar_name", "same_email", "same_phone"
+type DuplicateCandidate struct {
+	Customer  Customer `json:"customer"`
+	Duplicate Customer `json:"duplicate"`
+	Reasons   []string `json:"reasons"`
+	Score     float64  `json:"score"`
+}
+
+// CustomerMergeRequest is the body of POST /customers/{id}/merge. The source
+// customer is folded into the one in the URL and then deleted.
+type CustomerMergeRequest struct {
+	SourceID int `json:"source_id" validate:"required,gt=0"`
+}
+
+// CustomerMerge records one merge. Source holds the source customer as it
+// was just before it was deleted.
+type CustomerMerge struct {
+	ID             int             `json:"id"`
+	TargetID       int             `json:"target_id"`
+	SourceID       int             `json:"source_id"`
+	SourceName     string          `json:"source_name"`
+	Source         json.RawMessage `json:"source"`
+	OrdersMoved    int             `json:"orders_moved"`
+	ShipmentsMoved int             `json:"shipments_moved"`
+	AddressesMoved int             `json:"addresses_moved"`
+	ContactsMoved  int             `json:"contacts_moved"`
+	Actor          string          `json:"actor"`
+	MergedAt       string          `json:"merged_at"`
+}
@@ -0,0 +1,111 @@
+package models
+
+import "fmt"
+
+// CustomsLine is one shipped order line with the customs details of its
+// product. ProductID is nil when no product matches the item's name.
+type CustomsLine struct {
+	OrderItemID   int     `json:"order_item_id"`
+	Name          string  `json:"name"`
+	Size          *string `json:"size,omitempty"`
+	Color         *string `json:"color,omitempty"`
+	Quantity      int     `json:"quantity"`
+	UnitValue     float64 `json:"unit_value"`
+	ProductID     *int    `json:"product_id,omitempty"`
+	Description   string  `json:"description"`
+	HSCode        string  `json:"hs_code"`
+	OriginCountry string  `json:"origin_country"`
+	// NetWeightKg and GrossWeightKg are per unit
+	NetWeightKg   float64 `json:"net_weight_kg"`
+	GrossWeightKg float64 `json:"gross_weight_kg"`
+}
+
+func (l CustomsLine) Value() float64 {
+	return l.UnitValue * float64(l.Quantity)
+}
+
+func (l CustomsLine) TotalNetWeight() float64 {
+	return l.NetWeightKg * float64(l.Quantity)
+}
+
+// TotalGrossWeight falls back to the net weight for products without a
+// packed weight
+func (l CustomsLine) TotalGrossWeight() float64 {
+	if l.GrossWeightKg > 0 {
+		return l.GrossWeightKg * float64(l.Quantity)
+	}
+	return l.TotalNetWeight()
+}
+
+// CustomsParty is the exporter or consignee on customs documents
+type CustomsParty struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Country string `json:"country"`
+	Phone   string `json:"phone,omitempty"`
+	Email   string `json:"email,omitempty"`
+}
+
+// CustomsDocument has what the commercial invoice and packing list of a
+// shipment print
+type CustomsDocument struct {
+	InvoiceNumber string        `json:"invoice_number"`
+	Date          string        `json:"date"`
+	Currency      string        `json:"currency"`
+	Exporter      CustomsParty  `json:"exporter"`
+	Consignee     CustomsParty  `json:"consignee"`
+	Shipment      Shipment      `json:"shipment"`
+	Lines         []CustomsLine `json:"lines"`
+}
+
+// Missing lists the lines that can't be declared for lack of product data
+func (d CustomsDocument) Missing() ValidationErrors {
+	var errs ValidationErrors
+	for i, l := range d.Lines {
+		field := fmt.Sprintf("lines[%d]", i)
+		switch {
+		case l.ProductID == nil:
+			errs.Add(field, "has no product named %q", l.Name)
+		case l.NetWeightKg <= 0:
+			errs.Add(field, "has no net weight on product %q", l.Name)
+		}
+	}
+	return errs
+}
+
+func (d CustomsDocument) TotalValue() float64 {
+	var total float64
+	for _, l := range d.Lines {
+		total += l.Value()
+	}
+	return total
+}
+
+func (d CustomsDocument) NetWeightKg() float64 {
+	var total float64
+	for _, l := range d.Lines {
+		total += l.TotalNetWeight()
+	}
+	return total
+}
+
+// GrossWeightKg is the weighed packages' total when every package was
+// weighed, and otherwise the products' packed weights
+func (d CustomsDocument) GrossWeightKg() float64 {
+	var weighed float64
+	for _, p := range d.Shipment.Packages {
+		if p.WeightKg <= 0 {
+			weighed = 0
+			break
+		}
+		weighed += p.WeightKg
+	}
+	if weighed > 0 {
+		return weighed
+	}
+	var total float64
+	for _, l := range d.Lines {
+		total += l.TotalGrossWeight()
+	}
+	return total
+}
@@ -0,0 +1,61 @@
+package models
+
+import "fmt"
+
+// OrderDestination is one of the places an order is split across. Each
+// destination is allocated part of the order's lines, and every shipment of
+// the order goes to exactly one destination.
+type OrderDestination struct {
+	ID      int    `json:"id"`
+	OrderID int    `json:"order_id"`
+	Label   string `json:"label" validate:"max=100"`
+	// ShippingAddressID picks one of the customer's shipping addresses and
+	// is snapshotted into ShippingAddress like on the order itself
+	ShipmentAddress   string           `json:"shipment_address"`
+	ShippingAddressID *int             `json:"shipping_address_id,omitempty" validate:"gt=0"`
+	ShippingAddress   *CustomerAddress `json:"shipping_address,omitempty"`
+	Allocations       []Allocation     `json:"allocations" validate:"required,dive"`
+}
+
+// Allocation is how much of an order line goes to a destination. Shipped
+// and Due are filled in when destinations are read.
+type Allocation struct {
+	ItemID   int `json:"item_id" validate:"required,gt=0"`
+	Quantity int `json:"quantity" validate:"gt=0"`
+	Shipped  int `json:"shipped"`
+	Due      int `json:"due"`
+}
+
+// A destination needs an address and lists each order line at most once
+func (d OrderDestination) validate() ValidationErrors {
+	var errs ValidationErrors
+	if d.ShippingAddressID == nil && d.ShipmentAddress == "" {
+		errs.Add("shipment_address", "is required unless shipping_address_id is given")
+	}
+	seen := make(map[int]bool)
+	for i, a := range d.Allocations {
+		if a.ItemID > 0 && seen[a.ItemID] {
+			errs.Add(fmt.Sprintf("allocations[%d].item_id", i), "is listed more than once")
+		}
+		seen[a.ItemID] = true
+	}
+	return errs
+}
+
+// OrderDestinations is the body of PUT /orders/{id}/destinations. It
+// replaces all of an order's destinations; every order line must be
+// allocated in full across them.
+type OrderDestinations struct {
+	Destinations []OrderDestination `json:"destinations" validate:"required,dive"`
+}
+
+// Allocated totals each order line's quantity across all destinations
+func (o OrderDestinations) Allocated() map[int]int {
+	allocated := make(map[int]int)
+	for _, d := range o.Destinations {
+		for _, a := range d.Allocations {
+			allocated[a.ItemID] += a.Quantity
+		}
+	}
+	return allocated
+}
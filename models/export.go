@@ -0,0 +1,40 @@
+package models
+
+// OrderLine is one order item together with its order, as exported
+type OrderLine struct {
+	OrderID         int
+	ExternalRef     string
+	CustomerID      int
+	CustomerName    string
+	OrderDate       string
+	ShipmentDue     string
+	OrderStatus     string
+	ShipmentAddress string
+	ItemID          int
+	ItemName        string
+	Size            string
+	Color           string
+	Price           float64
+	Quantity        int
+}
+
+// ShipmentLine is one item in a shipment together with its order, as exported
+type ShipmentLine struct {
+	ShipmentID   int
+	ShippedDate  string
+	DueOrderType bool
+	Carrier      string
+	ServiceLevel string
+	// TrackingNumbers are the shipment's package tracking numbers, space separated
+	TrackingNumbers string
+	OrderID         int
+	CustomerID      int
+	CustomerName    string
+	OrderStatus     string
+	ItemID          int
+	ItemName        string
+	Size            string
+	Color           string
+	Price           float64
+	Quantity        int
+}
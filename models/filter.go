@@ -0,0 +1,20 @@
+package models
+
+// OrderFilter narrows order listings and exports. Dates are inclusive
+// YYYY-MM-DD bounds on the order date; zero values match everything.
+type OrderFilter struct {
+	From       string
+	To         string
+	Status     string
+	CustomerID int
+}
+
+// ShipmentFilter narrows shipment listings and exports. Dates are inclusive
+// bounds on the shipped date and Status matches the order's status.
+type ShipmentFilter struct {
+	From       string
+	To         string
+	Status     string
+	CustomerID int
+	OrderID    int
+}
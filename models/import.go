@@ -0,0 +1,56 @@
+package models
+
+// How an import treats a record that already exists
+const (
+	DuplicateError  = "error"
+	DuplicateSkip   = "skip"
+	DuplicateUpdate = "update"
+)
+
+type ImportOptions struct {
+	// DryRun checks every row and reports what would happen, then rolls back
+	DryRun      bool
+	OnDuplicate string
+}
+
+// ImportError is a problem with one row of an import file. Row is the
+// spreadsheet row number, counting the header as row 1.
+type ImportError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportResult reports an import. Nothing is saved unless Committed is true,
+// which needs a clean run that wasn't a dry run.
+type ImportResult struct {
+	Kind      string        `json:"kind"`
+	DryRun    bool          `json:"dry_run"`
+	Committed bool          `json:"committed"`
+	Records   int           `json:"records"`
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Skipped   int           `json:"skipped"`
+	Errors    []ImportError `json:"errors"`
+}
+
+// AddError records a problem with a row
+func (r *ImportResult) AddError(row int, field, message string) {
+	r.Errors = append(r.Errors, ImportError{Row: row, Field: field, Message: message})
+}
+
+// CustomerImport is one customer read from an import file
+type CustomerImport struct {
+	Row      int
+	Customer Customer
+}
+
+// OrderImport is one order read from an import file. Its lines may span
+// several rows; ItemRows holds the row each item came from. The customer is
+// given either by ID or by exact name.
+type OrderImport struct {
+	Row          int
+	ItemRows     []int
+	CustomerName string
+	Order        Order
+}
@@ -0,0 +1,43 @@
+package models
+
+import "encoding/json"
+
+// Job kinds. The order and shipment kinds are written to the outbox in the
+// same transaction as the change they describe.
+const (
+	JobOrderCreated            = "order.created"
+	JobShipmentCreated         = "shipment.created"
+	JobEmailOrderCreated       = "email.order_created"
+	JobEmailShipmentDispatched = "email.shipment_dispatched"
+	JobEmailOrderShipped       = "email.order_shipped"
+	JobOverdueDigest           = "report.overdue_digest"
+)
+
+// Job is a unit of background work.
+// Status can be one of: "queued", "running", "done", "dead"
+type Job struct {
+	ID          int             `json:"id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       string          `json:"run_at"`
+	DedupeKey   *string         `json:"dedupe_key,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+	CompletedAt *string         `json:"completed_at,omitempty"`
+}
+
+// ShipmentJobPayload is the outbox payload for JobShipmentCreated.
+// PreviousOrderStatus and OrderStatus are the order's status before and
+// after the shipment, so the job can publish the change.
+type ShipmentJobPayload struct {
+	ShipmentID          int    `json:"shipment_id"`
+	OrderID             int    `json:"order_id"`
+	ShippedDate         string `json:"shipped_date"`
+	Items               []Item `json:"items"`
+	PreviousOrderStatus string `json:"previous_order_status,omitempty"`
+	OrderStatus         string `json:"order_status,omitempty"`
+}
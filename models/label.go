@@ -0,0 +1,23 @@
+package models
+
+// ShipmentLabel is a label bought from a carrier for a shipment. A shipment
+// has at most one label that hasn't been cancelled.
+type ShipmentLabel struct {
+	ID              int      `json:"id"`
+	ShipmentID      int      `json:"shipment_id"`
+	Carrier         string   `json:"carrier"`
+	ServiceLevel    string   `json:"service_level"`
+	TrackingNumbers []string `json:"tracking_numbers"`
+	Format          string   `json:"format"`
+	Data            []byte   `json:"-"`
+	Cost            float64  `json:"cost"`
+	Currency        string   `json:"currency"`
+	CreatedAt       string   `json:"created_at"`
+	CancelledAt     *string  `json:"cancelled_at,omitempty"`
+}
+
+// LabelPurchase picks the carrier service to buy a label for
+type LabelPurchase struct {
+	Carrier      string `json:"carrier" validate:"required,max=50"`
+	ServiceLevel string `json:"service_level" validate:"required,max=50"`
+}
@@ -0,0 +1,14 @@
+package models
+
+// OrderNotification records an email the system sent (or deliberately
+// skipped) for an order. Status is one of "sent", "skipped" or "failed".
+type OrderNotification struct {
+	ID        int    `json:"id"`
+	OrderID   int    `json:"order_id"`
+	Event     string `json:"event"`
+	Recipient string `json:"recipient"`
+	Subject   string `json:"subject"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	SentAt    string `json:"sent_at"`
+}
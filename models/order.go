@@ -1,24 +1,61 @@
 package models
 
+// Order statuses
+const (
+	OrderPending       = "pending"
+	OrderShipped       = "shipped"
+	OrderShippedAndDue = "shipped and due"
+	// OrderDelivered is a shipped order whose shipments have all been delivered
+	OrderDelivered = "delivered"
+)
+
 type Order struct {
-	ID              int    `json:"id"`
-	CustomerID      int    `json:"customer_id"`
+	ID int `json:"id"`
+	// ExternalRef identifies the order in another system, e.g. an import file
+	ExternalRef     string `json:"external_ref,omitempty" validate:"max=100"`
+	CustomerID      int    `json:"customer_id" validate:"required,gt=0"`
 	CustomerName    string `json:"customer_name"`
-	OrderDate       string `json:"order_date"`
-	ShipmentDue     string `json:"shipment_due"`
+	OrderDate       string `json:"order_date" validate:"required,date"`
+	ShipmentDue     string `json:"shipment_due" validate:"date"`
 	ShipmentAddress string `json:"shipment_address"`
-	// OrderStatus can be one of: "pending", "shipped", "shipped but due"
-	OrderStatus string  `json:"order_status"`
-	Items       []Item  `json:"items"`
-	TotalPrice  float64 `json:"total_price"`
-	NoOfItems   int     `json:"no_of_items"`
+	// ShippingAddressID picks one of the customer's shipping addresses. The
+	// address as it was when the order was placed is kept in ShippingAddress.
+	ShippingAddressID *int             `json:"shipping_address_id,omitempty" validate:"gt=0"`
+	ShippingAddress   *CustomerAddress `json:"shipping_address,omitempty"`
+	// OrderStatus can be one of: "pending", "shipped", "shipped and due", "delivered"
+	OrderStatus string  `json:"order_status" validate:"oneof=pending|shipped|shipped and due|delivered"`
+	Items       []Item  `json:"items" validate:"required,dive"`
+	TotalPrice  float64 `json:"total_price" validate:"gte=0"`
+	NoOfItems   int     `json:"no_of_items" validate:"gte=0"`
+}
+
+// The shipment is due after the order was placed
+func (o Order) validate() ValidationErrors {
+	var errs ValidationErrors
+	if o.ShipmentDue == "" {
+		return errs
+	}
+	ordered, err1 := ParseDate(o.OrderDate)
+	due, err2 := ParseDate(o.ShipmentDue)
+	if err1 == nil && err2 == nil && !due.After(ordered) {
+		errs.Add("shipment_due", "must be after order_date")
+	}
+	return errs
 }
 
 type Item struct {
 	ID       int     `json:"id"`
-	Name     string  `json:"name"`
+	Name     string  `json:"name" validate:"required,max=100"`
 	Size     *string `json:"size,omitempty"`
 	Color    *string `json:"color,omitempty"`
-	Price    float64 `json:"price"`
-	Quantity int     `json:"quantity"`
+	Price    float64 `json:"price" validate:"gt=0"`
+	Quantity int     `json:"quantity" validate:"gt=0"`
+	// Estimated marks shipment lines whose quantity was inferred when
+	// shipments were migrated off the old item ID arrays
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// OrderStatusUpdate is the body of POST /orders/{id}/status
+type OrderStatusUpdate struct {
+	Status string `json:"status" validate:"required,oneof=pending|shipped|shipped and due|delivered"`
 }
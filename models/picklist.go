@@ -0,0 +1,199 @@
+package models
+
+import "fmt"
+
+// Pick list statuses
+const (
+	PickListOpen      = "open"
+	PickListPacked    = "packed"
+	PickListShipped   = "shipped"
+	PickListCancelled = "cancelled"
+)
+
+// PickList gathers what is outstanding on a set of orders for the
+// warehouse. Orders stay on it until they ship or the list is cancelled.
+type PickList struct {
+	ID        int             `json:"id"`
+	Status    string          `json:"status"`
+	CreatedAt string          `json:"created_at"`
+	Orders    []PickListOrder `json:"orders"`
+	// Lines group identical items across the orders, for picking
+	Lines []PickLine `json:"lines,omitempty"`
+}
+
+// PickListOrder is one order on a pick list: what to pick for it and the
+// parcels it was packed into. ShipmentID is set once the pack has shipped;
+// an order packed for several destinations ships once per destination and
+// ShipmentID is the first of those, with each parcel naming its own.
+type PickListOrder struct {
+	OrderID      int            `json:"order_id"`
+	CustomerName string         `json:"customer_name"`
+	ShipmentID   *int           `json:"shipment_id,omitempty"`
+	Items        []PickListItem `json:"items,omitempty"`
+	Parcels      []PackParcel   `json:"parcels,omitempty"`
+}
+
+// PickListItem is an order line to pick, with the quantity outstanding when
+// the list was made and how much of it has been packed
+type PickListItem struct {
+	ID       int     `json:"id"`
+	Name     string  `json:"name"`
+	Size     *string `json:"size,omitempty"`
+	Color    *string `json:"color,omitempty"`
+	Quantity int     `json:"quantity"`
+	Packed   int     `json:"packed"`
+}
+
+// PickLine is one item to pick and how many of it each order needs
+type PickLine struct {
+	Name     string          `json:"name"`
+	Size     string          `json:"size,omitempty"`
+	Color    string          `json:"color,omitempty"`
+	Quantity int             `json:"quantity"`
+	Orders   []PickLineOrder `json:"orders"`
+}
+
+type PickLineOrder struct {
+	OrderID     int `json:"order_id"`
+	OrderItemID int `json:"order_item_id"`
+	Quantity    int `json:"quantity"`
+}
+
+// GroupPickLines merges the orders' items with the same name, size and
+// color, keeping the order they first appear in
+func GroupPickLines(orders []PickListOrder) []PickLine {
+	var lines []PickLine
+	index := make(map[[3]string]int)
+	for _, o := range orders {
+		for _, item := range o.Items {
+			key := [3]string{item.Name, deref(item.Size), deref(item.Color)}
+			i, ok := index[key]
+			if !ok {
+				i = len(lines)
+				index[key] = i
+				lines = append(lines, PickLine{Name: key[0], Size: key[1], Color: key[2]})
+			}
+			lines[i].Quantity += item.Quantity
+			lines[i].Orders = append(lines[i].Orders, PickLineOrder{OrderID: o.OrderID, OrderItemID: item.ID, Quantity: item.Quantity})
+		}
+	}
+	return lines
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// PickListRequest makes a pick list from pending or part-shipped orders
+type PickListRequest struct {
+	OrderIDs []int `json:"order_ids" validate:"required"`
+}
+
+func (p PickListRequest) validate() ValidationErrors {
+	var errs ValidationErrors
+	seen := make(map[int]bool)
+	for i, id := range p.OrderIDs {
+		field := fmt.Sprintf("order_ids[%d]", i)
+		if id <= 0 {
+			errs.Add(field, "must be greater than 0")
+		} else if seen[id] {
+			errs.Add(field, "is listed more than once")
+		}
+		seen[id] = true
+	}
+	return errs
+}
+
+// PackRequest records the parcels staff packed for some of a pick list's
+// orders. It replaces any packing saved before for those orders.
+type PackRequest struct {
+	Orders []PackOrder `json:"orders" validate:"required,dive"`
+}
+
+type PackOrder struct {
+	OrderID int          `json:"order_id" validate:"required,gt=0"`
+	Parcels []PackParcel `json:"parcels" validate:"required,dive"`
+}
+
+// PackParcel is one parcel and the quantity of each order line in it.
+// Parcels of an order split across destinations each go to one of them,
+// and can hold no more than is allocated there and not yet shipped.
+type PackParcel struct {
+	ID            int          `json:"id"`
+	DestinationID *int         `json:"destination_id,omitempty" validate:"gt=0"`
+	WeightKg      float64      `json:"weight_kg" validate:"gte=0"`
+	LengthCm      float64      `json:"length_cm" validate:"gte=0"`
+	WidthCm       float64      `json:"width_cm" validate:"gte=0"`
+	HeightCm      float64      `json:"height_cm" validate:"gte=0"`
+	Items         []PackedItem `json:"items" validate:"required,dive"`
+	// ShipmentID is set once the parcel has shipped
+	ShipmentID *int `json:"shipment_id,omitempty"`
+}
+
+func (p PackParcel) validate() ValidationErrors {
+	var errs ValidationErrors
+	seen := make(map[int]bool)
+	for i, item := range p.Items {
+		if seen[item.ID] {
+			errs.Add(fmt.Sprintf("items[%d].id", i), "is listed more than once")
+		}
+		seen[item.ID] = true
+	}
+	return errs
+}
+
+// PackedItem is a quantity of an order line, by order item ID
+type PackedItem struct {
+	ID       int `json:"id" validate:"required,gt=0"`
+	Quantity int `json:"quantity" validate:"gt=0"`
+}
+
+// PickListShipment turns a pick list's packed orders into shipments
+type PickListShipment struct {
+	ShippedDate  string `json:"shipped_date" validate:"required,date"`
+	Carrier      string `json:"carrier" validate:"max=50"`
+	ServiceLevel string `json:"service_level" validate:"max=50"`
+}
+
+// Shipments builds the shipments for a packed order, one per destination
+// its parcels go to in the order they first appear: the packed quantities
+// of each line and a package per parcel
+func (o PickListOrder) Shipments(req PickListShipment) []Shipment {
+	var shipments []Shipment
+	byDestination := make(map[int]int)
+	index := make(map[[2]int]int)
+	for _, p := range o.Parcels {
+		destination := 0
+		if p.DestinationID != nil {
+			destination = *p.DestinationID
+		}
+		s, ok := byDestination[destination]
+		if !ok {
+			s = len(shipments)
+			byDestination[destination] = s
+			shipments = append(shipments, Shipment{
+				ShippedDate:   req.ShippedDate,
+				OrderID:       o.OrderID,
+				Carrier:       req.Carrier,
+				ServiceLevel:  req.ServiceLevel,
+				DestinationID: p.DestinationID,
+			})
+		}
+		shipment := &shipments[s]
+		shipment.Packages = append(shipment.Packages, ShipmentPackage{
+			WeightKg: p.WeightKg, LengthCm: p.LengthCm, WidthCm: p.WidthCm, HeightCm: p.HeightCm,
+		})
+		for _, item := range p.Items {
+			if i, ok := index[[2]int{s, item.ID}]; ok {
+				shipment.Items[i].Quantity += item.Quantity
+				continue
+			}
+			index[[2]int{s, item.ID}] = len(shipment.Items)
+			shipment.Items = append(shipment.Items, Item{ID: item.ID, Quantity: item.Quantity})
+		}
+	}
+	return shipments
+}
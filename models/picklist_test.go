@@ -0,0 +1,105 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func strPtr(s string) *string { return &s }
+
+func intPtr(n int) *int { return &n }
+
+func TestGroupPickLines(t *testing.T) {
+	orders := []PickListOrder{
+		{OrderID: 1, Items: []PickListItem{
+			{ID: 10, Name: "Garland", Color: strPtr("rainbow"), Quantity: 2},
+			{ID: 11, Name: "Slippers", Size: strPtr("M"), Quantity: 1},
+		}},
+		{OrderID: 2, Items: []PickListItem{
+			{ID: 20, Name: "Slippers", Size: strPtr("L"), Quantity: 1},
+			{ID: 21, Name: "Garland", Color: strPtr("rainbow"), Quantity: 3},
+			{ID: 22, Name: "Slippers", Size: strPtr("M"), Quantity: 2},
+		}},
+	}
+
+	want := []PickLine{
+		{Name: "Garland", Color: "rainbow", Quantity: 5, Orders: []PickLineOrder{
+			{OrderID: 1, OrderItemID: 10, Quantity: 2},
+			{OrderID: 2, OrderItemID: 21, Quantity: 3},
+		}},
+		{Name: "Slippers", Size: "M", Quantity: 3, Orders: []PickLineOrder{
+			{OrderID: 1, OrderItemID: 11, Quantity: 1},
+			{OrderID: 2, OrderItemID: 22, Quantity: 2},
+		}},
+		{Name: "Slippers", Size: "L", Quantity: 1, Orders: []PickLineOrder{
+			{OrderID: 2, OrderItemID: 20, Quantity: 1},
+		}},
+	}
+	if got := GroupPickLines(orders); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestPickListOrderShipmentsMergeParcels(t *testing.T) {
+	order := PickListOrder{
+		OrderID: 7,
+		Parcels: []PackParcel{
+			{WeightKg: 1.5, LengthCm: 30, WidthCm: 20, HeightCm: 10, Items: []PackedItem{{ID: 1, Quantity: 2}, {ID: 2, Quantity: 1}}},
+			{WeightKg: 0.5, Items: []PackedItem{{ID: 1, Quantity: 3}}},
+		},
+	}
+	req := PickListShipment{ShippedDate: "2026-10-19", Carrier: "dhl", ServiceLevel: "express"}
+
+	want := []Shipment{{
+		ShippedDate:  "2026-10-19",
+		OrderID:      7,
+		Carrier:      "dhl",
+		ServiceLevel: "express",
+		Items:        []Item{{ID: 1, Quantity: 5}, {ID: 2, Quantity: 1}},
+		Packages: []ShipmentPackage{
+			{WeightKg: 1.5, LengthCm: 30, WidthCm: 20, HeightCm: 10},
+			{WeightKg: 0.5},
+		},
+	}}
+	if got := order.Shipments(req); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestPickListOrderShipmentsPerDestination(t *testing.T) {
+	order := PickListOrder{
+		OrderID: 7,
+		Parcels: []PackParcel{
+			{DestinationID: intPtr(4), WeightKg: 1, Items: []PackedItem{{ID: 1, Quantity: 2}}},
+			{DestinationID: intPtr(3), WeightKg: 2, Items: []PackedItem{{ID: 1, Quantity: 1}, {ID: 2, Quantity: 4}}},
+			{DestinationID: intPtr(4), WeightKg: 3, Items: []PackedItem{{ID: 1, Quantity: 1}, {ID: 2, Quantity: 1}}},
+		},
+	}
+	shipments := order.Shipments(PickListShipment{ShippedDate: "2026-10-19"})
+
+	if len(shipments) != 2 {
+		t.Fatalf("got %d shipments, want one per destination", len(shipments))
+	}
+	for i, want := range []struct {
+		destination int
+		items       []Item
+		packages    int
+	}{
+		{4, []Item{{ID: 1, Quantity: 3}, {ID: 2, Quantity: 1}}, 2},
+		{3, []Item{{ID: 1, Quantity: 1}, {ID: 2, Quantity: 4}}, 1},
+	} {
+		s := shipments[i]
+		if s.DestinationID == nil || *s.DestinationID != want.destination {
+			t.Errorf("shipment %d: got destination %v, want %d", i, s.DestinationID, want.destination)
+		}
+		if !reflect.DeepEqual(s.Items, want.items) {
+			t.Errorf("shipment %d: got items %+v, want %+v", i, s.Items, want.items)
+		}
+		if len(s.Packages) != want.packages {
+			t.Errorf("shipment %d: got %d packages, want %d", i, len(s.Packages), want.packages)
+		}
+		if s.OrderID != 7 || s.ShippedDate != "2026-10-19" {
+			t.Errorf("shipment %d: got %+v", i, s)
+		}
+	}
+}
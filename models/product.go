@@ -0,0 +1,34 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Product holds the customs details of something we sell. Order items are
+// matched to a product by name, ignoring case.
+type Product struct {
+	ID   int    `json:"id"`
+	Name string `json:"name" validate:"required,max=100"`
+	// Description is how the goods are declared to customs, e.g.
+	// "Handmade felted wool balls"
+	Description   string  `json:"description" validate:"required,max=200"`
+	HSCode        string  `json:"hs_code" validate:"required,max=14"`
+	OriginCountry string  `json:"origin_country" validate:"required,country"`
+	NetWeightKg   float64 `json:"net_weight_kg" validate:"gt=0"`
+	// GrossWeightKg is the weight of one unit in its retail packaging
+	GrossWeightKg float64 `json:"gross_weight_kg" validate:"gte=0"`
+}
+
+var hsCodePattern = regexp.MustCompile(`^[0-9]{4}(\.?[0-9]{2}){1,3}$`)
+
+func (p Product) validate() ValidationErrors {
+	var errs ValidationErrors
+	if p.HSCode != "" && !hsCodePattern.MatchString(strings.TrimSpace(p.HSCode)) {
+		errs.Add("hs_code", "must be 6, 8 or 10 digits, e.g. 6307.90 or 630790")
+	}
+	if p.GrossWeightKg > 0 && p.GrossWeightKg < p.NetWeightKg {
+		errs.Add("gross_weight_kg", "must not be less than net_weight_kg")
+	}
+	return errs
+}
@@ -0,0 +1,96 @@
+package models
+
+// Sales report granularities and groupings
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+
+	GroupByCustomer = "customer"
+	GroupByCountry  = "country"
+	GroupByProduct  = "product"
+)
+
+// MaxSalesPeriods caps how many periods a sales series may list, as each
+// period is repeated for every group
+const MaxSalesPeriods = 1000
+
+// SalesFilter selects what a sales report covers. From and To are inclusive
+// YYYY-MM-DD bounds: orders count on their order date and shipped value on
+// the shipped_date of each shipment it went out in.
+type SalesFilter struct {
+	From        string
+	To          string
+	Granularity string
+	GroupBy     string
+	Top         int
+}
+
+// SalesPoint is one period of the sales series, or one group within it when
+// the report is grouped. Period is the first day of the period.
+type SalesPoint struct {
+	Period       string  `json:"period"`
+	Group        string  `json:"group,omitempty"`
+	OrderCount   int     `json:"order_count"`
+	OrderedValue float64 `json:"ordered_value"`
+	ShippedValue float64 `json:"shipped_value"`
+}
+
+type TopCustomer struct {
+	CustomerID   int     `json:"customer_id"`
+	CustomerName string  `json:"customer_name"`
+	OrderCount   int     `json:"order_count"`
+	OrderedValue float64 `json:"ordered_value"`
+	ShippedValue float64 `json:"shipped_value"`
+}
+
+type TopItem struct {
+	Name            string  `json:"name"`
+	OrderedQuantity int     `json:"ordered_quantity"`
+	OrderedValue    float64 `json:"ordered_value"`
+	ShippedQuantity int     `json:"shipped_quantity"`
+	ShippedValue    float64 `json:"shipped_value"`
+}
+
+type SalesReport struct {
+	From         string        `json:"from,omitempty"`
+	To           string        `json:"to,omitempty"`
+	Granularity  string        `json:"granularity"`
+	GroupBy      string        `json:"group_by,omitempty"`
+	Series       []SalesPoint  `json:"series"`
+	TopCustomers []TopCustomer `json:"top_customers"`
+	TopItems     []TopItem     `json:"top_items"`
+}
+
+// FulfillmentFilter selects orders by inclusive order date range and customer
+type FulfillmentFilter struct {
+	From       string
+	To         string
+	CustomerID int
+}
+
+// FulfillmentMetrics measures delivery for one slice of orders. Lead time
+// runs from order date to first shipment. Rates are percentages and are
+// nil when nothing in the slice could be measured.
+type FulfillmentMetrics struct {
+	Month              string   `json:"month,omitempty"`
+	CustomerID         int      `json:"customer_id,omitempty"`
+	CustomerName       string   `json:"customer_name,omitempty"`
+	OrderCount         int      `json:"order_count"`
+	ShippedOrderCount  int      `json:"shipped_order_count"`
+	ShipmentCount      int      `json:"shipment_count"`
+	AvgLeadTimeDays    *float64 `json:"avg_lead_time_days"`
+	MedianLeadTimeDays *float64 `json:"median_lead_time_days"`
+	OnTimeRate         *float64 `json:"on_time_rate"`
+	FillRate           *float64 `json:"first_shipment_fill_rate"`
+	FillRateOrders     int      `json:"fill_rate_order_count"`
+	ShipmentsPerOrder  *float64 `json:"shipments_per_order"`
+}
+
+type FulfillmentReport struct {
+	From       string               `json:"from,omitempty"`
+	To         string               `json:"to,omitempty"`
+	Overall    FulfillmentMetrics   `json:"overall"`
+	ByMonth    []FulfillmentMetrics `json:"by_month"`
+	ByCustomer []FulfillmentMetrics `json:"by_customer"`
+}
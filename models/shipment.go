@@ -1,12 +1,74 @@
 package models
 
+import "fmt"
+
 type Shipment struct {
-	ID           int    `json:"id"`
-	ShippedDate  string `json:"shipped_date"`
-	OrderID      int    `json:"order_id"`
-	Items        []Item `json:"items"`
-	DueOrderType bool   `json:"due_order_type"`
+	ID          int    `json:"id"`
+	ShippedDate string `json:"shipped_date" validate:"required,date"`
+	OrderID     int    `json:"order_id" validate:"required,gt=0"`
+	Items       []Item `json:"items" validate:"required"`
+	// DueOrderType is set when the shipment is stored: true when the order
+	// had already shipped in part. Any value sent by clients is ignored.
+	DueOrderType bool `json:"due_order_type"`
+	// DestinationID is required when the order is split across
+	// destinations, and names the one this shipment goes to
+	DestinationID *int `json:"destination_id,omitempty" validate:"gt=0"`
+
+	// Carrier is a code from the carriers package, or free text for
+	// carriers without tracking links
+	Carrier      string            `json:"carrier,omitempty" validate:"max=50"`
+	ServiceLevel string            `json:"service_level,omitempty" validate:"max=50"`
+	ShippingCost float64           `json:"shipping_cost" validate:"gte=0"`
+	Packages     []ShipmentPackage `json:"packages,omitempty" validate:"dive"`
+
+	// Status and DeliveredDate follow the shipment's tracking events and
+	// can't be set when it is created
+	Status        string  `json:"status"`
+	DeliveredDate *string `json:"delivered_date,omitempty"`
+}
+
+// ShipmentPackage is one parcel of a shipment. TrackingURL is filled in
+// from the carrier's template when the shipment is read.
+type ShipmentPackage struct {
+	ID             int     `json:"id"`
+	TrackingNumber string  `json:"tracking_number" validate:"max=100"`
+	TrackingURL    string  `json:"tracking_url,omitempty"`
+	WeightKg       float64 `json:"weight_kg" validate:"gte=0"`
+	LengthCm       float64 `json:"length_cm" validate:"gte=0"`
+	WidthCm        float64 `json:"width_cm" validate:"gte=0"`
+	HeightCm       float64 `json:"height_cm" validate:"gte=0"`
 }
+
+// TrackingNumbers lists the packages' tracking numbers, skipping blanks
+func (s Shipment) TrackingNumbers() []string {
+	var numbers []string
+	for _, p := range s.Packages {
+		if p.TrackingNumber != "" {
+			numbers = append(numbers, p.TrackingNumber)
+		}
+	}
+	return numbers
+}
+
+// Shipment items only carry the order item ID and the quantity shipped
+func (s Shipment) validate() ValidationErrors {
+	var errs ValidationErrors
+	seen := make(map[int]bool)
+	for i, item := range s.Items {
+		field := fmt.Sprintf("items[%d]", i)
+		if item.ID <= 0 {
+			errs.Add(field+".id", "is required")
+		} else if seen[item.ID] {
+			errs.Add(field+".id", "is listed more than once")
+		}
+		seen[item.ID] = true
+		if item.Quantity <= 0 {
+			errs.Add(field+".quantity", "must be greater than 0")
+		}
+	}
+	return errs
+}
+
 type ShipmentResponse struct {
 	ShipmentID   int       `json:"shipment_id"`
 	OrderID      int       `json:"order_id"`
@@ -0,0 +1,72 @@
+package models
+
+// Shipment statuses, in the order a parcel normally moves through them.
+// An exception can happen at any point and is cleared by the next event.
+const (
+	ShipmentCreated   = "created"
+	ShipmentPickedUp  = "picked_up"
+	ShipmentInTransit = "in_transit"
+	ShipmentDelivered = "delivered"
+	ShipmentException = "exception"
+)
+
+var shipmentStatusRank = map[string]int{
+	ShipmentException: 0,
+	ShipmentCreated:   1,
+	ShipmentPickedUp:  2,
+	ShipmentInTransit: 3,
+	ShipmentDelivered: 4,
+}
+
+// CombinedShipmentStatus is the status of a shipment whose packages are at
+// the given statuses: delivered once every package is, otherwise the least
+// advanced of them, so an exception on any package shows on the shipment.
+// A shipment with no packages is created.
+func CombinedShipmentStatus(packages []string) string {
+	if len(packages) == 0 {
+		return ShipmentCreated
+	}
+	status := packages[0]
+	for _, p := range packages[1:] {
+		if shipmentStatusRank[p] < shipmentStatusRank[status] {
+			status = p
+		}
+	}
+	return status
+}
+
+// TrackingManual is the source of events entered by staff
+const TrackingManual = "manual"
+
+// TrackingEvent is one status change of a shipment, entered by hand or
+// reported by the carrier. Each package takes the status of its latest event
+// and the shipment the CombinedShipmentStatus of its packages. An event
+// without a tracking number applies to every package.
+type TrackingEvent struct {
+	ID             int    `json:"id"`
+	ShipmentID     int    `json:"shipment_id"`
+	TrackingNumber string `json:"tracking_number,omitempty" validate:"max=100"`
+	Status         string `json:"status" validate:"required,oneof=created|picked_up|in_transit|delivered|exception"`
+	Description    string `json:"description" validate:"max=500"`
+	Location       string `json:"location" validate:"max=200"`
+	// OccurredAt is an RFC 3339 timestamp; it defaults to now
+	OccurredAt string `json:"occurred_at" validate:"timestamp"`
+	// Source is "manual" or the code of the carrier that reported the event
+	Source string `json:"source"`
+}
+
+// CarrierTrackingWebhook is the body carriers POST with tracking updates
+type CarrierTrackingWebhook struct {
+	Events []CarrierTrackingUpdate `json:"events" validate:"required,dive"`
+}
+
+// CarrierTrackingUpdate is one event in a carrier's tracking webhook. The
+// shipment is found by tracking number. Status is in the carrier vocabulary
+// (see carriers.ShipmentStatus); shipment statuses are accepted as well.
+type CarrierTrackingUpdate struct {
+	TrackingNumber string `json:"tracking_number" validate:"required,max=100"`
+	Status         string `json:"status" validate:"required,oneof=created|label_created|picked_up|in_transit|out_for_delivery|delivered|exception|cancelled"`
+	Description    string `json:"description" validate:"max=500"`
+	Location       string `json:"location" validate:"max=200"`
+	OccurredAt     string `json:"occurred_at" validate:"timestamp"`
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func validOrder() Order {
+	return Order{
+		CustomerID:  7,
+		OrderDate:   "2026-10-01",
+		ShipmentDue: "2026-10-15",
+		OrderStatus: "pending",
+		Items:       []Item{{Name: "Felt slippers", Price: 25, Quantity: 2}},
+	}
+}
+
+func TestValidateCustomer(t *testing.T) {
+	for name, tc := range map[string]struct {
+		customer Customer
+		want     ValidationErrors
+	}{
+		"valid": {
+			customer: Customer{Name: "Sita Rai", Email: "sita@example.com", Country: "np", Number: "+977 1-4412345"},
+		},
+		"blank optional fields": {
+			customer: Customer{Name: "Sita Rai"},
+		},
+		"missing name": {
+			customer: Customer{Name: "  "},
+			want:     ValidationErrors{{Field: "name", Message: "is required"}},
+		},
+		"bad fields": {
+			customer: Customer{Name: "Sita Rai", Email: "Sita <sita@example.com>", Country: "Nepal", Number: "call me"},
+			want: ValidationErrors{
+				{Field: "number", Message: "must be a valid phone number"},
+				{Field: "email", Message: "must be a valid email address"},
+				{Field: "country", Message: "must be an ISO 3166-1 alpha-2 country code"},
+			},
+		},
+		"email at 150 characters": {
+			customer: Customer{Name: "Sita Rai", Email: strings.Repeat("a", 138) + "@example.com"},
+		},
+		"email over 150 characters": {
+			customer: Customer{Name: "Sita Rai", Email: strings.Repeat("a", 139) + "@example.com"},
+			want:     ValidationErrors{{Field: "email", Message: "must be at most 150 characters"}},
+		},
+	} {
+		if got := Validate(tc.customer); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", name, got, tc.want)
+		}
+	}
+}
+
+func TestValidateOrder(t *testing.T) {
+	for name, tc := range map[string]struct {
+		change func(*Order)
+		want   ValidationErrors
+	}{
+		"valid":       {change: func(o *Order) {}},
+		"no due date": {change: func(o *Order) { o.ShipmentDue = "" }},
+		"due on the order date": {
+			change: func(o *Order) { o.ShipmentDue = o.OrderDate },
+			want:   ValidationErrors{{Field: "shipment_due", Message: "must be after order_date"}},
+		},
+		"due before the order date": {
+			change: func(o *Order) { o.ShipmentDue = "2026-09-30" },
+			want:   ValidationErrors{{Field: "shipment_due", Message: "must be after order_date"}},
+		},
+		"bad dates": {
+			change: func(o *Order) { o.OrderDate, o.ShipmentDue = "", "15/10/2026" },
+			want: ValidationErrors{
+				{Field: "order_date", Message: "is required"},
+				{Field: "shipment_due", Message: "must be a date in YYYY-MM-DD format"},
+			},
+		},
+		"bad status and items": {
+			change: func(o *Order) {
+				o.OrderStatus = "lost"
+				o.Items = append(o.Items, Item{Name: "Felt ball garland", Quantity: 0, Price: 12})
+			},
+			want: ValidationErrors{
+				{Field: "order_status", Message: "must be one of: pending, shipped, shipped and due, delivered"},
+				{Field: "items[1].quantity", Message: "must be greater than 0"},
+			},
+		},
+		"no items": {
+			change: func(o *Order) { o.Items = nil },
+			want:   ValidationErrors{{Field: "items", Message: "is required"}},
+		},
+	} {
+		order := validOrder()
+		tc.change(&order)
+		if got := Validate(order); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", name, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizeCountry(t *testing.T) {
+	for in, want := range map[string]struct {
+		code string
+		ok   bool
+	}{
+		"":         {"", true},
+		"  ":       {"", true},
+		"NP":       {"NP", true},
+		" np ":     {"NP", true},
+		"Nepal":    {"NP", true},
+		"NPL":      {"NP", true},
+		"u.s.a.":   {"US", true},
+		"USA":      {"US", true},
+		"Türkiye":  {"TR", true},
+		"uk":       {"GB", true},
+		"Atlantis": {"", false},
+		"XX":       {"", false},
+	} {
+		code, ok := NormalizeCountry(in)
+		if code != want.code || ok != want.ok {
+			t.Errorf("NormalizeCountry(%q) = %q, %v, want %q, %v", in, code, ok, want.code, want.ok)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError describes one invalid field using its JSON path, e.g. "items[2].quantity"
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is every problem found with a request body
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	parts := make([]string, len(v))
+	for i, e := range v {
+		parts[i] = e.Field + " " + e.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Add records a problem with field
+func (v *ValidationErrors) Add(field, format string, args ...any) {
+	*v = append(*v, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// crossValidator is implemented by models with rules that span fields
+type crossValidator interface {
+	validate() ValidationErrors
+}
+
+// Validate checks v against the rules in its `validate` struct tags and any
+// cross-field rules it defines. Supported rules:
+//
+//	required        non-zero value (non-empty for strings and slices)
+//	email           valid email address
+//	phone           digits with optional + ( ) - . / , and spaces
+//	country         ISO 3166-1 alpha-2 code, case-insensitive
+//	date            YYYY-MM-DD
+//	timestamp       RFC 3339 date and time
+//	url             absolute http or https URL
+//	gt=N, gte=N     numeric lower bound
+//	max=N           maximum string length
+//	oneof=a|b|c     one of the listed values
+//	dive            validate each element of a slice of structs
+//
+// Format rules are skipped for empty values, so combine them with required
+// when the field is mandatory.
+func Validate(v any) ValidationErrors {
+	var errs ValidationErrors
+	validateValue(reflect.ValueOf(v), "", &errs)
+	return errs
+}
+
+func validateValue(rv reflect.Value, prefix string, errs *ValidationErrors) {
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := jsonName(sf)
+		if name == "-" {
+			continue
+		}
+		field := prefix + name
+
+		for _, rule := range strings.Split(sf.Tag.Get("validate"), ",") {
+			if rule == "" {
+				continue
+			}
+			if rule == "dive" {
+				fv := rv.Field(i)
+				if fv.Kind() == reflect.Slice {
+					for j := 0; j < fv.Len(); j++ {
+						validateValue(fv.Index(j), fmt.Sprintf("%s[%d].", field, j), errs)
+					}
+				}
+				continue
+			}
+			if msg := checkRule(rule, rv.Field(i)); msg != "" {
+				errs.Add(field, "%s", msg)
+				break
+			}
+		}
+	}
+
+	if cv, ok := rv.Interface().(crossValidator); ok {
+		for _, e := range cv.validate() {
+			errs.Add(prefix+e.Field, "%s", e.Message)
+		}
+	}
+}
+
+func jsonName(sf reflect.StructField) string {
+	name := strings.Split(sf.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return sf.Name
+	}
+	return name
+}
+
+var phonePattern = regexp.MustCompile(`^\+?[0-9][0-9 ()\-./,]{5,19}$`)
+
+func checkRule(rule string, fv reflect.Value) string {
+	name, arg, _ := strings.Cut(rule, "=")
+
+	for fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			if name == "required" {
+				return "is required"
+			}
+			return ""
+		}
+		fv = fv.Elem()
+	}
+
+	if name == "required" {
+		if fv.IsZero() || (fv.Kind() == reflect.Slice && fv.Len() == 0) || (fv.Kind() == reflect.String && strings.TrimSpace(fv.String()) == "") {
+			return "is required"
+		}
+		return ""
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		s := strings.TrimSpace(fv.String())
+		if s == "" {
+			return ""
+		}
+		switch name {
+		case "email":
+			if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+				return "must be a valid email address"
+			}
+		case "phone":
+			if !phonePattern.MatchString(s) {
+				return "must be a valid phone number"
+			}
+		case "country":
+			if !IsCountryCode(s) {
+				return "must be an ISO 3166-1 alpha-2 country code"
+			}
+		case "date":
+			if _, err := ParseDate(s); err != nil {
+				return "must be a date in YYYY-MM-DD format"
+			}
+		case "timestamp":
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				return "must be an RFC 3339 timestamp"
+			}
+		case "url":
+			if u, err := url.Parse(s); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return "must be an absolute http(s) URL"
+			}
+		case "max":
+			n, _ := strconv.Atoi(arg)
+			if len([]rune(s)) > n {
+				return fmt.Sprintf("must be at most %d characters", n)
+			}
+		case "oneof":
+			for _, opt := range strings.Split(arg, "|") {
+				if s == opt {
+					return ""
+				}
+			}
+			return fmt.Sprintf("must be one of: %s", strings.ReplaceAll(arg, "|", ", "))
+		}
+
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		var val float64
+		if fv.Kind() == reflect.Float64 {
+			val = fv.Float()
+		} else {
+			val = float64(fv.Int())
+		}
+		bound, _ := strconv.ParseFloat(arg, 64)
+		switch name {
+		case "gt":
+			if val <= bound {
+				return fmt.Sprintf("must be greater than %s", arg)
+			}
+		case "gte":
+			if val < bound {
+				return fmt.Sprintf("must be at least %s", arg)
+			}
+		}
+	}
+	return ""
+}
+
+// ParseDate parses a YYYY-MM-DD date. Values read back from DATE columns
+// carry a time part, so only the first ten characters are considered then.
+func ParseDate(s string) (time.Time, error) {
+	if len(s) > 10 && s[10] == 'T' {
+		s = s[:10]
+	}
+	return time.Parse("2006-01-02", s)
+}
@@ -0,0 +1,50 @@
+package models
+
+import "encoding/json"
+
+type WebhookSubscription struct {
+	ID         int      `json:"id"`
+	URL        string   `json:"url" validate:"required,url"`
+	EventTypes []string `json:"event_types" validate:"required"`
+	// Secret is only returned when the subscription is created
+	Secret    string `json:"secret,omitempty"`
+	Active    bool   `json:"active"`
+	CreatedAt string `json:"created_at"`
+}
+
+// WebhookSubscriptionPatch is the body of PATCH /webhooks/{id}. A paused
+// subscription gets no new deliveries and its pending ones are cancelled.
+type WebhookSubscriptionPatch struct {
+	Active *bool `json:"active"`
+}
+
+func (p WebhookSubscriptionPatch) validate() ValidationErrors {
+	var errs ValidationErrors
+	if p.Active == nil {
+		errs.Add("active", "is required")
+	}
+	return errs
+}
+
+// WebhookDelivery is one event queued for one subscription.
+// Status can be one of: "pending", "delivered", "failed", "cancelled".
+// Pending deliveries are cancelled when their subscription is deactivated.
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	SubscriptionID int             `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *string         `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	ReplayOf       *int            `json:"replay_of,omitempty"`
+	CreatedAt      string          `json:"created_at"`
+	DeliveredAt    *string         `json:"delivered_at,omitempty"`
+
+	// URL and Secret are filled in when a delivery is claimed for sending
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
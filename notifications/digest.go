@@ -0,0 +1,27 @@
+package notifications
+
+import (
+	"fmt"
+
+	"AAHAOMS/OMS/mailer"
+	"AAHAOMS/OMS/models"
+)
+
+// OverdueDigest emails staff the overdue orders in report. Nothing is sent
+// when there are no recipients or nothing is overdue.
+func (n *Notifier) OverdueDigest(recipients []string, report models.BacklogReport) error {
+	if len(recipients) == 0 || report.OrderCount == 0 {
+		return nil
+	}
+
+	text, html, err := renderWithLayout("staff_layout.html", "overdue_digest", report)
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("%d overdue orders as of %s", report.OrderCount, report.AsOf)
+	if err := n.Mailer.Send(mailer.Message{To: recipients, Subject: subject, Text: text, HTML: html}); err != nil {
+		return fmt.Errorf("failed to send overdue digest: %v", err)
+	}
+	return nil
+}
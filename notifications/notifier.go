@@ -0,0 +1,160 @@
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"AAHAOMS/OMS/mailer"
+	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/storage"
+)
+
+// Events a customer can be notified about
+const (
+	EventOrderCreated       = "order_created"
+	EventShipmentDispatched = "shipment_dispatched"
+	EventOrderShipped       = "order_shipped"
+)
+
+// Notifier emails customers about their orders and records every attempt
+// against the order, including ones skipped because of an opt-out.
+type Notifier struct {
+	Store  storage.Storage
+	Mailer mailer.Mailer
+}
+
+// NewNotifier creates a notifier
+func NewNotifier(store storage.Storage, m mailer.Mailer) *Notifier {
+	return &Notifier{Store: store, Mailer: m}
+}
+
+type emailData struct {
+	Customer  models.Customer
+	Order     models.Order
+	Shipment  models.Shipment
+	Shipped   []models.Item
+	Remaining []models.Item
+}
+
+// OrderCreated confirms a newly placed order
+func (n *Notifier) OrderCreated(orderID int) error {
+	data, err := n.load(orderID)
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("Order #%d received", orderID)
+	return n.send(EventOrderCreated, subject, data)
+}
+
+// ShipmentDispatched lists what went out in a shipment and what is still due
+func (n *Notifier) ShipmentDispatched(shipment models.Shipment) error {
+	data, err := n.load(shipment.OrderID)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[int]models.Item, len(data.Order.Items))
+	for _, item := range data.Order.Items {
+		byID[item.ID] = item
+	}
+
+	for _, shipped := range shipment.Items {
+		item := byID[shipped.ID]
+		item.Quantity = shipped.Quantity
+		data.Shipped = append(data.Shipped, item)
+	}
+
+	dueItems, err := n.Store.GetDueItems(shipment.OrderID)
+	if err != nil {
+		return err
+	}
+	// Split orders owe a line per destination; the customer sees the total
+	index := make(map[int]int)
+	for _, due := range dueItems {
+		i, seen := index[due.ItemID]
+		if !seen {
+			i = len(data.Remaining)
+			index[due.ItemID] = i
+			item := byID[due.ItemID]
+			item.Quantity = 0
+			data.Remaining = append(data.Remaining, item)
+		}
+		data.Remaining[i].Quantity += due.Quantity
+	}
+
+	data.Shipment = shipment
+	subject := fmt.Sprintf("Order #%d has been dispatched", shipment.OrderID)
+	return n.send(EventShipmentDispatched, subject, data)
+}
+
+// OrderShipped tells the customer nothing is left outstanding on the order
+func (n *Notifier) OrderShipped(orderID int) error {
+	data, err := n.load(orderID)
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("Order #%d fully shipped", orderID)
+	return n.send(EventOrderShipped, subject, data)
+}
+
+func (n *Notifier) load(orderID int) (*emailData, error) {
+	order, err := n.Store.GetOrderByID(orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load order %d: %v", orderID, err)
+	}
+
+	customer, err := n.Store.GetCustomerByID(strconv.Itoa(order.CustomerID))
+	if errors.Is(err, storage.ErrNotFound) {
+		customer = &models.Customer{ID: order.CustomerID, Name: order.CustomerName}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load customer %d: %v", order.CustomerID, err)
+	}
+
+	return &emailData{Customer: *customer, Order: order}, nil
+}
+
+func (n *Notifier) send(event, subject string, data *emailData) error {
+	record := models.OrderNotification{
+		OrderID:   data.Order.ID,
+		Event:     event,
+		Recipient: data.Customer.Email,
+		Subject:   subject,
+	}
+
+	switch {
+	case data.Customer.NotificationsOptOut:
+		record.Status = "skipped"
+		record.Error = "customer opted out"
+	case data.Customer.Email == "":
+		record.Status = "skipped"
+		record.Error = "customer has no email address"
+	default:
+		text, html, err := render(event, data)
+		if err != nil {
+			return err
+		}
+		err = n.Mailer.Send(mailer.Message{
+			To:      []string{data.Customer.Email},
+			Subject: subject,
+			Text:    text,
+			HTML:    html,
+		})
+		if err != nil {
+			record.Status = "failed"
+			record.Error = err.Error()
+		} else {
+			record.Status = "sent"
+		}
+	}
+
+	if err := n.Store.RecordOrderNotification(record); err != nil {
+		log.Printf("Error recording %s notification for order %d: %v", event, data.Order.ID, err)
+	}
+
+	if record.Status == "failed" {
+		return fmt.Errorf("failed to send %s email for order %d: %s", event, data.Order.ID, record.Error)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package notifications
+
+import (
+	"strings"
+	"testing"
+
+	"AAHAOMS/OMS/mailer"
+	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/storage"
+)
+
+// fakeStore serves one order and customer and records notifications. Any
+// other storage call panics on the nil embedded interface.
+type fakeStore struct {
+	storage.Storage
+	order    models.Order
+	customer models.Customer
+	due      []storage.DueItem
+	recorded []models.OrderNotification
+}
+
+func (s *fakeStore) GetOrderByID(orderID int) (models.Order, error) {
+	return s.order, nil
+}
+
+func (s *fakeStore) GetCustomerByID(id string) (*models.Customer, error) {
+	c := s.customer
+	return &c, nil
+}
+
+func (s *fakeStore) GetDueItems(orderID int) ([]storage.DueItem, error) {
+	return s.due, nil
+}
+
+func (s *fakeStore) RecordOrderNotification(n models.OrderNotification) error {
+	s.recorded = append(s.recorded, n)
+	return nil
+}
+
+func newTestNotifier() (*Notifier, *fakeStore, *mailer.MemoryMailer) {
+	store := &fakeStore{
+		order: models.Order{
+			ID:         42,
+			CustomerID: 7,
+			OrderDate:  "2026-10-01",
+			Items: []models.Item{
+				{ID: 1, Name: "Felt ball garland", Price: 12, Quantity: 3},
+				{ID: 2, Name: "Felt slippers", Price: 25, Quantity: 2},
+			},
+		},
+		customer: models.Customer{ID: 7, Name: "Sita Rai", Email: "sita@example.com"},
+	}
+	m := mailer.NewMemoryMailer()
+	return NewNotifier(store, m), store, m
+}
+
+func TestShipmentDispatchedPartial(t *testing.T) {
+	n, store, m := newTestNotifier()
+	destination := 5
+	store.due = []storage.DueItem{
+		{ItemID: 2, DestinationID: &destination, Quantity: 1},
+		{ItemID: 2, Quantity: 1},
+	}
+
+	err := n.ShipmentDispatched(models.Shipment{
+		OrderID:     42,
+		ShippedDate: "2026-10-18",
+		Items:       []models.Item{{ID: 1, Quantity: 3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := m.Messages()
+	if len(sent) != 1 {
+		t.Fatalf("got %d messages, want 1", len(sent))
+	}
+	msg := sent[0]
+	if len(msg.To) != 1 || msg.To[0] != "sita@example.com" {
+		t.Errorf("sent to %v", msg.To)
+	}
+	if msg.Subject != "Order #42 has been dispatched" {
+		t.Errorf("subject %q", msg.Subject)
+	}
+	for _, want := range []string{
+		"Dear Sita Rai,",
+		"Part of your order #42 was dispatched",
+		"Felt ball garland x 3",
+		"Still to follow:",
+		"Felt slippers x 2",
+	} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("text is missing %q:\n%s", want, msg.Text)
+		}
+	}
+	for _, body := range []string{msg.Text, msg.HTML} {
+		if strings.Contains(body, "Reply to this email") {
+			t.Errorf("body still offers to unsubscribe by reply:\n%s", body)
+		}
+	}
+
+	if len(store.recorded) != 1 || store.recorded[0].Status != "sent" || store.recorded[0].Event != EventShipmentDispatched {
+		t.Errorf("recorded %+v", store.recorded)
+	}
+}
+
+func TestShipmentDispatchedRest(t *testing.T) {
+	n, _, m := newTestNotifier()
+
+	err := n.ShipmentDispatched(models.Shipment{
+		OrderID:      42,
+		ShippedDate:  "2026-10-18",
+		DueOrderType: true,
+		Items:        []models.Item{{ID: 2, Quantity: 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := m.Messages()[0].Text
+	for _, want := range []string{"The rest of your order #42", "Nothing remains outstanding on this order."} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text is missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestOptedOutCustomerIsSkipped(t *testing.T) {
+	n, store, m := newTestNotifier()
+	store.customer.NotificationsOptOut = true
+
+	if err := n.OrderCreated(42); err != nil {
+		t.Fatal(err)
+	}
+	if sent := m.Messages(); len(sent) != 0 {
+		t.Errorf("sent %d messages to an opted-out customer", len(sent))
+	}
+	if len(store.recorded) != 1 || store.recorded[0].Status != "skipped" {
+		t.Errorf("recorded %+v, want one skipped notification", store.recorded)
+	}
+}
@@ -0,0 +1,85 @@
+package notifications
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"AAHAOMS/OMS/carriers"
+	"AAHAOMS/OMS/models"
+)
+
+//go:embed templates/*
+var templateFS embed.FS
+
+var funcs = map[string]any{
+	"date":      formatDate,
+	"money":     func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"lineTotal": func(item models.Item) float64 { return item.Price * float64(item.Quantity) },
+	"describe":  describeItem,
+	"days":      formatDays,
+	"carrier":   carriers.Name,
+}
+
+// render produces the text and HTML bodies for the named customer email
+func render(name string, data any) (string, string, error) {
+	return renderWithLayout("layout.html", name, data)
+}
+
+// renderWithLayout renders the named template inside the given HTML layout,
+// which must define "layout"
+func renderWithLayout(layout, name string, data any) (string, string, error) {
+	textTmpl, err := texttemplate.New(name+".txt").Funcs(funcs).ParseFS(templateFS, "templates/"+name+".txt")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse %s text template: %v", name, err)
+	}
+	var text bytes.Buffer
+	if err := textTmpl.Execute(&text, data); err != nil {
+		return "", "", fmt.Errorf("failed to render %s text template: %v", name, err)
+	}
+
+	htmlTmpl, err := htmltemplate.New("layout").Funcs(funcs).ParseFS(templateFS, "templates/"+layout, "templates/"+name+".html")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse %s html template: %v", name, err)
+	}
+	var html bytes.Buffer
+	if err := htmlTmpl.ExecuteTemplate(&html, "layout", data); err != nil {
+		return "", "", fmt.Errorf("failed to render %s html template: %v", name, err)
+	}
+
+	return text.String(), html.String(), nil
+}
+
+// formatDate trims the time part postgres adds to DATE columns
+func formatDate(s string) string {
+	if len(s) >= 10 {
+		return s[:10]
+	}
+	return s
+}
+
+func describeItem(item models.Item) string {
+	var extras []string
+	if item.Size != nil && *item.Size != "" {
+		extras = append(extras, *item.Size)
+	}
+	if item.Color != nil && *item.Color != "" {
+		extras = append(extras, *item.Color)
+	}
+	if len(extras) == 0 {
+		return item.Name
+	}
+	return fmt.Sprintf("%s (%s)", item.Name, strings.Join(extras, ", "))
+}
+
+// formatDays prints an optional day count, as used for days overdue
+func formatDays(days *int) string {
+	if days == nil {
+		return "-"
+	}
+	return strconv.Itoa(*days)
+}
@@ -0,0 +1,120 @@
+// Package pdf writes simple printable documents: text in the standard
+// Helvetica fonts, lines and tables on A4 pages. It covers what our pick
+// lists and shipping documents need without a third-party library.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+)
+
+// A4 page size and the margin content is laid out within, in points
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+	Margin     = 40.0
+)
+
+// Document is a PDF being built page by page. Coordinates are in points
+// from the top left corner of the page.
+type Document struct {
+	Title string
+
+	pages []*bytes.Buffer
+}
+
+// New starts a document with one empty page
+func New(title string) *Document {
+	d := &Document{Title: title}
+	d.AddPage()
+	return d
+}
+
+// AddPage starts a new page; everything drawn afterwards goes on it
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+func (d *Document) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// Text draws s with its baseline at y
+func (d *Document) Text(x, y float64, f Font, size float64, s string) {
+	fmt.Fprintf(d.page(), "BT /F%d %.2f Tf %.2f %.2f Td (%s) Tj ET\n", int(f)+1, size, x, PageHeight-y, escape(s))
+}
+
+// TextRight draws s so that it ends at x
+func (d *Document) TextRight(x, y float64, f Font, size float64, s string) {
+	d.Text(x-TextWidth(f, size, s), y, f, size, s)
+}
+
+// Line draws a thin line
+func (d *Document) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, PageHeight-y1, x2, PageHeight-y2)
+}
+
+// Rect outlines a rectangle whose top left corner is at x, y
+func (d *Document) Rect(x, y, w, h float64) {
+	fmt.Fprintf(d.page(), "0.5 w %.2f %.2f %.2f %.2f re S\n", x, PageHeight-y-h, w, h)
+}
+
+// WriteTo writes the finished document
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1: catalog, 2: page tree, 3-4: fonts, 5: info, then a page and its
+	// contents for each page
+	const firstPage = 6
+	kids := &bytes.Buffer{}
+	for i := range d.pages {
+		fmt.Fprintf(kids, "%d 0 R ", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(d.pages)))
+	for _, name := range fontNames {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+	}
+	object(fmt.Sprintf("<< /Title (%s) /Producer (OMS) >>", escape(d.Title)))
+
+	for i, p := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			PageWidth, PageHeight, firstPage+2*i+1))
+
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(p.Bytes())
+		zw.Close()
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", z.Len(), z.Bytes()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.WriteTo(w)
+}
+
+// escape encodes s for a PDF string literal
+func escape(s string) string {
+	var b bytes.Buffer
+	for _, c := range encode(s) {
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,137 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func renderTable(t *testing.T, rows int) []byte {
+	t.Helper()
+	d := New("Pick list (test)")
+	d.Text(Margin, Margin, HelveticaBold, 16, "Pick List")
+	cells := make([][]string, rows)
+	for i := range cells {
+		cells[i] = []string{strconv.Itoa(i + 1), fmt.Sprintf("Felt ball garland %d", i+1)}
+	}
+	d.Table(Margin+20, []Column{{Title: "No", Width: 40, Right: true}, {Title: "Item", Width: 200}}, cells)
+
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+var (
+	startxrefRe = regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`)
+	streamRe    = regexp.MustCompile(`<< /Length (\d+) /Filter /FlateDecode >>\nstream\n`)
+)
+
+// pageContents inflates every page's content stream, in page order
+func pageContents(t *testing.T, doc []byte) []string {
+	t.Helper()
+	var pages []string
+	for _, m := range streamRe.FindAllSubmatchIndex(doc, -1) {
+		length, _ := strconv.Atoi(string(doc[m[2]:m[3]]))
+		r, err := zlib.NewReader(bytes.NewReader(doc[m[1] : m[1]+length]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, string(content))
+	}
+	return pages
+}
+
+func TestMultiPageTable(t *testing.T) {
+	doc := renderTable(t, 120)
+
+	if !bytes.HasPrefix(doc, []byte("%PDF-1.4\n")) {
+		t.Fatalf("missing header: %q", doc[:16])
+	}
+
+	// Rows are 15.3pt and a page holds about 49 of them, so 120 rows and
+	// the heading take three pages
+	if !bytes.Contains(doc, []byte("/Count 3 >>")) {
+		t.Errorf("page tree should count 3 pages")
+	}
+	if n := bytes.Count(doc, []byte("/Type /Page /Parent")); n != 3 {
+		t.Errorf("got %d page objects, want 3", n)
+	}
+
+	pages := pageContents(t, doc)
+	if len(pages) != 3 {
+		t.Fatalf("got %d content streams, want 3", len(pages))
+	}
+	for i, p := range pages {
+		if !strings.Contains(p, "(Item) Tj") {
+			t.Errorf("page %d doesn't repeat the header", i+1)
+		}
+	}
+	for _, row := range []string{"(Felt ball garland 1) Tj", "(Felt ball garland 120) Tj"} {
+		if !strings.Contains(strings.Join(pages, ""), row) {
+			t.Errorf("missing %s", row)
+		}
+	}
+}
+
+func TestXrefOffsets(t *testing.T) {
+	doc := renderTable(t, 120)
+
+	m := startxrefRe.FindSubmatch(doc)
+	if m == nil {
+		t.Fatalf("no startxref trailer:\n%s", doc[len(doc)-80:])
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(doc[xref:], []byte("xref\n0 ")) {
+		t.Fatalf("startxref %d doesn't point at the xref table", xref)
+	}
+
+	lines := strings.Split(string(doc[xref:]), "\n")
+	var first, count int
+	if _, err := fmt.Sscanf(lines[1], "%d %d", &first, &count); err != nil {
+		t.Fatal(err)
+	}
+	// catalog, pages, 2 fonts, info and a page and its contents per page
+	if want := 5 + 2*3 + 1; count != want {
+		t.Errorf("xref has %d entries, want %d", count, want)
+	}
+	if !strings.Contains(string(doc), fmt.Sprintf("/Size %d ", count)) {
+		t.Errorf("trailer /Size doesn't match the %d xref entries", count)
+	}
+
+	for n := 1; n < count; n++ {
+		entry := lines[2+n]
+		if len(entry) != 19 || !strings.HasSuffix(entry, " 00000 n ") {
+			t.Fatalf("object %d: malformed entry %q", n, entry)
+		}
+		offset, _ := strconv.Atoi(entry[:10])
+		if want := fmt.Sprintf("%d 0 obj\n", n); !bytes.HasPrefix(doc[offset:], []byte(want)) {
+			t.Errorf("object %d: offset %d points at %q", n, offset, doc[offset:min(offset+12, len(doc))])
+		}
+	}
+}
+
+func TestEscapeAndFit(t *testing.T) {
+	if got := escape(`a (b) \c`); got != `a \(b\) \\c` {
+		t.Errorf("escape: got %q", got)
+	}
+
+	s := "Hand felted wool ball garland in rainbow colours"
+	got := fit(Helvetica, TableFontSize, s, 100)
+	if !strings.HasSuffix(got, "...") || TextWidth(Helvetica, TableFontSize, got) > 100 {
+		t.Errorf("fit: got %q (%.1fpt)", got, TextWidth(Helvetica, TableFontSize, got))
+	}
+	if got := fit(Helvetica, TableFontSize, "Slippers", 100); got != "Slippers" {
+		t.Errorf("fit shortened text that fits: %q", got)
+	}
+}
@@ -0,0 +1,85 @@
+package pdf
+
+// Column is one column of a table. Right aligns its cells, e.g. for numbers.
+type Column struct {
+	Title string
+	Width float64
+	Right bool
+}
+
+// TableFontSize is the size table cells are set in
+const TableFontSize = 9
+
+const rowHeight = TableFontSize * 1.7
+
+// EnsureSpace starts a new page when h points don't fit below y, and
+// returns where to continue drawing
+func (d *Document) EnsureSpace(y, h float64) float64 {
+	if y+h > PageHeight-Margin {
+		d.AddPage()
+		return Margin
+	}
+	return y
+}
+
+// Table draws rows under a bold header row starting at y, repeating the
+// header on each new page, and returns the y below the table. Cells too
+// wide for their column are cut short.
+func (d *Document) Table(y float64, cols []Column, rows [][]string) float64 {
+	header := func(y float64) float64 {
+		d.row(y, cols, nil, HelveticaBold)
+		y += rowHeight
+		d.Line(Margin, y-rowHeight*0.3, Margin+tableWidth(cols), y-rowHeight*0.3)
+		return y
+	}
+
+	y = header(d.EnsureSpace(y, 2*rowHeight))
+	for _, cells := range rows {
+		if next := d.EnsureSpace(y, rowHeight); next != y {
+			y = header(next)
+		}
+		d.row(y, cols, cells, Helvetica)
+		y += rowHeight
+	}
+	return y
+}
+
+func (d *Document) row(y float64, cols []Column, cells []string, f Font) {
+	x := Margin
+	for i, c := range cols {
+		text := c.Title
+		if cells != nil {
+			text = ""
+			if i < len(cells) {
+				text = cells[i]
+			}
+		}
+		text = fit(f, TableFontSize, text, c.Width-6)
+		if c.Right {
+			d.TextRight(x+c.Width-3, y+TableFontSize, f, TableFontSize, text)
+		} else {
+			d.Text(x+3, y+TableFontSize, f, TableFontSize, text)
+		}
+		x += c.Width
+	}
+}
+
+func tableWidth(cols []Column) float64 {
+	var w float64
+	for _, c := range cols {
+		w += c.Width
+	}
+	return w
+}
+
+// fit shortens s with an ellipsis until it is at most width wide
+func fit(f Font, size float64, s string, width float64) string {
+	if TextWidth(f, size, s) <= width {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && TextWidth(f, size, string(r)+"...") > width {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "..."
+}
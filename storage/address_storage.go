@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"database/sql"
+	"fmt"
+)
+
+const addressColumns = `id, customer_id, type, label, line1, line2, city, region, postcode, country, is_default, unverified_country`
+
+func scanAddress(row rowScanner) (models.CustomerAddress, error) {
+	var a models.CustomerAddress
+	err := row.Scan(&a.ID, &a.CustomerID, &a.Type, &a.Label, &a.Line1, &a.Line2, &a.City, &a.Region, &a.Postcode, &a.Country, &a.IsDefault,
+		&a.UnverifiedCountry)
+	return a, err
+}
+
+// GetCustomerAddresses lists a customer's addresses, defaults first within each type
+func (s *PostgresStorage) GetCustomerAddresses(customerID int) ([]models.CustomerAddress, error) {
+	rows, err := s.DB.Query(`
+		SELECT `+addressColumns+`
+		FROM customer_addresses
+		WHERE customer_id = $1
+		ORDER BY type, is_default DESC, id
+	`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch addresses for customer %d: %v", customerID, err)
+	}
+	defer rows.Close()
+
+	addresses := []models.CustomerAddress{}
+	for rows.Next() {
+		a, err := scanAddress(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan address: %v", err)
+		}
+		addresses = append(addresses, a)
+	}
+	return addresses, rows.Err()
+}
+
+func (s *PostgresStorage) GetCustomerAddress(customerID, id int) (models.CustomerAddress, error) {
+	return getCustomerAddress(s.DB, customerID, id)
+}
+
+func getCustomerAddress(db queryRower, customerID, id int) (models.CustomerAddress, error) {
+	a, err := scanAddress(db.QueryRow(`SELECT `+addressColumns+` FROM customer_addresses WHERE id = $1 AND customer_id = $2`, id, customerID))
+	if err != nil {
+		return models.CustomerAddress{}, notFound(err, "address %d for customer %d", id, customerID)
+	}
+	return a, nil
+}
+
+// CreateCustomerAddress adds an address. The first address of a type
+// becomes the default, and a new default replaces the old one.
+func (s *PostgresStorage) CreateCustomerAddress(a models.CustomerAddress) (int, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if !a.IsDefault {
+		var hasDefault bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM customer_addresses WHERE customer_id = $1 AND type = $2 AND is_default)`, a.CustomerID, a.Type).Scan(&hasDefault)
+		if err != nil {
+			return 0, err
+		}
+		a.IsDefault = !hasDefault
+	}
+	if err := clearDefaultAddress(tx, a); err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO customer_addresses (customer_id, type, label, line1, line2, city, region, postcode, country, is_default)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`, a.CustomerID, a.Type, a.Label, a.Line1, a.Line2, a.City, a.Region, a.Postcode, a.Country, a.IsDefault).Scan(&id)
+	if err != nil {
+		return 0, classify(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return id, nil
+}
+
+func (s *PostgresStorage) UpdateCustomerAddress(a models.CustomerAddress) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := clearDefaultAddress(tx, a); err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(`
+		UPDATE customer_addresses
+		SET type = $1, label = $2, line1 = $3, line2 = $4, city = $5, region = $6, postcode = $7, country = $8, is_default = $9,
+			unverified_country = ''
+		WHERE id = $10 AND customer_id = $11
+	`, a.Type, a.Label, a.Line1, a.Line2, a.City, a.Region, a.Postcode, a.Country, a.IsDefault, a.ID, a.CustomerID)
+	if err != nil {
+		return classify(err)
+	}
+	if err := requireRow(res, "address %d for customer %d", a.ID, a.CustomerID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Orders keep their snapshot of a deleted address; only the link is cleared
+func (s *PostgresStorage) DeleteCustomerAddress(customerID, id int) error {
+	res, err := s.DB.Exec(`DELETE FROM customer_addresses WHERE id = $1 AND customer_id = $2`, id, customerID)
+	if err != nil {
+		return err
+	}
+	return requireRow(res, "address %d for customer %d", id, customerID)
+}
+
+// clearDefaultAddress unsets the current default when a is about to become it
+func clearDefaultAddress(tx *sql.Tx, a models.CustomerAddress) error {
+	if !a.IsDefault {
+		return nil
+	}
+	_, err := tx.Exec(`
+		UPDATE customer_addresses SET is_default = FALSE
+		WHERE customer_id = $1 AND type = $2 AND is_default AND id <> $3
+	`, a.CustomerID, a.Type, a.ID)
+	if err != nil {
+		return fmt.Errorf("failed to clear default address: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"fmt"
+)
+
+func (s *PostgresStorage) RecordAudit(e models.AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (actor, remote_addr, method, route, entity_type, entity_id, action, before, after, diff, actor_hint)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''))
+	`
+	_, err := s.DB.Exec(query, e.Actor, e.RemoteAddr, e.Method, e.Route, e.EntityType, e.EntityID, e.Action,
+		nullJSON(e.Before), nullJSON(e.After), nullJSON(e.Diff), e.ActorHint)
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetAuditEntries(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+	entries := []models.AuditEntry{}
+	err := s.StreamAuditEntries(filter, func(e models.AuditEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	return entries, err
+}
+
+// StreamAuditEntries calls fn for every matching entry, oldest first unless
+// the filter asks for newest first, without holding the whole result in
+// memory. A zero Limit means no limit.
+func (s *PostgresStorage) StreamAuditEntries(filter models.AuditFilter, fn func(models.AuditEntry) error) error {
+	query := `
+		SELECT id, actor, COALESCE(actor_hint, ''), COALESCE(remote_addr, ''), occurred_at, method, route, entity_type, entity_id, action,
+		       COALESCE(before, 'null'), COALESCE(after, 'null'), COALESCE(diff, 'null')
+		FROM audit_log
+		WHERE ($1 = '' OR entity_type = $1)
+		  AND ($2 = '' OR entity_id = $2)
+		  AND ($3 = '' OR actor = $3)
+		  AND ($4 = '' OR occurred_at >= NULLIF($4, '')::date)
+		  AND ($5 = '' OR occurred_at < NULLIF($5, '')::date + 1)
+		  AND ($6 = 0 OR id < $6)
+	`
+	if filter.NewestFirst {
+		query += ` ORDER BY id DESC`
+	} else {
+		query += ` ORDER BY occurred_at, id`
+	}
+	args := []any{filter.EntityType, filter.EntityID, filter.Actor, filter.From, filter.To, filter.BeforeID}
+	if filter.Limit > 0 {
+		query += ` LIMIT $7`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to fetch audit log: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.AuditEntry
+		var before, after, diff []byte
+		err := rows.Scan(&e.ID, &e.Actor, &e.ActorHint, &e.RemoteAddr, &e.OccurredAt, &e.Method, &e.Route, &e.EntityType,
+			&e.EntityID, &e.Action, &before, &after, &diff)
+		if err != nil {
+			return fmt.Errorf("failed to scan audit entry: %v", err)
+		}
+		e.Before, e.After, e.Diff = jsonOrNil(before), jsonOrNil(after), jsonOrNil(diff)
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func nullJSON(b []byte) any {
+	if len(b) == 0 || string(b) == "null" {
+		return nil
+	}
+	return b
+}
+
+func jsonOrNil(b []byte) []byte {
+	if string(b) == "null" {
+		return nil
+	}
+	return b
+}
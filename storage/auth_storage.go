@@ -1,6 +1,9 @@
 package storage
 
-import "AAHAOMS/OMS/models"
+import (
+	"AAHAOMS/OMS/models"
+	"database/sql"
+)
 
 func (s *PostgresStorage) AddAuthUser(user models.AuthUser) error {
 	query := `INSERT INTO auth_users (email) VALUES ($1) ON CONFLICT (email) DO NOTHING`
@@ -28,6 +31,17 @@ func (s *PostgresStorage) VerifyOtp(user models.AuthUser) (bool, error) {
 	return exists, err
 }
 
+// GetKeyEmail returns the email a login key was issued to, or "" when the
+// key is unknown
+func (s *PostgresStorage) GetKeyEmail(token string) (string, error) {
+	var email string
+	err := s.DB.QueryRow(`SELECT email FROM otp WHERE key = $1 LIMIT 1`, token).Scan(&email)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return email, err
+}
+
 func (s *PostgresStorage) IsKeyInStorage(token string) (bool, error) {
 	query := `SELECT EXISTS (SELECT 1 FROM otp WHERE key = $1)`
 	var exists bool
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"database/sql"
+	"fmt"
+)
+
+// GetBacklog lists pending and shipped and due orders with what is still to
+// ship, most overdue first. Pending orders owe every item; partly shipped
+// ones owe what is left in due_orders.
+func (s *PostgresStorage) GetBacklog(filter models.BacklogFilter) (models.BacklogReport, error) {
+	report := models.BacklogReport{AsOf: filter.AsOf, Orders: []models.BacklogOrder{}}
+	for _, b := range models.AgingBuckets {
+		report.Buckets = append(report.Buckets, models.BacklogBucket{Bucket: b})
+	}
+
+	rows, err := s.DB.Query(`
+		WITH open_orders AS (
+			SELECT o.id, COALESCE(o.customer_id, 0) AS customer_id, COALESCE(o.customer_name, '') AS customer_name,
+				o.order_date, o.shipment_due, TRIM(o.order_status) AS status,
+				$1::date - o.shipment_due AS days_overdue
+			FROM orders o
+			WHERE TRIM(o.order_status) IN ($4, $5)
+				AND ($2 = 0 OR o.customer_id = $2)
+		), outstanding AS (
+			SELECT oo.id AS order_id, COALESCE(oi.price, 0) AS price,
+				CASE WHEN oo.status = $4 THEN oi.quantity ELSE COALESCE(d.quantity, 0) END AS quantity
+			FROM open_orders oo
+			JOIN order_items oi ON oi.order_id = oo.id
+			LEFT JOIN (
+				SELECT item_id, SUM(quantity) AS quantity
+				FROM due_orders
+				WHERE order_id IN (SELECT id FROM open_orders)
+				GROUP BY item_id
+			) d ON d.item_id = oi.id
+		)
+		SELECT oo.id, oo.customer_id, oo.customer_name, TO_CHAR(oo.order_date, 'YYYY-MM-DD'),
+			COALESCE(TO_CHAR(oo.shipment_due, 'YYYY-MM-DD'), ''), oo.status, oo.days_overdue,
+			COALESCE(SUM(x.quantity), 0), COALESCE(SUM(x.quantity * x.price), 0)
+		FROM open_orders oo
+		LEFT JOIN outstanding x ON x.order_id = oo.id
+		WHERE NOT $3 OR oo.days_overdue > 0
+		GROUP BY oo.id, oo.customer_id, oo.customer_name, oo.order_date, oo.shipment_due, oo.status, oo.days_overdue
+		ORDER BY oo.days_overdue DESC NULLS LAST, oo.id
+	`, filter.AsOf, filter.CustomerID, filter.OverdueOnly, models.OrderPending, models.OrderShippedAndDue)
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch backlog: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var o models.BacklogOrder
+		var days sql.NullInt64
+		err := rows.Scan(&o.OrderID, &o.CustomerID, &o.CustomerName, &o.OrderDate, &o.ShipmentDue, &o.OrderStatus,
+			&days, &o.OutstandingQuantity, &o.OutstandingValue)
+		if err != nil {
+			return report, fmt.Errorf("failed to scan backlog order: %v", err)
+		}
+		if days.Valid {
+			d := int(days.Int64)
+			o.DaysOverdue = &d
+		}
+		report.AddOrder(o)
+	}
+	return report, rows.Err()
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"database/sql"
+	"fmt"
+)
+
+const contactColumns = `id, customer_id, name, role, email, phone, is_primary`
+
+func scanContact(row rowScanner) (models.CustomerContact, error) {
+	var c models.CustomerContact
+	err := row.Scan(&c.ID, &c.CustomerID, &c.Name, &c.Role, &c.Email, &c.Phone, &c.IsPrimary)
+	return c, err
+}
+
+// GetCustomerContacts lists a customer's contacts, primary first
+func (s *PostgresStorage) GetCustomerContacts(customerID int) ([]models.CustomerContact, error) {
+	rows, err := s.DB.Query(`
+		SELECT `+contactColumns+`
+		FROM customer_contacts
+		WHERE customer_id = $1
+		ORDER BY is_primary DESC, id
+	`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch contacts for customer %d: %v", customerID, err)
+	}
+	defer rows.Close()
+
+	contacts := []models.CustomerContact{}
+	for rows.Next() {
+		c, err := scanContact(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %v", err)
+		}
+		contacts = append(contacts, c)
+	}
+	return contacts, rows.Err()
+}
+
+func (s *PostgresStorage) GetCustomerContact(customerID, id int) (models.CustomerContact, error) {
+	c, err := scanContact(s.DB.QueryRow(`SELECT `+contactColumns+` FROM customer_contacts WHERE id = $1 AND customer_id = $2`, id, customerID))
+	if err != nil {
+		return models.CustomerContact{}, notFound(err, "contact %d for customer %d", id, customerID)
+	}
+	return c, nil
+}
+
+// CreateCustomerContact adds a contact. A new primary contact replaces the old one.
+func (s *PostgresStorage) CreateCustomerContact(c models.CustomerContact) (int, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := clearPrimaryContact(tx, c); err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO customer_contacts (customer_id, name, role, email, phone, is_primary)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, c.CustomerID, c.Name, c.Role, c.Email, c.Phone, c.IsPrimary).Scan(&id)
+	if err != nil {
+		return 0, classify(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return id, nil
+}
+
+func (s *PostgresStorage) UpdateCustomerContact(c models.CustomerContact) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := clearPrimaryContact(tx, c); err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(`
+		UPDATE customer_contacts
+		SET name = $1, role = $2, email = $3, phone = $4, is_primary = $5
+		WHERE id = $6 AND customer_id = $7
+	`, c.Name, c.Role, c.Email, c.Phone, c.IsPrimary, c.ID, c.CustomerID)
+	if err != nil {
+		return classify(err)
+	}
+	if err := requireRow(res, "contact %d for customer %d", c.ID, c.CustomerID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStorage) DeleteCustomerContact(customerID, id int) error {
+	res, err := s.DB.Exec(`DELETE FROM customer_contacts WHERE id = $1 AND customer_id = $2`, id, customerID)
+	if err != nil {
+		return err
+	}
+	return requireRow(res, "contact %d for customer %d", id, customerID)
+}
+
+// clearPrimaryContact unsets the current primary contact when c is about to become it
+func clearPrimaryContact(tx *sql.Tx, c models.CustomerContact) error {
+	if !c.IsPrimary {
+		return nil
+	}
+	_, err := tx.Exec(`
+		UPDATE customer_contacts SET is_primary = FALSE
+		WHERE customer_id = $1 AND is_primary AND id <> $2
+	`, c.CustomerID, c.ID)
+	if err != nil {
+		return fmt.Errorf("failed to clear primary contact: %v", err)
+	}
+	return nil
+}
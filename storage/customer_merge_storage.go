@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"database/sql"
+	"fmt"
+)
+
+// FindDuplicateCustomers pairs customers whose names are at least threshold
+// similar (trigrams ignore case and punctuation), or who share an email or
+// phone number. A non-zero customerID limits the pairs to that customer.
+// Similar names are found with the % operator so the trigram index on
+// customers.name is used rather than comparing every pair.
+func (s *PostgresStorage) FindDuplicateCustomers(customerID int, threshold float64, limit int) ([]models.DuplicateCandidate, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// % matches at pg_trgm's similarity threshold; set it for this transaction
+	if _, err := tx.Exec(`SELECT set_config('pg_trgm.similarity_threshold', $1, TRUE)`, fmt.Sprint(threshold)); err != nil {
+		return nil, fmt.Errorf("failed to set similarity threshold: %v", err)
+	}
+
+	query := `
+		WITH c AS (
+			SELECT id, name, COALESCE(number, '') AS number, COALESCE(email, '') AS email,
+				COALESCE(country, '') AS country, COALESCE(address, '') AS address, notifications_opt_out,
+				NULLIF(LOWER(TRIM(email)), '') AS norm_email,
+				NULLIF(REGEXP_REPLACE(COALESCE(number, ''), '[^0-9]', '', 'g'), '') AS norm_phone
+			FROM customers
+		), candidates AS (
+			SELECT a.id AS a_id, b.id AS b_id
+			FROM customers a
+			JOIN customers b ON a.name % b.name AND a.id < b.id
+			WHERE $1 = 0 OR a.id = $1 OR b.id = $1
+			UNION
+			SELECT a.id, b.id
+			FROM c a
+			JOIN c b ON a.norm_email = b.norm_email AND a.id < b.id
+			WHERE $1 = 0 OR a.id = $1 OR b.id = $1
+			UNION
+			SELECT a.id, b.id
+			FROM c a
+			JOIN c b ON a.norm_phone = b.norm_phone AND a.id < b.id
+			WHERE $1 = 0 OR a.id = $1 OR b.id = $1
+		), pairs AS (
+			SELECT a.*, b.id AS b_id, b.name AS b_name, b.number AS b_number, b.email AS b_email,
+				b.country AS b_country, b.address AS b_address, b.notifications_opt_out AS b_opt_out,
+				similarity(a.name, b.name) AS name_score,
+				a.norm_email = b.norm_email AS same_email,
+				a.norm_phone = b.norm_phone AS same_phone
+			FROM candidates p
+			JOIN c a ON a.id = p.a_id
+			JOIN c b ON b.id = p.b_id
+		)
+		SELECT id, name, number, email, country, address, notifications_opt_out,
+			b_id, b_name, b_number, b_email, b_country, b_address, b_opt_out,
+			name_score, COALESCE(same_email, FALSE), COALESCE(same_phone, FALSE)
+		FROM pairs
+		WHERE name_score >= $2 OR same_email OR same_phone
+		ORDER BY (name_score + CASE WHEN same_email THEN 1 ELSE 0 END + CASE WHEN same_phone THEN 1 ELSE 0 END) DESC, id, b_id
+		LIMIT $3
+	`
+	rows, err := tx.Query(query, customerID, threshold, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicate customers: %v", err)
+	}
+	defer rows.Close()
+
+	candidates := []models.DuplicateCandidate{}
+	for rows.Next() {
+		var d models.DuplicateCandidate
+		var sameEmail, samePhone bool
+		a, b := &d.Customer, &d.Duplicate
+		err := rows.Scan(&a.ID, &a.Name, &a.Number, &a.Email, &a.Country, &a.Address, &a.NotificationsOptOut,
+			&b.ID, &b.Name, &b.Number, &b.Email, &b.Country, &b.Address, &b.NotificationsOptOut,
+			&d.Score, &sameEmail, &samePhone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate pair: %v", err)
+		}
+
+		d.Reasons = []string{}
+		if d.Score >= threshold {
+			d.Reasons = append(d.Reasons, "similar_name")
+		}
+		if sameEmail {
+			d.Reasons = append(d.Reasons, "same_email")
+			d.Score++
+		}
+		if samePhone {
+			d.Reasons = append(d.Reasons, "same_phone")
+			d.Score++
+		}
+		candidates = append(candidates, d)
+	}
+	return candidates, rows.Err()
+}
+
+// MergeCustomers folds the source customer into the target in a single
+// transaction: orders (and so their shipments), addresses and contacts move
+// over, blank contact fields on the target are filled from the source, and
+// the source is deleted and recorded in customer_merges.
+func (s *PostgresStorage) MergeCustomers(targetID, sourceID int, actor string) (models.CustomerMerge, error) {
+	merge := models.CustomerMerge{TargetID: targetID, SourceID: sourceID, Actor: actor}
+	if targetID == sourceID {
+		return merge, fmt.Errorf("a customer can't be merged into itself: %w", ErrValidation)
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return merge, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Lock both rows in ID order so concurrent merges can't deadlock
+	rows, err := tx.Query(`SELECT id FROM customers WHERE id IN ($1, $2) ORDER BY id FOR UPDATE`, targetID, sourceID)
+	if err != nil {
+		return merge, fmt.Errorf("failed to lock customers: %v", err)
+	}
+	found := 0
+	for rows.Next() {
+		found++
+	}
+	rows.Close()
+	if found < 2 {
+		return merge, fmt.Errorf("customer %d or %d: %w", targetID, sourceID, ErrNotFound)
+	}
+
+	var targetName string
+	if err := tx.QueryRow(`SELECT name FROM customers WHERE id = $1`, targetID).Scan(&targetName); err != nil {
+		return merge, err
+	}
+	err = tx.QueryRow(`SELECT name, row_to_json(c) FROM customers c WHERE id = $1`, sourceID).Scan(&merge.SourceName, &merge.Source)
+	if err != nil {
+		return merge, fmt.Errorf("failed to snapshot customer %d: %v", sourceID, err)
+	}
+
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM shipments s JOIN orders o ON s.order_id = o.id WHERE o.customer_id = $1
+	`, sourceID).Scan(&merge.ShipmentsMoved)
+	if err != nil {
+		return merge, fmt.Errorf("failed to count shipments: %v", err)
+	}
+
+	moves := []struct {
+		n     *int
+		query string
+		args  []any
+	}{
+		{&merge.OrdersMoved, `UPDATE orders SET customer_id = $1, customer_name = $3 WHERE customer_id = $2`,
+			[]any{targetID, sourceID, targetName}},
+		// The target keeps its own defaults; source defaults only survive
+		// for types the target has none of
+		{&merge.AddressesMoved, `
+			UPDATE customer_addresses a SET customer_id = $1,
+				is_default = a.is_default AND NOT EXISTS (
+					SELECT 1 FROM customer_addresses t WHERE t.customer_id = $1 AND t.type = a.type AND t.is_default)
+			WHERE a.customer_id = $2`, []any{targetID, sourceID}},
+		{&merge.ContactsMoved, `
+			UPDATE customer_contacts k SET customer_id = $1,
+				is_primary = k.is_primary AND NOT EXISTS (
+					SELECT 1 FROM customer_contacts t WHERE t.customer_id = $1 AND t.is_primary)
+			WHERE k.customer_id = $2`, []any{targetID, sourceID}},
+	}
+	for _, m := range moves {
+		res, err := tx.Exec(m.query, m.args...)
+		if err != nil {
+			return merge, fmt.Errorf("failed to move customer records: %v", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return merge, err
+		}
+		*m.n = int(n)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE customers t SET
+			number = COALESCE(NULLIF(t.number, ''), s.number),
+			email = COALESCE(NULLIF(t.email, ''), s.email),
+			country = COALESCE(NULLIF(t.country, ''), s.country),
+			address = COALESCE(NULLIF(t.address, ''), s.address),
+			notifications_opt_out = t.notifications_opt_out OR s.notifications_opt_out
+		FROM customers s
+		WHERE t.id = $1 AND s.id = $2
+	`, targetID, sourceID)
+	if err != nil {
+		return merge, fmt.Errorf("failed to update customer %d: %v", targetID, err)
+	}
+
+	// Customers merged into the source earlier now belong to the target's history
+	if _, err := tx.Exec(`UPDATE customer_merges SET target_id = $1 WHERE target_id = $2`, targetID, sourceID); err != nil {
+		return merge, fmt.Errorf("failed to move merge history: %v", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM customers WHERE id = $1`, sourceID); err != nil {
+		return merge, fmt.Errorf("failed to delete customer %d: %v", sourceID, err)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO customer_merges (target_id, source_id, source_name, source, orders_moved, shipments_moved, addresses_moved, contacts_moved, actor)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, merged_at
+	`, targetID, sourceID, merge.SourceName, []byte(merge.Source), merge.OrdersMoved, merge.ShipmentsMoved,
+		merge.AddressesMoved, merge.ContactsMoved, actor).Scan(&merge.ID, &merge.MergedAt)
+	if err != nil {
+		return merge, fmt.Errorf("failed to record merge: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return merge, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return merge, nil
+}
+
+// GetCustomerMerges lists the customers merged into customerID, newest first
+func (s *PostgresStorage) GetCustomerMerges(customerID int) ([]models.CustomerMerge, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, target_id, source_id, source_name, source, orders_moved, shipments_moved, addresses_moved, contacts_moved, actor, merged_at
+		FROM customer_merges
+		WHERE target_id = $1
+		ORDER BY merged_at DESC, id DESC
+	`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch merges for customer %d: %v", customerID, err)
+	}
+	defer rows.Close()
+
+	merges := []models.CustomerMerge{}
+	for rows.Next() {
+		var m models.CustomerMerge
+		var targetID sql.NullInt64
+		err := rows.Scan(&m.ID, &targetID, &m.SourceID, &m.SourceName, &m.Source, &m.OrdersMoved, &m.ShipmentsMoved,
+			&m.AddressesMoved, &m.ContactsMoved, &m.Actor, &m.MergedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan merge: %v", err)
+		}
+		m.TargetID = int(targetID.Int64)
+		merges = append(merges, m)
+	}
+	return merges, rows.Err()
+}
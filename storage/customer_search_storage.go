@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"fmt"
+	"strings"
+)
+
+// customerRank scores how well a customer matches $1. Exact and prefix name
+// matches come first, then substring matches on any field, then trigram
+// similarity so small typos still find the customer.
+const customerRank = `(
+	CASE
+		WHEN LOWER(c.name) = LOWER($1) THEN 3
+		WHEN c.name ILIKE $2 || '%' THEN 2
+		WHEN c.name ILIKE '%' || $2 || '%' OR c.email ILIKE '%' || $2 || '%'
+			OR c.number ILIKE '%' || $2 || '%' OR c.country ILIKE '%' || $2 || '%' THEN 1
+		ELSE 0
+	END
+	+ GREATEST(similarity(c.name, $1), word_similarity($1, c.name), similarity(COALESCE(c.email, ''), $1))
+)`
+
+const customerMatch = `(
+	c.name ILIKE '%' || $2 || '%'
+	OR c.email ILIKE '%' || $2 || '%'
+	OR c.number ILIKE '%' || $2 || '%'
+	OR c.country ILIKE '%' || $2 || '%'
+	OR c.name % $1
+	OR $1 <% c.name
+	OR COALESCE(c.email, '') % $1
+)`
+
+// escapeLike makes q safe to embed in an ILIKE pattern
+func escapeLike(q string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(q)
+}
+
+// customerFilter returns the WHERE and ORDER BY clauses and their arguments
+// for a search, so an empty q simply lists customers by name.
+func customerFilter(q string) (where, order string, args []any) {
+	if q == "" {
+		return "TRUE", "c.name", nil
+	}
+	return customerMatch, customerRank + " DESC, c.name", []any{q, escapeLike(q)}
+}
+
+// SearchCustomers returns one page of customers matching q, best match first
+func (s *PostgresStorage) SearchCustomers(q string, page, pageSize int) (models.CustomerPage, error) {
+	result := models.CustomerPage{Customers: []models.Customer{}, Page: page, PageSize: pageSize}
+	where, order, args := customerFilter(strings.TrimSpace(q))
+
+	query := fmt.Sprintf(`
+		SELECT c.id, c.name, c.number, c.email, c.country, c.address, c.notifications_opt_out, COUNT(*) OVER ()
+		FROM customers c
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d
+	`, where, order, len(args)+1, len(args)+2)
+	rows, err := s.DB.Query(query, append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		return result, fmt.Errorf("failed to search customers: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.Customer
+		if err := rows.Scan(&c.ID, &c.Name, &c.Number, &c.Email, &c.Country, &c.Address, &c.NotificationsOptOut, &result.Total); err != nil {
+			return result, fmt.Errorf("failed to scan customer: %v", err)
+		}
+		result.Customers = append(result.Customers, c)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	// Past the last page there are no rows to carry the total
+	if len(result.Customers) == 0 && page > 1 {
+		if err := s.DB.QueryRow(`SELECT COUNT(*) FROM customers c WHERE `+where, args...).Scan(&result.Total); err != nil {
+			return result, fmt.Errorf("failed to count customers: %v", err)
+		}
+	}
+	return result, nil
+}
+
+// GetCustomerOptions returns up to limit ID and name pairs for a typeahead
+func (s *PostgresStorage) GetCustomerOptions(q string, limit int) ([]models.CustomerOption, error) {
+	where, order, args := customerFilter(strings.TrimSpace(q))
+
+	query := fmt.Sprintf(`
+		SELECT c.id, c.name
+		FROM customers c
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d
+	`, where, order, len(args)+1)
+	rows, err := s.DB.Query(query, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search customers: %v", err)
+	}
+	defer rows.Close()
+
+	options := []models.CustomerOption{}
+	for rows.Next() {
+		var o models.CustomerOption
+		if err := rows.Scan(&o.ID, &o.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan customer: %v", err)
+		}
+		options = append(options, o)
+	}
+	return options, rows.Err()
+}
@@ -3,20 +3,48 @@ package storage
 import (
 	"AAHAOMS/OMS/models"
 	"database/sql"
+	"fmt"
+	"strings"
 
 	_ "github.com/lib/pq"
 )
 
+// EditCustumerDetails updates the customer and carries a rename through to
+// the name copied onto their orders.
 func (s *PostgresStorage) EditCustumerDetails(customer models.Customer) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Setting a country clears any unrecognised legacy value it replaces
+	country, err := countryCode(customer.Country)
+	if err != nil {
+		return err
+	}
 	query := `UPDATE customers
-			  SET name = $1, number = $2, email = $3, country = $4, address = $5
+			  SET name = $1, number = $2, email = $3, country = $4, address = $5,
+			      unverified_country = CASE WHEN $4 = '' THEN unverified_country ELSE '' END
 			  WHERE id = $6`
-	_, err := s.DB.Exec(query, customer.Name, customer.Number, customer.Email, customer.Country, customer.Address, customer.ID)
-	return err
+	res, err := tx.Exec(query, customer.Name, customer.Number, customer.Email, country, customer.Address, customer.ID)
+	if err != nil {
+		return classify(err)
+	}
+	if err := requireRow(res, "customer %d", customer.ID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE orders SET customer_name = $1 WHERE customer_id = $2 AND customer_name IS DISTINCT FROM $1`, customer.Name, customer.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update customer name on orders: %v", err)
+	}
+
+	return tx.Commit()
 }
 
 func (s *PostgresStorage) GetAllCustomers() ([]models.Customer, error) {
-	rows, err := s.DB.Query("SELECT id, name, number, email, country, address FROM customers")
+	rows, err := s.DB.Query("SELECT id, name, number, email, country, address, notifications_opt_out FROM customers")
 	if err != nil {
 		return nil, err
 	}
@@ -25,7 +53,7 @@ func (s *PostgresStorage) GetAllCustomers() ([]models.Customer, error) {
 	var customers []models.Customer
 	for rows.Next() {
 		var customer models.Customer
-		if err := rows.Scan(&customer.ID, &customer.Name, &customer.Number, &customer.Email, &customer.Country, &customer.Address); err != nil {
+		if err := rows.Scan(&customer.ID, &customer.Name, &customer.Number, &customer.Email, &customer.Country, &customer.Address, &customer.NotificationsOptOut); err != nil {
 			return nil, err
 		}
 		customers = append(customers, customer)
@@ -36,29 +64,97 @@ func (s *PostgresStorage) GetAllCustomers() ([]models.Customer, error) {
 func (s *PostgresStorage) GetCustomerByID(id string) (*models.Customer, error) {
 	var customer models.Customer
 	err := s.DB.QueryRow(
-		"SELECT id, name, number, email, country, address FROM customers WHERE id = $1",
+		"SELECT id, name, number, email, country, address, notifications_opt_out, unverified_country FROM customers WHERE id = $1",
 		id,
-	).Scan(&customer.ID, &customer.Name, &customer.Number, &customer.Email, &customer.Country, &customer.Address)
+	).Scan(&customer.ID, &customer.Name, &customer.Number, &customer.Email, &customer.Country, &customer.Address, &customer.NotificationsOptOut,
+		&customer.UnverifiedCountry)
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
+		return nil, notFound(err, "customer %s", id)
+	}
+
+	if customer.Addresses, err = s.GetCustomerAddresses(customer.ID); err != nil {
+		return nil, err
+	}
+	if customer.Contacts, err = s.GetCustomerContacts(customer.ID); err != nil {
 		return nil, err
 	}
 	return &customer, nil
 }
 
+// CreateCustomer inserts the customer along with a primary contact and
+// default billing and shipping addresses made from the legacy fields.
 func (s *PostgresStorage) CreateCustomer(name string, number string, email string, country string, address string) (int, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	id, err := createCustomer(tx, models.Customer{Name: name, Number: number, Email: email, Country: country, Address: address})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return id, nil
+}
+
+// countryCode upper-cases an ISO 3166-1 alpha-2 code, leaving a blank
+// country blank. Names like "Nepal" are not accepted here: the validator
+// only lets codes through and the importer turns names into codes first.
+func countryCode(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+	if !models.IsCountryCode(s) {
+		return "", fmt.Errorf("country %q is not an ISO 3166-1 alpha-2 code: %w", s, ErrValidation)
+	}
+	return strings.ToUpper(s), nil
+}
+
+func createCustomer(tx *sql.Tx, c models.Customer) (int, error) {
+	// The addresses below need a code
+	country, err := countryCode(c.Country)
+	if err != nil {
+		return 0, err
+	}
+	c.Country = country
+
 	var id int
 	query := `
 		INSERT INTO customers (name, number, email, country, address)
 		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id
 	`
-	err := s.DB.QueryRow(query, name, number, email, country, address).Scan(&id)
-	return id, err
+	if err := tx.QueryRow(query, c.Name, c.Number, c.Email, c.Country, c.Address).Scan(&id); err != nil {
+		return 0, classify(err)
+	}
+
+	if c.Number != "" || c.Email != "" {
+		_, err := tx.Exec(`
+			INSERT INTO customer_contacts (customer_id, name, role, email, phone, is_primary)
+			VALUES ($1, $2, 'primary', $3, $4, TRUE)
+		`, id, c.Name, c.Email, c.Number)
+		if err != nil {
+			return 0, classify(err)
+		}
+	}
+	if c.Address != "" {
+		_, err := tx.Exec(`
+			INSERT INTO customer_addresses (customer_id, type, line1, country, is_default)
+			VALUES ($1, 'billing', $2, $3, TRUE), ($1, 'shipping', $2, $3, TRUE)
+		`, id, c.Address, c.Country)
+		if err != nil {
+			return 0, classify(err)
+		}
+	}
+	return id, nil
 }
+
 func (s *PostgresStorage) CountCustumer() (int, error) {
 	var count int
 	err := s.DB.QueryRow("SELECT COUNT(*) FROM customers").Scan(&count)
@@ -67,6 +163,19 @@ func (s *PostgresStorage) CountCustumer() (int, error) {
 }
 func (s *PostgresStorage) DeleteCustomer(id int) error {
 	query := `DELETE FROM customers WHERE id = $1`
-	_, err := s.DB.Exec(query, id)
-	return err
+	res, err := s.DB.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	return requireRow(res, "customer %d", id)
+}
+
+// SetCustomerNotificationOptOut turns customer notification emails off or back on
+func (s *PostgresStorage) SetCustomerNotificationOptOut(id int, optOut bool) error {
+	query := `UPDATE customers SET notifications_opt_out = $1 WHERE id = $2`
+	res, err := s.DB.Exec(query, optOut, id)
+	if err != nil {
+		return err
+	}
+	return requireRow(res, "customer %d", id)
 }
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// GetCustomerSummary totals a customer's orders and shipments in one query
+// and adds their recent orders and shipments.
+func (s *PostgresStorage) GetCustomerSummary(customerID int, recent int) (models.CustomerSummary, error) {
+	var summary models.CustomerSummary
+
+	customer, err := s.GetCustomerByID(strconv.Itoa(customerID))
+	if err != nil {
+		return summary, err
+	}
+	summary.Customer = customer
+
+	var first, last sql.NullString
+	var orderedUnits, shippedUnits int
+	err = s.DB.QueryRow(`
+		WITH o AS (
+			SELECT id, order_date FROM orders WHERE customer_id = $1
+		), ordered AS (
+			SELECT COALESCE(SUM(oi.quantity), 0) AS units, COALESCE(SUM(oi.price * oi.quantity), 0) AS value
+			FROM order_items oi JOIN o ON oi.order_id = o.id
+		), shipped AS (
+			SELECT COALESCE(SUM(sl.quantity), 0) AS units, COALESCE(SUM(sl.value), 0) AS value
+			FROM shipped_lines sl JOIN o ON sl.order_id = o.id
+		)
+		SELECT
+			(SELECT COUNT(*) FROM o),
+			(SELECT MIN(order_date) FROM o),
+			(SELECT MAX(order_date) FROM o),
+			ordered.units, ordered.value, shipped.units, shipped.value
+		FROM ordered, shipped
+	`, customerID).Scan(&summary.OrderCount, &first, &last, &orderedUnits, &summary.OrderedValue, &shippedUnits, &summary.ShippedValue)
+	if err != nil {
+		return summary, fmt.Errorf("failed to summarize customer %d: %v", customerID, err)
+	}
+
+	if first.Valid {
+		summary.FirstOrderDate = &first.String
+	}
+	if last.Valid {
+		summary.LastOrderDate = &last.String
+	}
+	summary.OutstandingItems = orderedUnits - shippedUnits
+	summary.OutstandingValue = summary.OrderedValue - summary.ShippedValue
+	if summary.OrderCount > 0 {
+		summary.AverageOrderValue = summary.OrderedValue / float64(summary.OrderCount)
+	}
+
+	summary.RecentOrders, err = s.queryOrders(`
+		SELECT `+orderColumns+`
+		FROM orders
+		WHERE customer_id = $1
+		ORDER BY order_date DESC, id DESC
+		LIMIT $2
+	`, customerID, recent)
+	if err != nil {
+		return summary, fmt.Errorf("failed to fetch recent orders: %v", err)
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT `+shipmentColumns+`
+		FROM shipments s
+		INNER JOIN orders o ON s.order_id = o.id
+		WHERE o.customer_id = $1
+		ORDER BY s.shipped_date DESC, s.id DESC
+		LIMIT $2
+	`, customerID, recent)
+	if err != nil {
+		return summary, fmt.Errorf("failed to fetch recent shipments: %v", err)
+	}
+	defer rows.Close()
+
+	if summary.RecentShipments, err = s.parseShipments(rows); err != nil {
+		return summary, err
+	}
+	if summary.RecentShipments == nil {
+		summary.RecentShipments = []models.Shipment{}
+	}
+	return summary, nil
+}
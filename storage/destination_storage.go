@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// GetOrderDestinations lists the destinations an order is split across with
+// how much of each allocated line has shipped there. Orders that aren't
+// split have none.
+func (s *PostgresStorage) GetOrderDestinations(orderID int) ([]models.OrderDestination, error) {
+	var exists bool
+	if err := s.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM orders WHERE id = $1)`, orderID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("order %d: %w", orderID, ErrNotFound)
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT od.id, od.order_id, od.label, od.shipment_address, od.shipping_address_id, od.shipping_address,
+			a.order_item_id, a.quantity, COALESCE(sh.quantity, 0)
+		FROM order_destinations od
+		JOIN order_allocations a ON a.destination_id = od.id
+		LEFT JOIN (
+			SELECT s.destination_id, si.order_item_id, SUM(si.quantity)::int AS quantity
+			FROM shipments s
+			JOIN shipment_items si ON si.shipment_id = s.id
+			WHERE s.order_id = $1
+			GROUP BY s.destination_id, si.order_item_id
+		) sh ON sh.destination_id = od.id AND sh.order_item_id = a.order_item_id
+		WHERE od.order_id = $1
+		ORDER BY od.id, a.order_item_id
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch destinations for order %d: %v", orderID, err)
+	}
+	defer rows.Close()
+
+	destinations := []models.OrderDestination{}
+	for rows.Next() {
+		var d models.OrderDestination
+		var a models.Allocation
+		var addressID sql.NullInt64
+		var snapshot []byte
+		err := rows.Scan(&d.ID, &d.OrderID, &d.Label, &d.ShipmentAddress, &addressID, &snapshot,
+			&a.ItemID, &a.Quantity, &a.Shipped)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan destination: %v", err)
+		}
+		a.Due = max(a.Quantity-a.Shipped, 0)
+
+		if n := len(destinations); n > 0 && destinations[n-1].ID == d.ID {
+			destinations[n-1].Allocations = append(destinations[n-1].Allocations, a)
+			continue
+		}
+		if addressID.Valid {
+			id := int(addressID.Int64)
+			d.ShippingAddressID = &id
+		}
+		if snapshot != nil {
+			d.ShippingAddress = &models.CustomerAddress{}
+			if err := json.Unmarshal(snapshot, d.ShippingAddress); err != nil {
+				return nil, fmt.Errorf("failed to decode shipping address for destination %d: %v", d.ID, err)
+			}
+		}
+		d.Allocations = []models.Allocation{a}
+		destinations = append(destinations, d)
+	}
+	return destinations, rows.Err()
+}
+
+// SetOrderDestinations replaces the destinations of an order that hasn't
+// shipped yet. Every line of the order must be allocated in full.
+func (s *PostgresStorage) SetOrderDestinations(orderID int, req models.OrderDestinations) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	customerID, err := s.lockUnshippedOrder(tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	orderItems, err := s.getOrderItems(tx, orderID)
+	if err != nil {
+		return err
+	}
+	allocated := req.Allocated()
+	for _, itemID := range slices.Sorted(maps.Keys(allocated)) {
+		if _, ok := orderItems[itemID]; !ok {
+			return fmt.Errorf("item ID %d does not exist in the order: %w", itemID, ErrValidation)
+		}
+	}
+	for _, itemID := range slices.Sorted(maps.Keys(orderItems)) {
+		if ordered := orderItems[itemID].Quantity; allocated[itemID] != ordered {
+			return fmt.Errorf("item %d has %d of %d allocated: %w", itemID, allocated[itemID], ordered, ErrValidation)
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM order_destinations WHERE order_id = $1`, orderID); err != nil {
+		return fmt.Errorf("failed to clear destinations: %v", err)
+	}
+	for _, d := range req.Destinations {
+		var snapshot []byte
+		if d.ShippingAddressID != nil {
+			address, data, err := shippingAddressSnapshot(tx, customerID, *d.ShippingAddressID)
+			if err != nil {
+				return err
+			}
+			snapshot = data
+			d.ShipmentAddress = address.String()
+		}
+
+		var destinationID int
+		err := tx.QueryRow(`
+			INSERT INTO order_destinations (order_id, label, shipment_address, shipping_address_id, shipping_address)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id
+		`, orderID, d.Label, d.ShipmentAddress, d.ShippingAddressID, snapshot).Scan(&destinationID)
+		if err != nil {
+			return classify(err)
+		}
+
+		for _, a := range d.Allocations {
+			_, err := tx.Exec(`
+				INSERT INTO order_allocations (destination_id, order_item_id, quantity)
+				VALUES ($1, $2, $3)
+			`, destinationID, a.ItemID, a.Quantity)
+			if err != nil {
+				return fmt.Errorf("failed to allocate item %d: %v", a.ItemID, err)
+			}
+		}
+	}
+
+	if _, err := s.refreshOrderFulfillment(tx, orderID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit destinations: %v", err)
+	}
+	return nil
+}
+
+// DeleteOrderDestinations goes back to shipping an unshipped order to its
+// own address
+func (s *PostgresStorage) DeleteOrderDestinations(orderID int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := s.lockUnshippedOrder(tx, orderID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM order_destinations WHERE order_id = $1`, orderID); err != nil {
+		return fmt.Errorf("failed to delete destinations: %v", err)
+	}
+	if _, err := s.refreshOrderFulfillment(tx, orderID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit destinations: %v", err)
+	}
+	return nil
+}
+
+// lockUnshippedOrder locks an order whose destinations are about to change
+// and returns its customer. Once anything has shipped they are fixed.
+func (s *PostgresStorage) lockUnshippedOrder(tx *sql.Tx, orderID int) (int, error) {
+	if _, err := s.getOrderStatus(tx, orderID); err != nil {
+		return 0, err
+	}
+	var customerID, shipments int
+	err := tx.QueryRow(`
+		SELECT COALESCE(o.customer_id, 0), (SELECT COUNT(*) FROM shipments WHERE order_id = o.id)
+		FROM orders o
+		WHERE o.id = $1
+	`, orderID).Scan(&customerID, &shipments)
+	if err != nil {
+		return 0, err
+	}
+	if shipments > 0 {
+		return 0, fmt.Errorf("order %d has already shipped; its destinations can't change: %w", orderID, ErrConflict)
+	}
+	return customerID, nil
+}
+
+// destinationAllocations returns how much of each line is allocated to the
+// destination of a split order, or nil when the order isn't split
+func destinationAllocations(tx *sql.Tx, orderID int, destinationID *int) (map[int]int, error) {
+	var split bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM order_destinations WHERE order_id = $1)`, orderID).Scan(&split); err != nil {
+		return nil, fmt.Errorf("failed to fetch destinations: %v", err)
+	}
+	switch {
+	case !split && destinationID == nil:
+		return nil, nil
+	case !split:
+		return nil, fmt.Errorf("order %d is not split across destinations: %w", orderID, ErrValidation)
+	case destinationID == nil:
+		return nil, fmt.Errorf("order %d is split across destinations; destination_id is required: %w", orderID, ErrValidation)
+	}
+
+	rows, err := tx.Query(`
+		SELECT a.order_item_id, a.quantity
+		FROM order_allocations a
+		JOIN order_destinations od ON od.id = a.destination_id
+		WHERE od.order_id = $1 AND od.id = $2
+	`, orderID, *destinationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch allocations: %v", err)
+	}
+	defer rows.Close()
+
+	allocations := make(map[int]int)
+	for rows.Next() {
+		var itemID, quantity int
+		if err := rows.Scan(&itemID, &quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan allocation: %v", err)
+		}
+		allocations[itemID] = quantity
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(allocations) == 0 {
+		return nil, fmt.Errorf("destination %d does not belong to order %d: %w", *destinationID, orderID, ErrValidation)
+	}
+	return allocations, nil
+}
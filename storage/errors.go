@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Sentinel errors callers can test for with errors.Is. Storage functions
+// wrap them with context, e.g. fmt.Errorf("order %d: %w", id, ErrNotFound).
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+)
+
+// notFound wraps sql.ErrNoRows as ErrNotFound and passes other errors through
+func notFound(err error, format string, args ...any) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf(format+": %w", append(args, ErrNotFound)...)
+	}
+	return err
+}
+
+// classify maps Postgres constraint and data errors onto the sentinels so
+// the API can tell a duplicate or bad reference apart from an outage.
+func classify(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch {
+	case pqErr.Code == "23505": // unique_violation
+		return fmt.Errorf("%s: %w", pqErr.Detail, ErrConflict)
+	case pqErr.Code == "23503": // foreign_key_violation
+		return fmt.Errorf("%s: %w", pqErr.Detail, ErrValidation)
+	case pqErr.Code == "23502", pqErr.Code == "23514": // not_null_violation, check_violation
+		return fmt.Errorf("%s: %w", pqErr.Message, ErrValidation)
+	case pqErr.Code.Class() == "22": // data_exception, e.g. a malformed date
+		return fmt.Errorf("%s: %w", pqErr.Message, ErrValidation)
+	}
+	return err
+}
+
+// requireRow turns an UPDATE or DELETE that touched nothing into ErrNotFound
+func requireRow(res sql.Result, format string, args ...any) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf(format+": %w", append(args, ErrNotFound)...)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"fmt"
+	"strings"
+)
+
+const orderFilterClause = `
+	($1 = '' OR o.order_date >= NULLIF($1, '')::date)
+	AND ($2 = '' OR o.order_date <= NULLIF($2, '')::date)
+	AND ($3 = '' OR TRIM(o.order_status) = $3)
+	AND ($4 = 0 OR o.customer_id = $4)`
+
+func orderFilterArgs(f models.OrderFilter) []any {
+	return []any{f.From, f.To, f.Status, f.CustomerID}
+}
+
+const shipmentFilterClause = `
+	($1 = '' OR s.shipped_date >= NULLIF($1, '')::date)
+	AND ($2 = '' OR s.shipped_date <= NULLIF($2, '')::date)
+	AND ($3 = '' OR TRIM(o.order_status) = $3)
+	AND ($4 = 0 OR o.customer_id = $4)
+	AND ($5 = 0 OR s.order_id = $5)`
+
+func shipmentFilterArgs(f models.ShipmentFilter) []any {
+	return []any{f.From, f.To, f.Status, f.CustomerID, f.OrderID}
+}
+
+// GetOrders lists the orders matching filter, newest first
+func (s *PostgresStorage) GetOrders(filter models.OrderFilter) ([]models.Order, error) {
+	return s.queryOrders(`
+		SELECT `+orderColumns+`
+		FROM orders o
+		WHERE `+orderFilterClause+`
+		ORDER BY o.order_date DESC, o.id DESC
+	`, orderFilterArgs(filter)...)
+}
+
+// GetShipments lists the shipments matching filter, newest first
+func (s *PostgresStorage) GetShipments(filter models.ShipmentFilter) ([]models.Shipment, error) {
+	rows, err := s.DB.Query(`
+		SELECT `+shipmentColumns+`
+		FROM shipments s
+		INNER JOIN orders o ON s.order_id = o.id
+		WHERE `+shipmentFilterClause+`
+		ORDER BY s.shipped_date DESC, s.id DESC
+	`, shipmentFilterArgs(filter)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch shipments: %v", err)
+	}
+	defer rows.Close()
+
+	shipments, err := s.parseShipments(rows)
+	if shipments == nil && err == nil {
+		shipments = []models.Shipment{}
+	}
+	return shipments, err
+}
+
+// StreamOrderLines calls fn for each item of each matching order without
+// holding the result in memory
+func (s *PostgresStorage) StreamOrderLines(filter models.OrderFilter, fn func(models.OrderLine) error) error {
+	rows, err := s.DB.Query(`
+		SELECT o.id, COALESCE(o.external_ref, ''), COALESCE(o.customer_id, 0), COALESCE(o.customer_name, ''),
+			TO_CHAR(o.order_date, 'YYYY-MM-DD'), COALESCE(TO_CHAR(o.shipment_due, 'YYYY-MM-DD'), ''),
+			COALESCE(o.order_status, ''), COALESCE(o.shipment_address, ''),
+			i.id, COALESCE(i.name, ''), COALESCE(i.size, ''), COALESCE(i.color, ''), COALESCE(i.price, 0), i.quantity
+		FROM orders o
+		JOIN order_items i ON i.order_id = o.id
+		WHERE `+orderFilterClause+`
+		ORDER BY o.order_date, o.id, i.id
+	`, orderFilterArgs(filter)...)
+	if err != nil {
+		return fmt.Errorf("failed to fetch order lines: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l models.OrderLine
+		err := rows.Scan(&l.OrderID, &l.ExternalRef, &l.CustomerID, &l.CustomerName, &l.OrderDate, &l.ShipmentDue,
+			&l.OrderStatus, &l.ShipmentAddress, &l.ItemID, &l.ItemName, &l.Size, &l.Color, &l.Price, &l.Quantity)
+		if err != nil {
+			return fmt.Errorf("failed to scan order line: %v", err)
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// StreamShipmentLines calls fn for each line of each matching shipment with
+// the quantity and price it shipped at
+func (s *PostgresStorage) StreamShipmentLines(filter models.ShipmentFilter, fn func(models.ShipmentLine) error) error {
+	rows, err := s.DB.Query(`
+		SELECT s.id, TO_CHAR(s.shipped_date, 'YYYY-MM-DD'), COALESCE(s.due_order_type, FALSE),
+			s.carrier, s.service_level,
+			COALESCE((
+				SELECT STRING_AGG(p.tracking_number, ' ' ORDER BY p.id)
+				FROM shipment_packages p
+				WHERE p.shipment_id = s.id AND p.tracking_number <> ''
+			), ''),
+			o.id, COALESCE(o.customer_id, 0), COALESCE(o.customer_name, ''), COALESCE(o.order_status, ''),
+			i.id, COALESCE(i.name, ''), COALESCE(i.size, ''), COALESCE(i.color, ''), si.price, si.quantity
+		FROM shipments s
+		JOIN orders o ON s.order_id = o.id
+		JOIN shipment_items si ON si.shipment_id = s.id
+		JOIN order_items i ON i.id = si.order_item_id
+		WHERE `+shipmentFilterClause+`
+		ORDER BY s.shipped_date, s.id, i.id
+	`, shipmentFilterArgs(filter)...)
+	if err != nil {
+		return fmt.Errorf("failed to fetch shipment lines: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l models.ShipmentLine
+		err := rows.Scan(&l.ShipmentID, &l.ShippedDate, &l.DueOrderType, &l.Carrier, &l.ServiceLevel, &l.TrackingNumbers, &l.OrderID, &l.CustomerID, &l.CustomerName,
+			&l.OrderStatus, &l.ItemID, &l.ItemName, &l.Size, &l.Color, &l.Price, &l.Quantity)
+		if err != nil {
+			return fmt.Errorf("failed to scan shipment line: %v", err)
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// StreamCustomers calls fn for each customer matching q, as the search does,
+// or every customer by name when q is empty
+func (s *PostgresStorage) StreamCustomers(q string, fn func(models.Customer) error) error {
+	where, order, args := customerFilter(strings.TrimSpace(q))
+	rows, err := s.DB.Query(`
+		SELECT c.id, c.name, COALESCE(c.number, ''), COALESCE(c.email, ''), COALESCE(c.country, ''),
+			COALESCE(c.address, ''), c.notifications_opt_out
+		FROM customers c
+		WHERE `+where+`
+		ORDER BY `+order, args...)
+	if err != nil {
+		return fmt.Errorf("failed to fetch customers: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.Customer
+		if err := rows.Scan(&c.ID, &c.Name, &c.Number, &c.Email, &c.Country, &c.Address, &c.NotificationsOptOut); err != nil {
+			return fmt.Errorf("failed to scan customer: %v", err)
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"database/sql"
+	"fmt"
+	"math"
+)
+
+// GetFulfillmentReport measures lead time, on-time rate, first shipment fill
+// rate and shipments per order for orders placed in the filter's range,
+// overall and sliced by order month and by customer in one pass. The fill
+// rate is the share of ordered units that went out in the first shipment.
+func (s *PostgresStorage) GetFulfillmentReport(filter models.FulfillmentFilter) (models.FulfillmentReport, error) {
+	report := models.FulfillmentReport{
+		From:       filter.From,
+		To:         filter.To,
+		ByMonth:    []models.FulfillmentMetrics{},
+		ByCustomer: []models.FulfillmentMetrics{},
+	}
+
+	rows, err := s.DB.Query(`
+		WITH o AS (
+			SELECT o.id, COALESCE(o.customer_id, 0) AS customer_id, COALESCE(c.name, o.customer_name, '') AS customer_name,
+				o.order_date, o.shipment_due, DATE_TRUNC('month', o.order_date::timestamp) AS month
+			FROM orders o
+			LEFT JOIN customers c ON c.id = o.customer_id
+			WHERE ($1 = '' OR o.order_date >= NULLIF($1, '')::date)
+				AND ($2 = '' OR o.order_date <= NULLIF($2, '')::date)
+				AND ($3 = 0 OR o.customer_id = $3)
+		), ship AS (
+			SELECT s.order_id, COUNT(*) AS shipments, MIN(s.shipped_date) AS first_shipped,
+				COUNT(*) FILTER (WHERE o.shipment_due IS NOT NULL) AS due_shipments,
+				COUNT(*) FILTER (WHERE s.shipped_date <= o.shipment_due) AS on_time_shipments
+			FROM shipments s
+			JOIN o ON o.id = s.order_id
+			GROUP BY s.order_id
+		), ordered AS (
+			SELECT oi.order_id, SUM(oi.quantity) AS units
+			FROM order_items oi
+			JOIN o ON o.id = oi.order_id
+			GROUP BY oi.order_id
+		), first_shipment AS (
+			SELECT DISTINCT ON (s.order_id) s.order_id, s.id
+			FROM shipments s
+			JOIN o ON o.id = s.order_id
+			ORDER BY s.order_id, s.shipped_date, s.id
+		), first_fill AS (
+			SELECT fs.order_id, SUM(si.quantity) AS units
+			FROM first_shipment fs
+			JOIN shipment_items si ON si.shipment_id = fs.id
+			GROUP BY fs.order_id
+		)
+		SELECT GROUPING(o.month) = 0, GROUPING(o.customer_id) = 0,
+			TO_CHAR(o.month, 'YYYY-MM'), o.customer_id, o.customer_name,
+			COUNT(*), COUNT(sh.order_id), COALESCE(SUM(sh.shipments), 0),
+			AVG(sh.first_shipped - o.order_date)::float8,
+			PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY sh.first_shipped - o.order_date),
+			COALESCE(SUM(sh.on_time_shipments), 0), COALESCE(SUM(sh.due_shipments), 0),
+			COALESCE(SUM(ff.units), 0), COALESCE(SUM(ordered.units) FILTER (WHERE ff.order_id IS NOT NULL), 0),
+			COUNT(ff.order_id)
+		FROM o
+		LEFT JOIN ship sh ON sh.order_id = o.id
+		LEFT JOIN ordered ON ordered.order_id = o.id
+		LEFT JOIN first_fill ff ON ff.order_id = o.id
+		GROUP BY GROUPING SETS ((), (o.month), (o.customer_id, o.customer_name))
+		ORDER BY 1, 2, 3, 5
+	`, filter.From, filter.To, filter.CustomerID)
+	if err != nil {
+		return report, fmt.Errorf("failed to compute fulfillment metrics: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m models.FulfillmentMetrics
+		var byMonth, byCustomer bool
+		var month, customerName sql.NullString
+		var customerID sql.NullInt64
+		var avgLead, medianLead sql.NullFloat64
+		var onTime, dueShipments, filledUnits, fillOrderedUnits int
+		err := rows.Scan(&byMonth, &byCustomer, &month, &customerID, &customerName,
+			&m.OrderCount, &m.ShippedOrderCount, &m.ShipmentCount, &avgLead, &medianLead,
+			&onTime, &dueShipments, &filledUnits, &fillOrderedUnits, &m.FillRateOrders)
+		if err != nil {
+			return report, fmt.Errorf("failed to scan fulfillment metrics: %v", err)
+		}
+
+		m.Month = month.String
+		m.CustomerID = int(customerID.Int64)
+		m.CustomerName = customerName.String
+		if avgLead.Valid {
+			m.AvgLeadTimeDays = round2(avgLead.Float64)
+		}
+		if medianLead.Valid {
+			m.MedianLeadTimeDays = round2(medianLead.Float64)
+		}
+		m.OnTimeRate = percent(onTime, dueShipments)
+		m.FillRate = percent(filledUnits, fillOrderedUnits)
+		if m.ShippedOrderCount > 0 {
+			m.ShipmentsPerOrder = round2(float64(m.ShipmentCount) / float64(m.ShippedOrderCount))
+		}
+
+		switch {
+		case byMonth:
+			report.ByMonth = append(report.ByMonth, m)
+		case byCustomer:
+			report.ByCustomer = append(report.ByCustomer, m)
+		default:
+			report.Overall = m
+		}
+	}
+	return report, rows.Err()
+}
+
+// percent returns part as a percentage of whole, or nil when whole is 0
+func percent(part, whole int) *float64 {
+	if whole == 0 {
+		return nil
+	}
+	return round2(float64(part) * 100 / float64(whole))
+}
+
+func round2(v float64) *float64 {
+	r := math.Round(v*100) / 100
+	return &r
+}
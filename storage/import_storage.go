@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ImportCustomers saves customers read from an import file in one
+// transaction. Each row runs under a savepoint so every failing row is
+// reported, and nothing is committed unless all rows succeed.
+func (s *PostgresStorage) ImportCustomers(rows []models.CustomerImport, opts models.ImportOptions) (models.ImportResult, error) {
+	result := models.ImportResult{Kind: "customers", DryRun: opts.DryRun, Records: len(rows), Errors: []models.ImportError{}}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, row := range rows {
+		err := withSavepoint(tx, func() error {
+			var existingID int
+			err := tx.QueryRow(`SELECT id FROM customers WHERE LOWER(name) = LOWER($1)`, row.Customer.Name).Scan(&existingID)
+			if err == sql.ErrNoRows {
+				_, err = createCustomer(tx, row.Customer)
+				if err == nil {
+					result.Created++
+				}
+				return err
+			}
+			if err != nil {
+				return err
+			}
+
+			switch opts.OnDuplicate {
+			case models.DuplicateSkip:
+				result.Skipped++
+				return nil
+			case models.DuplicateUpdate:
+				c := row.Customer
+				_, err := tx.Exec(`
+					UPDATE customers SET
+						number = COALESCE(NULLIF($1, ''), number),
+						email = COALESCE(NULLIF($2, ''), email),
+						country = COALESCE(NULLIF($3, ''), country),
+						address = COALESCE(NULLIF($4, ''), address)
+					WHERE id = $5
+				`, c.Number, c.Email, c.Country, c.Address, existingID)
+				if err == nil {
+					result.Updated++
+				}
+				return classify(err)
+			}
+			return fmt.Errorf("customer already exists with ID %d: %w", existingID, ErrConflict)
+		})
+		if err != nil {
+			result.AddError(row.Row, "name", err.Error())
+		}
+	}
+
+	return result, finishImport(tx, &result)
+}
+
+// ImportOrders saves orders read from an import file in one transaction.
+// Duplicates are matched on external_ref. Imported orders are historical,
+// so no order.created notifications or webhooks are queued for them.
+func (s *PostgresStorage) ImportOrders(rows []models.OrderImport, opts models.ImportOptions) (models.ImportResult, error) {
+	result := models.ImportResult{Kind: "orders", DryRun: opts.DryRun, Records: len(rows), Errors: []models.ImportError{}}
+	if opts.OnDuplicate == models.DuplicateUpdate {
+		return result, fmt.Errorf("orders can't be updated by import, use on_duplicate=skip or error: %w", ErrValidation)
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, row := range rows {
+		order := row.Order
+		field := "customer"
+
+		err := withSavepoint(tx, func() error {
+			if order.CustomerID == 0 {
+				err := tx.QueryRow(`SELECT id FROM customers WHERE LOWER(name) = LOWER(TRIM($1))`, row.CustomerName).Scan(&order.CustomerID)
+				if err == sql.ErrNoRows {
+					return fmt.Errorf("no customer named %q", row.CustomerName)
+				} else if err != nil {
+					return err
+				}
+			}
+
+			if order.ExternalRef != "" {
+				var existingID int
+				err := tx.QueryRow(`SELECT id FROM orders WHERE external_ref = $1`, order.ExternalRef).Scan(&existingID)
+				if err == nil {
+					field = "order_ref"
+					if opts.OnDuplicate == models.DuplicateSkip {
+						result.Skipped++
+						return nil
+					}
+					return fmt.Errorf("order already imported with ID %d: %w", existingID, ErrConflict)
+				} else if err != sql.ErrNoRows {
+					return err
+				}
+			}
+
+			field = ""
+			if _, err := createOrder(tx, order); err != nil {
+				return err
+			}
+			result.Created++
+			return nil
+		})
+		if err != nil {
+			result.AddError(row.Row, field, err.Error())
+		}
+	}
+
+	return result, finishImport(tx, &result)
+}
+
+// withSavepoint runs fn under a savepoint, rolling back to it if fn fails
+// so the surrounding transaction can carry on
+func withSavepoint(tx *sql.Tx, fn func() error) error {
+	if _, err := tx.Exec(`SAVEPOINT import_row`); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		if _, rbErr := tx.Exec(`ROLLBACK TO SAVEPOINT import_row`); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+	_, err := tx.Exec(`RELEASE SAVEPOINT import_row`)
+	return err
+}
+
+// finishImport commits a clean, non dry-run import and rolls back anything else
+func finishImport(tx *sql.Tx, result *models.ImportResult) error {
+	if len(result.Errors) > 0 || result.DryRun {
+		return tx.Rollback()
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit import: %v", err)
+	}
+	result.Committed = true
+	return nil
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"AAHAOMS/OMS/models"
 	"database/sql"
+	"fmt"
 )
 
 func (s *PostgresStorage) GetItemByID(itemID int) (models.Item, error) {
@@ -17,7 +18,7 @@ func (s *PostgresStorage) GetItemByID(itemID int) (models.Item, error) {
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return models.Item{}, nil
+			return models.Item{}, fmt.Errorf("item %d: %w", itemID, ErrNotFound)
 		}
 		return models.Item{}, err
 	}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const jobColumns = `id, kind, payload, status, attempts, max_attempts, run_at, dedupe_key,
+	COALESCE(last_error, ''), created_at, updated_at, completed_at`
+
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// enqueueJob inserts a job through db, which may be a transaction so the job
+// only exists if the surrounding change commits. A job whose dedupe key is
+// already taken is silently dropped and 0 is returned.
+func enqueueJob(db queryRower, kind string, payload any, dedupeKey string) (int, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode %s job payload: %v", kind, err)
+	}
+
+	var id int
+	err = db.QueryRow(`
+		INSERT INTO jobs (kind, payload, dedupe_key)
+		VALUES ($1, $2, NULLIF($3, ''))
+		ON CONFLICT (dedupe_key) WHERE dedupe_key IS NOT NULL DO NOTHING
+		RETURNING id
+	`, kind, body, dedupeKey).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue %s job: %v", kind, err)
+	}
+	return id, nil
+}
+
+func (s *PostgresStorage) EnqueueJob(kind string, payload any, dedupeKey string) (int, error) {
+	return enqueueJob(s.DB, kind, payload, dedupeKey)
+}
+
+// ClaimJob marks the oldest runnable job of one of the given kinds as running
+// and returns it, or nil when there is nothing to do.
+func (s *PostgresStorage) ClaimJob(kinds []string) (*models.Job, error) {
+	row := s.DB.QueryRow(`
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = 'queued' AND run_at <= NOW() AND kind = ANY($1)
+			ORDER BY run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+jobColumns, pq.Array(kinds))
+
+	job, err := scanJob(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job: %v", err)
+	}
+	return job, nil
+}
+
+func (s *PostgresStorage) CompleteJob(id int) error {
+	_, err := s.DB.Exec(`
+		UPDATE jobs
+		SET status = 'done', last_error = NULL, locked_at = NULL, updated_at = NOW(), completed_at = NOW()
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+// FailJob records a failed run. A nil retryAt dead-letters the job.
+func (s *PostgresStorage) FailJob(id int, errMsg string, retryAt *time.Time) error {
+	var err error
+	if retryAt == nil {
+		_, err = s.DB.Exec(`
+			UPDATE jobs SET status = 'dead', last_error = $2, locked_at = NULL, updated_at = NOW()
+			WHERE id = $1
+		`, id, errMsg)
+	} else {
+		_, err = s.DB.Exec(`
+			UPDATE jobs SET status = 'queued', last_error = $2, run_at = $3, locked_at = NULL, updated_at = NOW()
+			WHERE id = $1
+		`, id, errMsg, *retryAt)
+	}
+	return err
+}
+
+// RequeueStaleJobs puts jobs back in the queue whose worker has held them
+// longer than timeout, which only happens if the process died mid-job.
+func (s *PostgresStorage) RequeueStaleJobs(timeout time.Duration) (int, error) {
+	res, err := s.DB.Exec(`
+		UPDATE jobs
+		SET status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'queued' END,
+		    last_error = 'worker timed out', locked_at = NULL, updated_at = NOW()
+		WHERE status = 'running' AND locked_at < NOW() - make_interval(secs => $1)
+	`, timeout.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue stale jobs: %v", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+func (s *PostgresStorage) GetJobs(status, kind string, limit int) ([]models.Job, error) {
+	rows, err := s.DB.Query(`
+		SELECT `+jobColumns+`
+		FROM jobs
+		WHERE ($1 = '' OR status = $1) AND ($2 = '' OR kind = $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`, status, kind, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch jobs: %v", err)
+	}
+	defer rows.Close()
+
+	jobs := []models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %v", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+func (s *PostgresStorage) GetJobCounts() (map[string]int, error) {
+	rows, err := s.DB.Query(`SELECT status, COUNT(*) FROM jobs GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count jobs: %v", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int{"queued": 0, "running": 0, "done": 0, "dead": 0}
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
+func (s *PostgresStorage) GetJobByID(id int) (*models.Job, error) {
+	job, err := scanJob(s.DB.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("job %d: %w", id, ErrNotFound)
+	}
+	return job, err
+}
+
+// RetryJob puts a dead job back in the queue with a fresh set of attempts
+func (s *PostgresStorage) RetryJob(id int) error {
+	res, err := s.DB.Exec(`
+		UPDATE jobs
+		SET status = 'queued', attempts = 0, run_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = 'dead'
+	`, id)
+	if err != nil {
+		return fmt.Errorf("failed to retry job: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := s.GetJobByID(id); err != nil {
+			return err
+		}
+		return fmt.Errorf("job %d is not dead: %w", id, ErrConflict)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row rowScanner) (*models.Job, error) {
+	var job models.Job
+	var dedupeKey, completedAt sql.NullString
+	err := row.Scan(&job.ID, &job.Kind, &job.Payload, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAt,
+		&dedupeKey, &job.LastError, &job.CreatedAt, &job.UpdatedAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+	if dedupeKey.Valid {
+		job.DedupeKey = &dedupeKey.String
+	}
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.String
+	}
+	return &job, nil
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+const labelColumns = `id, shipment_id, carrier, service_level, tracking_numbers, format, data, cost, currency, created_at, cancelled_at`
+
+func scanLabel(row rowScanner) (models.ShipmentLabel, error) {
+	var l models.ShipmentLabel
+	var cancelled sql.NullString
+	err := row.Scan(&l.ID, &l.ShipmentID, &l.Carrier, &l.ServiceLevel, pq.Array(&l.TrackingNumbers), &l.Format, &l.Data,
+		&l.Cost, &l.Currency, &l.CreatedAt, &cancelled)
+	if cancelled.Valid {
+		l.CancelledAt = &cancelled.String
+	}
+	return l, err
+}
+
+// SaveShipmentLabel stores a purchased label. Its tracking numbers go onto the
+// shipment's packages in order, and the shipment takes the label's carrier,
+// service level and cost.
+func (s *PostgresStorage) SaveShipmentLabel(label models.ShipmentLabel) (int, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var active bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM shipment_labels WHERE shipment_id = s.id AND cancelled_at IS NULL)
+		FROM shipments s
+		WHERE s.id = $1
+		FOR UPDATE
+	`, label.ShipmentID).Scan(&active)
+	if err != nil {
+		return 0, notFound(err, "shipment %d", label.ShipmentID)
+	}
+	if active {
+		return 0, fmt.Errorf("shipment %d already has a label: %w", label.ShipmentID, ErrConflict)
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO shipment_labels (shipment_id, carrier, service_level, tracking_numbers, format, data, cost, currency)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, label.ShipmentID, label.Carrier, label.ServiceLevel, pq.Array(label.TrackingNumbers), label.Format, label.Data,
+		label.Cost, label.Currency).Scan(&id)
+	if err != nil {
+		return 0, classify(err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE shipment_packages p
+		SET tracking_number = n.tracking_number
+		FROM (
+			SELECT id, ROW_NUMBER() OVER (ORDER BY id) AS position
+			FROM shipment_packages
+			WHERE shipment_id = $1
+		) ordered
+		JOIN UNNEST($2::text[]) WITH ORDINALITY AS n(tracking_number, position) ON n.position = ordered.position
+		WHERE p.id = ordered.id
+	`, label.ShipmentID, pq.Array(label.TrackingNumbers))
+	if err != nil {
+		return 0, fmt.Errorf("failed to set tracking numbers: %v", err)
+	}
+
+	_, err = tx.Exec(`UPDATE shipments SET carrier = $2, service_level = $3, shipping_cost = $4 WHERE id = $1`,
+		label.ShipmentID, label.Carrier, label.ServiceLevel, label.Cost)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update shipment %d: %v", label.ShipmentID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit label: %v", err)
+	}
+	return id, nil
+}
+
+// GetShipmentLabel returns the shipment's label that hasn't been cancelled
+func (s *PostgresStorage) GetShipmentLabel(shipmentID int) (models.ShipmentLabel, error) {
+	l, err := scanLabel(s.DB.QueryRow(`
+		SELECT `+labelColumns+`
+		FROM shipment_labels
+		WHERE shipment_id = $1 AND cancelled_at IS NULL
+	`, shipmentID))
+	if err != nil {
+		return models.ShipmentLabel{}, notFound(err, "label for shipment %d", shipmentID)
+	}
+	return l, nil
+}
+
+// CancelShipmentLabel marks the shipment's label cancelled and clears the
+// tracking numbers it put on the packages
+func (s *PostgresStorage) CancelShipmentLabel(shipmentID int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var numbers []string
+	err = tx.QueryRow(`
+		UPDATE shipment_labels SET cancelled_at = NOW()
+		WHERE shipment_id = $1 AND cancelled_at IS NULL
+		RETURNING tracking_numbers
+	`, shipmentID).Scan(pq.Array(&numbers))
+	if err != nil {
+		return notFound(err, "label for shipment %d", shipmentID)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE shipment_packages SET tracking_number = ''
+		WHERE shipment_id = $1 AND tracking_number = ANY($2)
+	`, shipmentID, pq.Array(numbers))
+	if err != nil {
+		return fmt.Errorf("failed to clear tracking numbers: %v", err)
+	}
+	return tx.Commit()
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"AAHAOMS/OMS/models"
 	"database/sql"
 	"fmt"
 	"os"
@@ -55,9 +56,435 @@ func (s *PostgresStorage) Init() error {
 		due_order_type BOOLEAN,
 		items INT[]
 	);
+
+	ALTER TABLE customers ADD COLUMN IF NOT EXISTS notifications_opt_out BOOLEAN NOT NULL DEFAULT FALSE;
+
+	CREATE TABLE IF NOT EXISTS order_notifications (
+		id SERIAL PRIMARY KEY,
+		order_id INT REFERENCES orders(id) ON DELETE CASCADE,
+		event VARCHAR(50) NOT NULL,
+		recipient VARCHAR(150),
+		subject TEXT,
+		status VARCHAR(20) NOT NULL,
+		error TEXT,
+		sent_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id SERIAL PRIMARY KEY,
+		url TEXT NOT NULL,
+		event_types TEXT[] NOT NULL,
+		secret VARCHAR(128) NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id SERIAL PRIMARY KEY,
+		subscription_id INT REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+		event_id VARCHAR(64) NOT NULL,
+		event_type VARCHAR(50) NOT NULL,
+		payload JSONB NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMPTZ DEFAULT NOW(),
+		last_status_code INT,
+		last_error TEXT,
+		replay_of INT REFERENCES webhook_deliveries(id) ON DELETE SET NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		delivered_at TIMESTAMPTZ
+	);
+
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+	CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_event_idx ON webhook_deliveries (subscription_id, event_id) WHERE replay_of IS NULL;
+
+	CREATE TABLE IF NOT EXISTS jobs (
+		id SERIAL PRIMARY KEY,
+		kind VARCHAR(100) NOT NULL,
+		payload JSONB NOT NULL DEFAULT '{}',
+		status VARCHAR(20) NOT NULL DEFAULT 'queued',
+		attempts INT NOT NULL DEFAULT 0,
+		max_attempts INT NOT NULL DEFAULT 5,
+		run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		locked_at TIMESTAMPTZ,
+		dedupe_key VARCHAR(200),
+		last_error TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		completed_at TIMESTAMPTZ
+	);
+
+	CREATE INDEX IF NOT EXISTS jobs_queued_idx ON jobs (run_at) WHERE status = 'queued';
+	CREATE UNIQUE INDEX IF NOT EXISTS jobs_dedupe_key_idx ON jobs (dedupe_key) WHERE dedupe_key IS NOT NULL;
+
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id SERIAL PRIMARY KEY,
+		actor VARCHAR(150) NOT NULL,
+		remote_addr VARCHAR(100),
+		occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		method VARCHAR(10) NOT NULL,
+		route TEXT NOT NULL,
+		entity_type VARCHAR(50) NOT NULL,
+		entity_id VARCHAR(100) NOT NULL,
+		action VARCHAR(20) NOT NULL,
+		before JSONB,
+		after JSONB,
+		diff JSONB
+	);
+
+	CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (entity_type, entity_id, occurred_at);
+
+	-- actor comes from the request's credential; X-Actor is only a hint
+	ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS actor_hint VARCHAR(150);
+
+	CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_log is append-only';
+	END;
+	$$ LANGUAGE plpgsql;
+
+	DROP TRIGGER IF EXISTS audit_log_no_change ON audit_log;
+	CREATE TRIGGER audit_log_no_change BEFORE UPDATE OR DELETE ON audit_log
+		FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+	-- Orders used to be tied to customers by name only. Link any that still
+	-- lack a customer_id where the name matches exactly one customer, then
+	-- keep the denormalized name in step with the customer record.
+	UPDATE orders o SET customer_id = c.id
+	FROM customers c
+	WHERE o.customer_id IS NULL
+		AND LOWER(TRIM(o.customer_name)) = LOWER(TRIM(c.name))
+		AND (SELECT COUNT(*) FROM customers d WHERE LOWER(TRIM(d.name)) = LOWER(TRIM(c.name))) = 1;
+
+	UPDATE orders o SET customer_name = c.name
+	FROM customers c
+	WHERE o.customer_id = c.id AND o.customer_name IS DISTINCT FROM c.name;
+
+	CREATE INDEX IF NOT EXISTS orders_customer_id_idx ON orders (customer_id);
+	CREATE INDEX IF NOT EXISTS customers_lower_name_idx ON customers (LOWER(name));
+
+	-- One-off data migrations record themselves here so they don't repeat
+	CREATE TABLE IF NOT EXISTS data_migrations (
+		name VARCHAR(100) PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS customer_addresses (
+		id SERIAL PRIMARY KEY,
+		customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+		type VARCHAR(20) NOT NULL CHECK (type IN ('billing', 'shipping')),
+		label VARCHAR(100) NOT NULL DEFAULT '',
+		line1 VARCHAR(200) NOT NULL,
+		line2 VARCHAR(200) NOT NULL DEFAULT '',
+		city VARCHAR(100) NOT NULL DEFAULT '',
+		region VARCHAR(100) NOT NULL DEFAULT '',
+		postcode VARCHAR(20) NOT NULL DEFAULT '',
+		country VARCHAR(100) NOT NULL DEFAULT '',
+		is_default BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS customer_addresses_customer_idx ON customer_addresses (customer_id);
+	CREATE UNIQUE INDEX IF NOT EXISTS customer_addresses_default_idx ON customer_addresses (customer_id, type) WHERE is_default;
+
+	CREATE TABLE IF NOT EXISTS customer_contacts (
+		id SERIAL PRIMARY KEY,
+		customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+		name VARCHAR(100) NOT NULL,
+		role VARCHAR(30) NOT NULL DEFAULT 'other',
+		email VARCHAR(150) NOT NULL DEFAULT '',
+		phone VARCHAR(30) NOT NULL DEFAULT '',
+		is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS customer_contacts_customer_idx ON customer_contacts (customer_id);
+	CREATE UNIQUE INDEX IF NOT EXISTS customer_contacts_primary_idx ON customer_contacts (customer_id) WHERE is_primary;
+
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_address_id INT REFERENCES customer_addresses(id) ON DELETE SET NULL;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_address JSONB;
+
+	-- Turn the legacy free-text address into default billing and shipping
+	-- addresses, and number/email into a primary contact. Countries are
+	-- turned into codes by normalizeCountries once this has run.
+	INSERT INTO customer_addresses (customer_id, type, label, line1, country, is_default)
+	SELECT c.id, t.type, 'Imported', TRIM(c.address), COALESCE(TRIM(c.country), ''), TRUE
+	FROM customers c CROSS JOIN (VALUES ('billing'), ('shipping')) AS t(type)
+	WHERE COALESCE(TRIM(c.address), '') <> ''
+		AND NOT EXISTS (SELECT 1 FROM data_migrations WHERE name = 'customer_addresses');
+
+	INSERT INTO customer_contacts (customer_id, name, role, email, phone, is_primary)
+	SELECT c.id, c.name, 'primary', COALESCE(TRIM(c.email), ''), COALESCE(TRIM(c.number), ''), TRUE
+	FROM customers c
+	WHERE (COALESCE(TRIM(c.email), '') <> '' OR COALESCE(TRIM(c.number), '') <> '')
+		AND NOT EXISTS (SELECT 1 FROM data_migrations WHERE name = 'customer_contacts');
+
+	INSERT INTO data_migrations (name) VALUES ('customer_addresses'), ('customer_contacts') ON CONFLICT DO NOTHING;
+
+	-- Trigram indexes back fuzzy customer search
+	CREATE EXTENSION IF NOT EXISTS pg_trgm;
+	CREATE INDEX IF NOT EXISTS customers_name_trgm_idx ON customers USING GIN (name gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS customers_email_trgm_idx ON customers USING GIN (email gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS customers_number_trgm_idx ON customers USING GIN (number gin_trgm_ops);
+
+	CREATE TABLE IF NOT EXISTS customer_merges (
+		id SERIAL PRIMARY KEY,
+		target_id INT REFERENCES customers(id) ON DELETE SET NULL,
+		source_id INT NOT NULL,
+		source_name VARCHAR(100) NOT NULL,
+		source JSONB NOT NULL,
+		orders_moved INT NOT NULL DEFAULT 0,
+		shipments_moved INT NOT NULL DEFAULT 0,
+		addresses_moved INT NOT NULL DEFAULT 0,
+		contacts_moved INT NOT NULL DEFAULT 0,
+		actor VARCHAR(150) NOT NULL,
+		merged_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS customer_merges_target_idx ON customer_merges (target_id);
+
+	CREATE INDEX IF NOT EXISTS shipments_order_id_idx ON shipments (order_id);
+	CREATE INDEX IF NOT EXISTS order_items_order_id_idx ON order_items (order_id);
+
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS external_ref VARCHAR(100);
+	CREATE UNIQUE INDEX IF NOT EXISTS orders_external_ref_idx ON orders (external_ref) WHERE external_ref IS NOT NULL;
+
+	-- shipment_items records what each shipment actually contained. The
+	-- legacy items array only held order item IDs, so existing shipments are
+	-- converted from it: a line in one shipment shipped the ordered quantity
+	-- less what is still due, and a line split across several shipments has
+	-- that spread across them and is flagged as estimated.
+	CREATE TABLE IF NOT EXISTS shipment_items (
+		id SERIAL PRIMARY KEY,
+		shipment_id INT NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+		order_item_id INT NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+		quantity INT NOT NULL CHECK (quantity > 0),
+		price DECIMAL(10, 2) NOT NULL DEFAULT 0,
+		estimated BOOLEAN NOT NULL DEFAULT FALSE,
+		UNIQUE (shipment_id, order_item_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS shipment_items_order_item_idx ON shipment_items (order_item_id);
+
+	INSERT INTO shipment_items (shipment_id, order_item_id, quantity, price, estimated)
+	SELECT shipment_id, order_item_id, quantity, price, listings > 1
+	FROM (
+		SELECT l.*, l.shipped / l.listings + CASE WHEN l.n = 1 THEN l.shipped % l.listings ELSE 0 END AS quantity
+		FROM (
+			SELECT s.id AS shipment_id, oi.id AS order_item_id, COALESCE(oi.price, 0) AS price,
+				oi.quantity - COALESCE(d.quantity, 0) AS shipped,
+				ROW_NUMBER() OVER (PARTITION BY oi.id ORDER BY s.shipped_date, s.id) AS n,
+				COUNT(*) OVER (PARTITION BY oi.id) AS listings
+			FROM shipments s
+			JOIN order_items oi ON oi.order_id = s.order_id AND oi.id = ANY(s.items)
+			LEFT JOIN due_orders d ON d.order_id = oi.order_id AND d.item_id = oi.id
+		) l
+	) q
+	WHERE quantity > 0
+		AND NOT EXISTS (SELECT 1 FROM data_migrations WHERE name = 'shipment_items')
+	ON CONFLICT DO NOTHING;
+
+	INSERT INTO data_migrations (name) VALUES ('shipment_items') ON CONFLICT DO NOTHING;
+
+	-- shipped_lines totals what has shipped of each order line
+	CREATE OR REPLACE VIEW shipped_lines AS
+	SELECT oi.order_id, oi.id AS order_item_id, oi.price, SUM(si.quantity)::int AS quantity,
+		SUM(si.quantity * si.price) AS value
+	FROM order_items oi
+	JOIN shipment_items si ON si.order_item_id = oi.id
+	GROUP BY oi.order_id, oi.id, oi.price;
+
+	ALTER TABLE shipments ADD COLUMN IF NOT EXISTS carrier VARCHAR(50) NOT NULL DEFAULT '';
+	ALTER TABLE shipments ADD COLUMN IF NOT EXISTS service_level VARCHAR(50) NOT NULL DEFAULT '';
+	ALTER TABLE shipments ADD COLUMN IF NOT EXISTS shipping_cost DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+	CREATE TABLE IF NOT EXISTS shipment_packages (
+		id SERIAL PRIMARY KEY,
+		shipment_id INT NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+		tracking_number VARCHAR(100) NOT NULL DEFAULT '',
+		weight_kg DECIMAL(10, 3) NOT NULL DEFAULT 0,
+		length_cm DECIMAL(10, 1) NOT NULL DEFAULT 0,
+		width_cm DECIMAL(10, 1) NOT NULL DEFAULT 0,
+		height_cm DECIMAL(10, 1) NOT NULL DEFAULT 0
+	);
+
+	CREATE INDEX IF NOT EXISTS shipment_packages_shipment_idx ON shipment_packages (shipment_id);
+	CREATE INDEX IF NOT EXISTS shipment_packages_tracking_idx ON shipment_packages (tracking_number) WHERE tracking_number <> '';
+
+	CREATE TABLE IF NOT EXISTS shipment_labels (
+		id SERIAL PRIMARY KEY,
+		shipment_id INT NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+		carrier VARCHAR(50) NOT NULL,
+		service_level VARCHAR(50) NOT NULL,
+		tracking_numbers TEXT[] NOT NULL DEFAULT '{}',
+		format VARCHAR(10) NOT NULL,
+		data BYTEA NOT NULL,
+		cost DECIMAL(10, 2) NOT NULL DEFAULT 0,
+		currency CHAR(3) NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		cancelled_at TIMESTAMPTZ
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS shipment_labels_active_idx ON shipment_labels (shipment_id) WHERE cancelled_at IS NULL;
+
+	ALTER TABLE shipments ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'created';
+	ALTER TABLE shipments ADD COLUMN IF NOT EXISTS delivered_date DATE;
+
+	CREATE TABLE IF NOT EXISTS shipment_events (
+		id SERIAL PRIMARY KEY,
+		shipment_id INT NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+		tracking_number VARCHAR(100) NOT NULL DEFAULT '',
+		status VARCHAR(20) NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		location VARCHAR(200) NOT NULL DEFAULT '',
+		occurred_at TIMESTAMPTZ NOT NULL,
+		source VARCHAR(50) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (shipment_id, tracking_number, status, occurred_at)
+	);
+
+	CREATE TABLE IF NOT EXISTS pick_lists (
+		id SERIAL PRIMARY KEY,
+		status VARCHAR(20) NOT NULL DEFAULT 'open',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS pick_list_orders (
+		pick_list_id INT NOT NULL REFERENCES pick_lists(id) ON DELETE CASCADE,
+		order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+		shipment_id INT REFERENCES shipments(id) ON DELETE SET NULL,
+		PRIMARY KEY (pick_list_id, order_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS pick_list_orders_order_idx ON pick_list_orders (order_id);
+
+	CREATE TABLE IF NOT EXISTS pick_list_items (
+		pick_list_id INT NOT NULL,
+		order_id INT NOT NULL,
+		order_item_id INT NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+		quantity INT NOT NULL CHECK (quantity > 0),
+		PRIMARY KEY (pick_list_id, order_item_id),
+		FOREIGN KEY (pick_list_id, order_id) REFERENCES pick_list_orders (pick_list_id, order_id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS pick_list_parcels (
+		id SERIAL PRIMARY KEY,
+		pick_list_id INT NOT NULL,
+		order_id INT NOT NULL,
+		weight_kg DECIMAL(10, 3) NOT NULL DEFAULT 0,
+		length_cm DECIMAL(10, 1) NOT NULL DEFAULT 0,
+		width_cm DECIMAL(10, 1) NOT NULL DEFAULT 0,
+		height_cm DECIMAL(10, 1) NOT NULL DEFAULT 0,
+		FOREIGN KEY (pick_list_id, order_id) REFERENCES pick_list_orders (pick_list_id, order_id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS pick_list_parcel_items (
+		parcel_id INT NOT NULL REFERENCES pick_list_parcels(id) ON DELETE CASCADE,
+		order_item_id INT NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+		quantity INT NOT NULL CHECK (quantity > 0),
+		PRIMARY KEY (parcel_id, order_item_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS products (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(100) NOT NULL,
+		description VARCHAR(200) NOT NULL DEFAULT '',
+		hs_code VARCHAR(14) NOT NULL DEFAULT '',
+		origin_country CHAR(2) NOT NULL DEFAULT 'NP',
+		net_weight_kg DECIMAL(10, 3) NOT NULL DEFAULT 0,
+		gross_weight_kg DECIMAL(10, 3) NOT NULL DEFAULT 0
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS products_name_idx ON products (LOWER(name));
+
+	CREATE TABLE IF NOT EXISTS order_destinations (
+		id SERIAL PRIMARY KEY,
+		order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+		label VARCHAR(100) NOT NULL DEFAULT '',
+		shipment_address TEXT NOT NULL DEFAULT '',
+		shipping_address_id INT REFERENCES customer_addresses(id) ON DELETE SET NULL,
+		shipping_address JSONB
+	);
+
+	CREATE INDEX IF NOT EXISTS order_destinations_order_idx ON order_destinations (order_id);
+
+	CREATE TABLE IF NOT EXISTS order_allocations (
+		destination_id INT NOT NULL REFERENCES order_destinations(id) ON DELETE CASCADE,
+		order_item_id INT NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+		quantity INT NOT NULL CHECK (quantity > 0),
+		PRIMARY KEY (destination_id, order_item_id)
+	);
+
+	ALTER TABLE shipments ADD COLUMN IF NOT EXISTS destination_id INT REFERENCES order_destinations(id) ON DELETE SET NULL;
+
+	-- Split orders owe each line per destination
+	ALTER TABLE due_orders ADD COLUMN IF NOT EXISTS destination_id INT REFERENCES order_destinations(id) ON DELETE CASCADE;
+	ALTER TABLE due_orders DROP CONSTRAINT IF EXISTS due_orders_order_id_item_id_key;
+	CREATE UNIQUE INDEX IF NOT EXISTS due_orders_line_idx ON due_orders (order_id, item_id, COALESCE(destination_id, 0));
+
+	-- Parcels of a split order are packed for, and ship to, one destination
+	ALTER TABLE pick_list_parcels ADD COLUMN IF NOT EXISTS destination_id INT REFERENCES order_destinations(id) ON DELETE CASCADE;
+	ALTER TABLE pick_list_parcels ADD COLUMN IF NOT EXISTS shipment_id INT REFERENCES shipments(id) ON DELETE SET NULL;
+
+	-- Orders with no shipments owe nothing in due_orders; deleting an
+	-- order's last shipment used to leave a full set behind
+	DELETE FROM due_orders d
+	WHERE NOT EXISTS (SELECT 1 FROM shipments s WHERE s.order_id = d.order_id);
+
+	ALTER TABLE customers ADD COLUMN IF NOT EXISTS unverified_country VARCHAR(100) NOT NULL DEFAULT '';
+	ALTER TABLE customer_addresses ADD COLUMN IF NOT EXISTS unverified_country VARCHAR(100) NOT NULL DEFAULT '';
 	`)
+	if err != nil {
+		return err
+	}
+
+	// Addresses imported from the legacy fields copied their country as is
+	for _, table := range []string{"customers", "customer_addresses"} {
+		if err := normalizeCountries(s.DB, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	return err
+// normalizeCountries rewrites the free-text countries left in a table by
+// older versions as alpha-2 codes. Values that aren't recognised are moved
+// to unverified_country and the country left blank, so the row passes
+// validation on its next edit and the original is kept for someone to fix.
+func normalizeCountries(db *sql.DB, table string) error {
+	rows, err := db.Query(`SELECT DISTINCT country FROM ` + table + ` WHERE COALESCE(country, '') <> ''`)
+	if err != nil {
+		return fmt.Errorf("failed to read %s countries: %v", table, err)
+	}
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return err
+		}
+		values = append(values, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, v := range values {
+		code, ok := models.NormalizeCountry(v)
+		if ok && code == v {
+			continue
+		}
+		if ok {
+			_, err = db.Exec(`UPDATE `+table+` SET country = $2 WHERE country = $1`, v, code)
+		} else {
+			_, err = db.Exec(`UPDATE `+table+` SET country = '', unverified_country = TRIM($1) WHERE country = $1`, v)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to normalize %s country %q: %v", table, v, err)
+		}
+	}
+	return nil
 }
 
 //	func NewPostgresStorage() (*PostgresStorage, error) {
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"fmt"
+)
+
+func (s *PostgresStorage) RecordOrderNotification(n models.OrderNotification) error {
+	query := `
+		INSERT INTO order_notifications (order_id, event, recipient, subject, status, error)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+	`
+	_, err := s.DB.Exec(query, n.OrderID, n.Event, n.Recipient, n.Subject, n.Status, n.Error)
+	if err != nil {
+		return fmt.Errorf("failed to record notification: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetOrderNotifications(orderID int) ([]models.OrderNotification, error) {
+	query := `
+		SELECT id, order_id, event, COALESCE(recipient, ''), COALESCE(subject, ''), status, COALESCE(error, ''), sent_at
+		FROM order_notifications
+		WHERE order_id = $1
+		ORDER BY sent_at, id
+	`
+	rows, err := s.DB.Query(query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch notifications: %v", err)
+	}
+	defer rows.Close()
+
+	notifications := []models.OrderNotification{}
+	for rows.Next() {
+		var n models.OrderNotification
+		if err := rows.Scan(&n.ID, &n.OrderID, &n.Event, &n.Recipient, &n.Subject, &n.Status, &n.Error, &n.SentAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %v", err)
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
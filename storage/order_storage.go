@@ -3,16 +3,19 @@ package storage
 import (
 	"AAHAOMS/OMS/models"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 )
 
+// getOrderStatus locks the order for the rest of tx and returns its status
 func (s *PostgresStorage) getOrderStatus(tx *sql.Tx, orderID int) (string, error) {
 	var orderStatus string
-	err := tx.QueryRow(`SELECT order_status FROM orders WHERE id = $1`, orderID).Scan(&orderStatus)
+	err := tx.QueryRow(`SELECT order_status FROM orders WHERE id = $1 FOR UPDATE`, orderID).Scan(&orderStatus)
 	if err != nil {
 		log.Printf("Invalid order ID: %v", err)
-		return "", fmt.Errorf("invalid order ID: %v", err)
+		return "", notFound(err, "order %d", orderID)
 	}
 	return orderStatus, nil
 }
@@ -34,18 +37,89 @@ func (s *PostgresStorage) TotalOrderCount() (int, error) {
 	return totalCount, nil
 }
 
+// CreateOrder inserts the order and its items, and queues the order.created
+// outbox job in the same transaction.
 func (s *PostgresStorage) CreateOrder(order models.Order) (int, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	orderID, err := createOrder(tx, order)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := enqueueJob(tx, models.JobOrderCreated, map[string]int{"order_id": orderID}, ""); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return orderID, nil
+}
+
+// shippingAddressSnapshot loads one of a customer's shipping addresses and
+// encodes it for keeping with an order
+func shippingAddressSnapshot(tx *sql.Tx, customerID, addressID int) (models.CustomerAddress, []byte, error) {
+	address, err := getCustomerAddress(tx, customerID, addressID)
+	if errors.Is(err, ErrNotFound) {
+		return address, nil, fmt.Errorf("shipping address %d does not belong to customer %d: %w", addressID, customerID, ErrValidation)
+	} else if err != nil {
+		return address, nil, err
+	}
+	if address.Type != models.AddressShipping {
+		return address, nil, fmt.Errorf("address %d is not a shipping address: %w", address.ID, ErrValidation)
+	}
+	snapshot, err := json.Marshal(address)
+	return address, snapshot, err
+}
+
+// createOrder inserts the order and its items within tx
+func createOrder(tx *sql.Tx, order models.Order) (int, error) {
 	if order.OrderStatus == "" {
 		order.OrderStatus = "pending"
 	}
 
+	// customer_name is a denormalized copy, always taken from the customer record
+	err := tx.QueryRow(`SELECT name FROM customers WHERE id = $1`, order.CustomerID).Scan(&order.CustomerName)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("customer %d does not exist: %w", order.CustomerID, ErrValidation)
+	} else if err != nil {
+		return 0, err
+	}
+
+	// Snapshot the chosen shipping address, falling back to the customer's
+	// default one when no free-text address was given either
+	var snapshot []byte
+	if order.ShippingAddressID == nil && order.ShipmentAddress == "" {
+		var id int
+		err := tx.QueryRow(`SELECT id FROM customer_addresses WHERE customer_id = $1 AND type = 'shipping' AND is_default`, order.CustomerID).Scan(&id)
+		if err == nil {
+			order.ShippingAddressID = &id
+		} else if err != sql.ErrNoRows {
+			return 0, err
+		}
+	}
+	if order.ShippingAddressID != nil {
+		address, data, err := shippingAddressSnapshot(tx, order.CustomerID, *order.ShippingAddressID)
+		if err != nil {
+			return 0, err
+		}
+		snapshot = data
+		order.ShipmentAddress = address.String()
+	}
+
 	query := `
-		INSERT INTO orders (customer_id, customer_name, order_date, shipment_due, shipment_address, order_status, total_price, no_of_items)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO orders (customer_id, customer_name, order_date, shipment_due, shipment_address, order_status, total_price, no_of_items, shipping_address_id, shipping_address, external_ref)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''))
 		RETURNING id
 	`
 	var orderID int
-	err := s.DB.QueryRow(
+	err = tx.QueryRow(
 		query,
 		order.CustomerID,
 		order.CustomerName,
@@ -55,9 +129,12 @@ func (s *PostgresStorage) CreateOrder(order models.Order) (int, error) {
 		order.OrderStatus,
 		order.TotalPrice,
 		order.NoOfItems,
+		order.ShippingAddressID,
+		snapshot,
+		order.ExternalRef,
 	).Scan(&orderID)
 	if err != nil {
-		return 0, err
+		return 0, classify(err)
 	}
 
 	for _, item := range order.Items {
@@ -67,9 +144,9 @@ func (s *PostgresStorage) CreateOrder(order models.Order) (int, error) {
 			RETURNING id
 		`
 		var itemID int
-		err = s.DB.QueryRow(itemQuery, orderID, item.Name, item.Size, item.Color, item.Price, item.Quantity).Scan(&itemID)
+		err = tx.QueryRow(itemQuery, orderID, item.Name, item.Size, item.Color, item.Price, item.Quantity).Scan(&itemID)
 		if err != nil {
-			return 0, err
+			return 0, classify(err)
 		}
 		item.ID = itemID // Assign the auto-generated ID back to the item struct
 	}
@@ -118,49 +195,90 @@ func (s *PostgresStorage) GetLatestOrderID() (int, error) {
 	return latestOrderID, nil
 }
 
+// GetOrderHistoryByCustomerName resolves the name to a customer exactly
+// (ignoring case) and returns that customer's orders.
 func (s *PostgresStorage) GetOrderHistoryByCustomerName(name string) ([]models.Order, error) {
-	query := `
-		SELECT id, customer_id, customer_name, order_date, shipment_due, shipment_address, order_status, total_price, no_of_items
+	return s.queryOrders(`
+		SELECT `+orderColumns+`
 		FROM orders
-		WHERE customer_name = $1
-	`
-	rows, err := s.DB.Query(query, name)
+		WHERE customer_id IN (SELECT id FROM customers WHERE LOWER(name) = LOWER(TRIM($1)))
+		ORDER BY order_date DESC, id DESC
+	`, name)
+}
+
+// GetOrdersByCustomerID returns a customer's orders, newest first
+func (s *PostgresStorage) GetOrdersByCustomerID(customerID int) ([]models.Order, error) {
+	return s.queryOrders(`
+		SELECT `+orderColumns+`
+		FROM orders
+		WHERE customer_id = $1
+		ORDER BY order_date DESC, id DESC
+	`, customerID)
+}
+
+const orderColumns = `id, COALESCE(external_ref, ''), customer_id, customer_name, order_date, shipment_due, COALESCE(shipment_address, ''),
+	order_status, total_price, no_of_items, shipping_address_id, shipping_address`
+
+func scanOrder(row rowScanner, order *models.Order) error {
+	var addressID sql.NullInt64
+	var snapshot []byte
+	err := row.Scan(&order.ID, &order.ExternalRef, &order.CustomerID, &order.CustomerName, &order.OrderDate, &order.ShipmentDue, &order.ShipmentAddress,
+		&order.OrderStatus, &order.TotalPrice, &order.NoOfItems, &addressID, &snapshot)
+	if err != nil {
+		return err
+	}
+	if addressID.Valid {
+		id := int(addressID.Int64)
+		order.ShippingAddressID = &id
+	}
+	if snapshot != nil {
+		order.ShippingAddress = &models.CustomerAddress{}
+		if err := json.Unmarshal(snapshot, order.ShippingAddress); err != nil {
+			return fmt.Errorf("failed to decode shipping address for order %d: %v", order.ID, err)
+		}
+	}
+	return nil
+}
+
+// queryOrders runs an orders query selecting the standard columns and loads each order's items
+func (s *PostgresStorage) queryOrders(query string, args ...any) ([]models.Order, error) {
+	rows, err := s.DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var orders []models.Order
+	orders := []models.Order{}
 	for rows.Next() {
 		var order models.Order
-		err = rows.Scan(&order.ID, &order.CustomerID, &order.CustomerName, &order.OrderDate, &order.ShipmentDue, &order.ShipmentAddress, &order.OrderStatus, &order.TotalPrice, &order.NoOfItems)
-		if err != nil {
+		if err := scanOrder(rows, &order); err != nil {
 			return nil, err
 		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
 
-		// Fetch items for this order
-		itemQuery := `
+	for i := range orders {
+		itemRows, err := s.DB.Query(`
 			SELECT id, name, size, color, price, quantity
 			FROM order_items
 			WHERE order_id = $1
-		`
-		itemRows, err := s.DB.Query(itemQuery, order.ID)
+		`, orders[i].ID)
 		if err != nil {
 			return nil, err
 		}
-		defer itemRows.Close()
-
-		var items []models.Item
 		for itemRows.Next() {
 			var item models.Item
-			err = itemRows.Scan(&item.ID, &item.Name, &item.Size, &item.Color, &item.Price, &item.Quantity)
-			if err != nil {
+			if err := itemRows.Scan(&item.ID, &item.Name, &item.Size, &item.Color, &item.Price, &item.Quantity); err != nil {
+				itemRows.Close()
 				return nil, err
 			}
-			items = append(items, item)
+			orders[i].Items = append(orders[i].Items, item)
 		}
-		order.Items = items
-		orders = append(orders, order)
+		itemRows.Close()
 	}
 
 	return orders, nil
@@ -169,16 +287,13 @@ func (s *PostgresStorage) GetOrderHistoryByCustomerName(name string) ([]models.O
 func (s *PostgresStorage) GetOrderByID(orderID int) (models.Order, error) {
 	var order models.Order
 	query := `
-		SELECT id, customer_id, customer_name, order_date, shipment_due, shipment_address, order_status, total_price, no_of_items
+		SELECT `+orderColumns+`
 		FROM orders
 		WHERE id = $1
 	`
-	err := s.DB.QueryRow(query, orderID).Scan(
-		&order.ID, &order.CustomerID, &order.CustomerName, &order.OrderDate, &order.ShipmentDue, &order.ShipmentAddress,
-		&order.OrderStatus, &order.TotalPrice, &order.NoOfItems,
-	)
+	err := scanOrder(s.DB.QueryRow(query, orderID), &order)
 	if err != nil {
-		return models.Order{}, err
+		return models.Order{}, notFound(err, "order %d", orderID)
 	}
 
 	itemQuery := `
@@ -207,54 +322,10 @@ func (s *PostgresStorage) GetOrderByID(orderID int) (models.Order, error) {
 }
 
 func (s *PostgresStorage) GetAllOrders() ([]models.Order, error) {
-	query := `
-		SELECT id, customer_id, customer_name, order_date, shipment_due, shipment_address, order_status, total_price, no_of_items
+	return s.queryOrders(`
+		SELECT `+orderColumns+`
 		FROM orders
-	`
-	rows, err := s.DB.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var orders []models.Order
-	for rows.Next() {
-		var order models.Order
-		err = rows.Scan(
-			&order.ID, &order.CustomerID, &order.CustomerName, &order.OrderDate, &order.ShipmentDue, &order.ShipmentAddress,
-			&order.OrderStatus, &order.TotalPrice, &order.NoOfItems,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		// Fetch items for each order
-		itemQuery := `
-			SELECT id, name, size, color, price, quantity
-			FROM order_items
-			WHERE order_id = $1
-		`
-		itemRows, err := s.DB.Query(itemQuery, order.ID)
-		if err != nil {
-			return nil, err
-		}
-		defer itemRows.Close()
-
-		var items []models.Item
-		for itemRows.Next() {
-			var item models.Item
-			err = itemRows.Scan(&item.ID, &item.Name, &item.Size, &item.Color, &item.Price, &item.Quantity)
-			if err != nil {
-				return nil, err
-			}
-			items = append(items, item)
-		}
-		order.Items = items
-
-		orders = append(orders, order)
-	}
-
-	return orders, nil
+	`)
 }
 
 func (s *PostgresStorage) DeleteOrder(orderID int) error {
@@ -278,10 +349,13 @@ func (s *PostgresStorage) DeleteOrder(orderID int) error {
 	}
 
 	orderQuery := `DELETE FROM orders WHERE id = $1`
-	_, err = tx.Exec(orderQuery, orderID)
+	res, err := tx.Exec(orderQuery, orderID)
 	if err != nil {
 		return fmt.Errorf("failed to delete order: %v", err)
 	}
+	if err := requireRow(res, "order %d", orderID); err != nil {
+		return err
+	}
 
 	// Commit the transaction
 	if err := tx.Commit(); err != nil {
@@ -298,19 +372,22 @@ func (s *PostgresStorage) UpdateOrderStatus(orderID int, status string) error {
 		SET order_status = $1
 		WHERE id = $2
 	`
-	_, err := s.DB.Exec(query, status, orderID)
-	return err
+	res, err := s.DB.Exec(query, status, orderID)
+	if err != nil {
+		return err
+	}
+	return requireRow(res, "order %d", orderID)
 }
 
 func (s *PostgresStorage) GetTotalOrderValueByCustomerName(customerName string) (float64, error) {
 	query := `
 		SELECT COALESCE(SUM(i.price * i.quantity), 0) AS total_value
 		FROM orders o
-		LEFT JOIN items i ON o.id = i.order_id
-		WHERE o.customer_name ILIKE $1
+		JOIN order_items i ON o.id = i.order_id
+		WHERE o.customer_id IN (SELECT id FROM customers WHERE LOWER(name) = LOWER(TRIM($1)))
 	`
 	var totalValue float64
-	err := s.DB.QueryRow(query, "%"+customerName+"%").Scan(&totalValue)
+	err := s.DB.QueryRow(query, customerName).Scan(&totalValue)
 	return totalValue, err
 }
 
@@ -318,13 +395,28 @@ func (s *PostgresStorage) GetOrderCountByCustomerName(customerName string) (int,
 	query := `
 		SELECT COUNT(*)
 		FROM orders
-		WHERE customer_name ILIKE $1
+		WHERE customer_id IN (SELECT id FROM customers WHERE LOWER(name) = LOWER(TRIM($1)))
 	`
 	var orderCount int
-	err := s.DB.QueryRow(query, "%"+customerName+"%").Scan(&orderCount)
+	err := s.DB.QueryRow(query, customerName).Scan(&orderCount)
 	return orderCount, err
 }
 
+// GetCustomerSales totals a customer's orders by ID
+func (s *PostgresStorage) GetCustomerSales(customerID int) (models.CustomerSales, error) {
+	sales := models.CustomerSales{CustomerID: customerID}
+	query := `
+		SELECT
+			COUNT(*),
+			COALESCE(SUM((SELECT SUM(i.price * i.quantity) FROM order_items i WHERE i.order_id = o.id)), 0),
+			COALESCE(SUM(o.total_price) FILTER (WHERE TRIM(o.order_status) IN ('shipped', 'delivered')), 0)
+		FROM orders o
+		WHERE o.customer_id = $1
+	`
+	err := s.DB.QueryRow(query, customerID).Scan(&sales.OrderCount, &sales.TotalOrderValue, &sales.ShippedSales)
+	return sales, err
+}
+
 func (s *PostgresStorage) GetPendingOrderCount() (int, error) {
 	query := `
 	SELECT COUNT(*)
@@ -344,114 +436,36 @@ func (s *PostgresStorage) GetPendingOrderCount() (int, error) {
 }
 
 func (s *PostgresStorage) GetOrdersByNameAndDate(customerName, orderDate string) ([]models.Order, error) {
-	query := `
-		SELECT id, customer_id, customer_name, order_date, shipment_due, shipment_address, order_status, total_price, no_of_items
+	orders, err := s.queryOrders(`
+		SELECT `+orderColumns+`
 		FROM orders
-		WHERE customer_name ILIKE $1 AND order_date = $2
-	`
-
-	rows, err := s.DB.Query(query, "%"+customerName+"%", orderDate)
+		WHERE customer_id IN (SELECT id FROM customers WHERE LOWER(name) = LOWER(TRIM($1))) AND order_date = $2
+	`, customerName, orderDate)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %w", err)
 	}
-	defer rows.Close()
-
-	var orders []models.Order
-	for rows.Next() {
-		var order models.Order
-		err = rows.Scan(
-			&order.ID, &order.CustomerID, &order.CustomerName, &order.OrderDate, &order.ShipmentDue,
-			&order.ShipmentAddress, &order.OrderStatus, &order.TotalPrice, &order.NoOfItems,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("row scan error: %w", err)
-		}
-
-		itemQuery := `
-			SELECT id, name, size, color, price, quantity
-			FROM order_items
-			WHERE order_id = $1
-		`
-		itemRows, err := s.DB.Query(itemQuery, order.ID)
-		if err != nil {
-			return nil, fmt.Errorf("item query error: %w", err)
-		}
-		defer itemRows.Close()
-
-		var items []models.Item
-		for itemRows.Next() {
-			var item models.Item
-			err = itemRows.Scan(&item.ID, &item.Name, &item.Size, &item.Color, &item.Price, &item.Quantity)
-			if err != nil {
-				return nil, fmt.Errorf("item scan error: %w", err)
-			}
-			items = append(items, item)
-		}
-		order.Items = items
-		orders = append(orders, order)
-	}
-
 	return orders, nil
 }
 
 func (s *PostgresStorage) GetRecentOrders(limit int) ([]models.Order, error) {
-	query := `
-		SELECT id, customer_id, customer_name, order_date, shipment_due, shipment_address, order_status, total_price, no_of_items
+	orders, err := s.queryOrders(`
+		SELECT `+orderColumns+`
 		FROM orders
 		ORDER BY order_date DESC
 		LIMIT $1
-	`
-	rows, err := s.DB.Query(query, limit)
+	`, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch recent orders: %w", err)
 	}
-	defer rows.Close()
-
-	var orders []models.Order
-	for rows.Next() {
-		var order models.Order
-		err = rows.Scan(
-			&order.ID, &order.CustomerID, &order.CustomerName, &order.OrderDate, &order.ShipmentDue,
-			&order.ShipmentAddress, &order.OrderStatus, &order.TotalPrice, &order.NoOfItems,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan order: %w", err)
-		}
-
-		// Fetch items for the order
-		itemQuery := `
-			SELECT id, name, size, color, price, quantity
-			FROM order_items
-			WHERE order_id = $1
-		`
-		itemRows, err := s.DB.Query(itemQuery, order.ID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to fetch order items: %w", err)
-		}
-		defer itemRows.Close()
-
-		var items []models.Item
-		for itemRows.Next() {
-			var item models.Item
-			err = itemRows.Scan(&item.ID, &item.Name, &item.Size, &item.Color, &item.Price, &item.Quantity)
-			if err != nil {
-				return nil, fmt.Errorf("failed to scan order item: %w", err)
-			}
-			items = append(items, item)
-		}
-		order.Items = items
-		orders = append(orders, order)
-	}
-
 	return orders, nil
 }
 
 func (s *PostgresStorage) GetCompletedShipments() ([]models.Shipment, error) {
 	query := `
-		SELECT s.id, s.order_id, s.shipped_date::DATE, s.items::int[], s.due_order_type
+		SELECT `+shipmentColumns+`
 		FROM shipments s
 		INNER JOIN orders o ON s.order_id = o.id
-		WHERE o.order_status = 'shipped'
+		WHERE o.order_status IN ('shipped', 'delivered')
 	`
 
 	rows, err := s.DB.Query(query)
@@ -462,35 +476,43 @@ func (s *PostgresStorage) GetCompletedShipments() ([]models.Shipment, error) {
 
 	return s.parseShipments(rows)
 }
+// DeleteShipment removes a shipment and puts what it shipped back into the
+// order's outstanding quantities
 func (s *PostgresStorage) DeleteShipment(shipmentID int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
 
 	var orderID int
 	getOrderQuery := `SELECT order_id FROM shipments WHERE id = $1`
-	err := s.DB.QueryRow(getOrderQuery, shipmentID).Scan(&orderID)
+	err = tx.QueryRow(getOrderQuery, shipmentID).Scan(&orderID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return fmt.Errorf("shipment ID %d not found", shipmentID)
+			return fmt.Errorf("shipment %d: %w", shipmentID, ErrNotFound)
 		}
 		return fmt.Errorf("failed to fetch order ID for shipment ID %d: %v", shipmentID, err)
 	}
+	if _, err := s.getOrderStatus(tx, orderID); err != nil {
+		return err
+	}
 
 	deleteShipmentQuery := `DELETE FROM shipments WHERE id = $1`
-	_, err = s.DB.Exec(deleteShipmentQuery, shipmentID)
+	_, err = tx.Exec(deleteShipmentQuery, shipmentID)
 	if err != nil {
 		return fmt.Errorf("failed to delete shipment ID %d: %v", shipmentID, err)
 	}
 
-	updateOrderQuery := `UPDATE orders SET order_status = 'pending' WHERE id = $1`
-	_, err = s.DB.Exec(updateOrderQuery, orderID)
-	if err != nil {
-		return fmt.Errorf("failed to reset order status for order ID %d: %v", orderID, err)
+	if _, err := s.refreshOrderFulfillment(tx, orderID); err != nil {
+		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
 func (s *PostgresStorage) GetShippedButPendingShipments() ([]models.Shipment, error) {
 	query := `
-		SELECT s.id, s.order_id, s.shipped_date::DATE, s.items::int[], s.due_order_type
+		SELECT `+shipmentColumns+`
 		FROM shipments s
 		INNER JOIN orders o ON s.order_id = o.id
 		WHERE o.order_status = 'shipped and due'
@@ -507,7 +529,7 @@ func (s *PostgresStorage) GetShippedButPendingShipments() ([]models.Shipment, er
 
 
 func (s *PostgresStorage) getOrderItems(tx *sql.Tx, orderID int) (map[int]models.Item, error) {
-	rows, err := tx.Query(`SELECT id, name, quantity FROM order_items WHERE order_id = $1`, orderID)
+	rows, err := tx.Query(`SELECT id, name, COALESCE(price, 0), quantity FROM order_items WHERE order_id = $1`, orderID)
 	if err != nil {
 		log.Printf("Error fetching order items: %v", err)
 		return nil, fmt.Errorf("failed to fetch order items: %v", err)
@@ -517,7 +539,7 @@ func (s *PostgresStorage) getOrderItems(tx *sql.Tx, orderID int) (map[int]models
 	orderItems := make(map[int]models.Item)
 	for rows.Next() {
 		var item models.Item
-		if err := rows.Scan(&item.ID, &item.Name, &item.Quantity); err != nil {
+		if err := rows.Scan(&item.ID, &item.Name, &item.Price, &item.Quantity); err != nil {
 			log.Printf("Error scanning order item: %v", err)
 			return nil, fmt.Errorf("failed to scan order item: %v", err)
 		}
@@ -0,0 +1,485 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+// CreatePickList puts what is still outstanding on each order onto a new
+// pick list. An order can only be on one unshipped pick list at a time.
+func (s *PostgresStorage) CreatePickList(orderIDs []int) (int, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var listID int
+	if err := tx.QueryRow(`INSERT INTO pick_lists (status) VALUES ($1) RETURNING id`, models.PickListOpen).Scan(&listID); err != nil {
+		return 0, fmt.Errorf("failed to create pick list: %v", err)
+	}
+
+	// Lock orders in ID order so concurrent lists can't deadlock
+	ids := append([]int(nil), orderIDs...)
+	sort.Ints(ids)
+	for _, orderID := range ids {
+		if _, err := s.getOrderStatus(tx, orderID); err != nil {
+			return 0, err
+		}
+
+		var other int
+		err := tx.QueryRow(`
+			SELECT pl.id
+			FROM pick_list_orders plo
+			JOIN pick_lists pl ON pl.id = plo.pick_list_id
+			WHERE plo.order_id = $1 AND plo.shipment_id IS NULL AND pl.status IN ($2, $3)
+			LIMIT 1
+		`, orderID, models.PickListOpen, models.PickListPacked).Scan(&other)
+		if err == nil {
+			return 0, fmt.Errorf("order %d is already on pick list %d: %w", orderID, other, ErrConflict)
+		}
+		if err != sql.ErrNoRows {
+			return 0, fmt.Errorf("failed to check pick lists for order %d: %v", orderID, err)
+		}
+
+		orderItems, err := s.getOrderItems(tx, orderID)
+		if err != nil {
+			return 0, err
+		}
+		shipped, err := s.shippedQuantities(tx, orderID, nil)
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err := tx.Exec(`INSERT INTO pick_list_orders (pick_list_id, order_id) VALUES ($1, $2)`, listID, orderID); err != nil {
+			return 0, fmt.Errorf("failed to add order %d to pick list: %v", orderID, err)
+		}
+		outstanding := 0
+		for _, item := range orderItems {
+			quantity := item.Quantity - shipped[item.ID]
+			if quantity <= 0 {
+				continue
+			}
+			outstanding++
+			_, err := tx.Exec(`
+				INSERT INTO pick_list_items (pick_list_id, order_id, order_item_id, quantity)
+				VALUES ($1, $2, $3, $4)
+			`, listID, orderID, item.ID, quantity)
+			if err != nil {
+				return 0, fmt.Errorf("failed to add item %d to pick list: %v", item.ID, err)
+			}
+		}
+		if outstanding == 0 {
+			return 0, fmt.Errorf("order %d has nothing left to ship: %w", orderID, ErrValidation)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit pick list: %v", err)
+	}
+	return listID, nil
+}
+
+// GetPickLists lists pick lists newest first with their orders, optionally
+// only those in status
+func (s *PostgresStorage) GetPickLists(status string) ([]models.PickList, error) {
+	rows, err := s.DB.Query(`
+		SELECT pl.id, pl.status, pl.created_at, plo.order_id, COALESCE(o.customer_name, ''), plo.shipment_id
+		FROM pick_lists pl
+		JOIN pick_list_orders plo ON plo.pick_list_id = pl.id
+		JOIN orders o ON o.id = plo.order_id
+		WHERE $1 = '' OR pl.status = $1
+		ORDER BY pl.id DESC, plo.order_id
+	`, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pick lists: %v", err)
+	}
+	defer rows.Close()
+
+	lists := []models.PickList{}
+	for rows.Next() {
+		var l models.PickList
+		var o models.PickListOrder
+		if err := rows.Scan(&l.ID, &l.Status, &l.CreatedAt, &o.OrderID, &o.CustomerName, &o.ShipmentID); err != nil {
+			return nil, fmt.Errorf("failed to scan pick list: %v", err)
+		}
+		if n := len(lists); n == 0 || lists[n-1].ID != l.ID {
+			lists = append(lists, l)
+		}
+		lists[len(lists)-1].Orders = append(lists[len(lists)-1].Orders, o)
+	}
+	return lists, rows.Err()
+}
+
+// GetPickList returns a pick list with each order's items and parcels, and
+// the items grouped for picking
+func (s *PostgresStorage) GetPickList(id int) (models.PickList, error) {
+	var list models.PickList
+	err := s.DB.QueryRow(`SELECT id, status, created_at FROM pick_lists WHERE id = $1`, id).Scan(&list.ID, &list.Status, &list.CreatedAt)
+	if err != nil {
+		return list, notFound(err, "pick list %d", id)
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT plo.order_id, COALESCE(o.customer_name, ''), plo.shipment_id
+		FROM pick_list_orders plo
+		JOIN orders o ON o.id = plo.order_id
+		WHERE plo.pick_list_id = $1
+		ORDER BY plo.order_id
+	`, id)
+	if err != nil {
+		return list, fmt.Errorf("failed to fetch pick list orders: %v", err)
+	}
+	defer rows.Close()
+
+	index := make(map[int]int)
+	for rows.Next() {
+		var o models.PickListOrder
+		if err := rows.Scan(&o.OrderID, &o.CustomerName, &o.ShipmentID); err != nil {
+			return list, fmt.Errorf("failed to scan pick list order: %v", err)
+		}
+		index[o.OrderID] = len(list.Orders)
+		list.Orders = append(list.Orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return list, err
+	}
+
+	if err := s.loadPickListItems(&list, index); err != nil {
+		return list, err
+	}
+	if err := s.loadPickListParcels(&list, index); err != nil {
+		return list, err
+	}
+	list.Lines = models.GroupPickLines(list.Orders)
+	return list, nil
+}
+
+func (s *PostgresStorage) loadPickListItems(list *models.PickList, index map[int]int) error {
+	rows, err := s.DB.Query(`
+		SELECT pli.order_id, oi.id, COALESCE(oi.name, ''), oi.size, oi.color, pli.quantity,
+			COALESCE((
+				SELECT SUM(ppi.quantity)
+				FROM pick_list_parcel_items ppi
+				JOIN pick_list_parcels pp ON pp.id = ppi.parcel_id
+				WHERE pp.pick_list_id = pli.pick_list_id AND ppi.order_item_id = pli.order_item_id
+			), 0)
+		FROM pick_list_items pli
+		JOIN order_items oi ON oi.id = pli.order_item_id
+		WHERE pli.pick_list_id = $1
+		ORDER BY pli.order_id, oi.id
+	`, list.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch pick list items: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderID int
+		var item models.PickListItem
+		if err := rows.Scan(&orderID, &item.ID, &item.Name, &item.Size, &item.Color, &item.Quantity, &item.Packed); err != nil {
+			return fmt.Errorf("failed to scan pick list item: %v", err)
+		}
+		o := &list.Orders[index[orderID]]
+		o.Items = append(o.Items, item)
+	}
+	return rows.Err()
+}
+
+func (s *PostgresStorage) loadPickListParcels(list *models.PickList, index map[int]int) error {
+	rows, err := s.DB.Query(`
+		SELECT pp.id, pp.order_id, pp.destination_id, pp.shipment_id, pp.weight_kg, pp.length_cm, pp.width_cm, pp.height_cm,
+			ppi.order_item_id, ppi.quantity
+		FROM pick_list_parcels pp
+		JOIN pick_list_parcel_items ppi ON ppi.parcel_id = pp.id
+		WHERE pp.pick_list_id = $1
+		ORDER BY pp.id, ppi.order_item_id
+	`, list.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch pick list parcels: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.PackParcel
+		var orderID int
+		var item models.PackedItem
+		err := rows.Scan(&p.ID, &orderID, &p.DestinationID, &p.ShipmentID, &p.WeightKg, &p.LengthCm, &p.WidthCm, &p.HeightCm,
+			&item.ID, &item.Quantity)
+		if err != nil {
+			return fmt.Errorf("failed to scan pick list parcel: %v", err)
+		}
+		o := &list.Orders[index[orderID]]
+		if n := len(o.Parcels); n == 0 || o.Parcels[n-1].ID != p.ID {
+			o.Parcels = append(o.Parcels, p)
+		}
+		last := &o.Parcels[len(o.Parcels)-1]
+		last.Items = append(last.Items, item)
+	}
+	return rows.Err()
+}
+
+// lockPickList locks a pick list for the rest of tx and returns its status
+func lockPickList(tx *sql.Tx, id int) (string, error) {
+	var status string
+	err := tx.QueryRow(`SELECT status FROM pick_lists WHERE id = $1 FOR UPDATE`, id).Scan(&status)
+	if err != nil {
+		return "", notFound(err, "pick list %d", id)
+	}
+	return status, nil
+}
+
+// SavePickListPacking replaces the parcels of the orders in req. Each line
+// can be packed up to the quantity picked for it, and for a split order up
+// to what is still due at each parcel's destination; anything left unpacked
+// stays outstanding on the order when it ships.
+func (s *PostgresStorage) SavePickListPacking(id int, req models.PackRequest) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	status, err := lockPickList(tx, id)
+	if err != nil {
+		return err
+	}
+	if status != models.PickListOpen && status != models.PickListPacked {
+		return fmt.Errorf("pick list %d is %s: %w", id, status, ErrConflict)
+	}
+
+	for _, order := range req.Orders {
+		var shipmentID sql.NullInt64
+		err := tx.QueryRow(`SELECT shipment_id FROM pick_list_orders WHERE pick_list_id = $1 AND order_id = $2`, id, order.OrderID).Scan(&shipmentID)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("order %d is not on pick list %d: %w", order.OrderID, id, ErrValidation)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch order %d on pick list: %v", order.OrderID, err)
+		}
+		if shipmentID.Valid {
+			return fmt.Errorf("order %d has already shipped as shipment %d: %w", order.OrderID, shipmentID.Int64, ErrConflict)
+		}
+
+		picked, err := pickedQuantities(tx, id, order.OrderID)
+		if err != nil {
+			return err
+		}
+		packed := make(map[int]int)
+		for _, parcel := range order.Parcels {
+			for _, item := range parcel.Items {
+				if _, ok := picked[item.ID]; !ok {
+					return fmt.Errorf("item %d is not picked for order %d: %w", item.ID, order.OrderID, ErrValidation)
+				}
+				packed[item.ID] += item.Quantity
+			}
+		}
+		for itemID, quantity := range packed {
+			if quantity > picked[itemID] {
+				return fmt.Errorf("packed quantity for item %d exceeds the %d picked: %w", itemID, picked[itemID], ErrValidation)
+			}
+		}
+		if err := s.checkPackedDestinations(tx, order); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`DELETE FROM pick_list_parcels WHERE pick_list_id = $1 AND order_id = $2`, id, order.OrderID); err != nil {
+			return fmt.Errorf("failed to clear parcels for order %d: %v", order.OrderID, err)
+		}
+		for _, parcel := range order.Parcels {
+			if err := insertPickListParcel(tx, id, order.OrderID, parcel); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE pick_lists SET status = $2 WHERE id = $1`, id, models.PickListPacked); err != nil {
+		return fmt.Errorf("failed to update pick list %d: %v", id, err)
+	}
+	return tx.Commit()
+}
+
+// checkPackedDestinations makes sure every parcel of a split order names one
+// of its destinations, and that no destination is packed more of a line than
+// is allocated there and not yet shipped. Parcels of an order that isn't
+// split must not name a destination.
+func (s *PostgresStorage) checkPackedDestinations(tx *sql.Tx, order models.PackOrder) error {
+	packed := make(map[int]map[int]int)
+	var destinations []*int
+	for _, parcel := range order.Parcels {
+		destination := 0
+		if parcel.DestinationID != nil {
+			destination = *parcel.DestinationID
+		}
+		if packed[destination] == nil {
+			packed[destination] = make(map[int]int)
+			destinations = append(destinations, parcel.DestinationID)
+		}
+		for _, item := range parcel.Items {
+			packed[destination][item.ID] += item.Quantity
+		}
+	}
+
+	for _, destinationID := range destinations {
+		allocations, err := destinationAllocations(tx, order.OrderID, destinationID)
+		if err != nil {
+			return err
+		}
+		if allocations == nil {
+			continue
+		}
+		shipped, err := s.shippedQuantities(tx, order.OrderID, destinationID)
+		if err != nil {
+			return err
+		}
+		for itemID, quantity := range packed[*destinationID] {
+			allocated, ok := allocations[itemID]
+			if !ok {
+				return fmt.Errorf("item %d is not allocated to destination %d: %w", itemID, *destinationID, ErrValidation)
+			}
+			if due := allocated - shipped[itemID]; quantity > due {
+				return fmt.Errorf("packed quantity for item %d exceeds the %d due at destination %d: %w", itemID, max(due, 0), *destinationID, ErrValidation)
+			}
+		}
+	}
+	return nil
+}
+
+func pickedQuantities(tx *sql.Tx, listID, orderID int) (map[int]int, error) {
+	rows, err := tx.Query(`SELECT order_item_id, quantity FROM pick_list_items WHERE pick_list_id = $1 AND order_id = $2`, listID, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch picked items: %v", err)
+	}
+	defer rows.Close()
+
+	picked := make(map[int]int)
+	for rows.Next() {
+		var itemID, quantity int
+		if err := rows.Scan(&itemID, &quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan picked item: %v", err)
+		}
+		picked[itemID] = quantity
+	}
+	return picked, rows.Err()
+}
+
+func insertPickListParcel(tx *sql.Tx, listID, orderID int, parcel models.PackParcel) error {
+	var parcelID int
+	err := tx.QueryRow(`
+		INSERT INTO pick_list_parcels (pick_list_id, order_id, destination_id, weight_kg, length_cm, width_cm, height_cm)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, listID, orderID, parcel.DestinationID, parcel.WeightKg, parcel.LengthCm, parcel.WidthCm, parcel.HeightCm).Scan(&parcelID)
+	if err != nil {
+		return fmt.Errorf("failed to insert parcel: %v", err)
+	}
+
+	var itemIDs, quantities []int64
+	for _, item := range parcel.Items {
+		itemIDs = append(itemIDs, int64(item.ID))
+		quantities = append(quantities, int64(item.Quantity))
+	}
+	_, err = tx.Exec(`
+		INSERT INTO pick_list_parcel_items (parcel_id, order_item_id, quantity)
+		SELECT $1, i.item_id, i.quantity
+		FROM UNNEST($2::int[], $3::int[]) AS i(item_id, quantity)
+	`, parcelID, pq.Array(itemIDs), pq.Array(quantities))
+	if err != nil {
+		return classify(err)
+	}
+	return nil
+}
+
+// ShipPickListOrder creates the shipments for a packed order on a pick
+// list, one per destination its parcels go to, all or none of them. The
+// list is shipped once every order packed on it has.
+func (s *PostgresStorage) ShipPickListOrder(id, orderID int, shipments []models.Shipment) ([]int, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	status, err := lockPickList(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if status != models.PickListPacked {
+		return nil, fmt.Errorf("pick list %d is %s, not packed: %w", id, status, ErrConflict)
+	}
+
+	var existing sql.NullInt64
+	err = tx.QueryRow(`SELECT shipment_id FROM pick_list_orders WHERE pick_list_id = $1 AND order_id = $2`, id, orderID).Scan(&existing)
+	if err != nil {
+		return nil, notFound(err, "order %d on pick list %d", orderID, id)
+	}
+	if existing.Valid {
+		return nil, fmt.Errorf("order %d has already shipped as shipment %d: %w", orderID, existing.Int64, ErrConflict)
+	}
+	if len(shipments) == 0 {
+		return nil, fmt.Errorf("order %d has nothing packed on pick list %d: %w", orderID, id, ErrValidation)
+	}
+
+	var shipmentIDs []int
+	for _, shipment := range shipments {
+		shipmentID, err := s.createShipment(tx, shipment)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(`
+			UPDATE pick_list_parcels SET shipment_id = $4
+			WHERE pick_list_id = $1 AND order_id = $2 AND destination_id IS NOT DISTINCT FROM $3
+		`, id, orderID, shipment.DestinationID, shipmentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to link shipment to parcels: %v", err)
+		}
+		shipmentIDs = append(shipmentIDs, shipmentID)
+	}
+
+	_, err = tx.Exec(`UPDATE pick_list_orders SET shipment_id = $3 WHERE pick_list_id = $1 AND order_id = $2`, id, orderID, shipmentIDs[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to link shipment to pick list: %v", err)
+	}
+	_, err = tx.Exec(`
+		UPDATE pick_lists SET status = $2
+		WHERE id = $1 AND NOT EXISTS (
+			SELECT 1
+			FROM pick_list_orders plo
+			WHERE plo.pick_list_id = $1 AND plo.shipment_id IS NULL
+				AND EXISTS (SELECT 1 FROM pick_list_parcels pp WHERE pp.pick_list_id = $1 AND pp.order_id = plo.order_id)
+		)
+	`, id, models.PickListShipped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update pick list %d: %v", id, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit shipment: %v", err)
+	}
+	return shipmentIDs, nil
+}
+
+// CancelPickList releases a pick list's orders that haven't shipped
+func (s *PostgresStorage) CancelPickList(id int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	status, err := lockPickList(tx, id)
+	if err != nil {
+		return err
+	}
+	if status != models.PickListOpen && status != models.PickListPacked {
+		return fmt.Errorf("pick list %d is %s: %w", id, status, ErrConflict)
+	}
+	if _, err := tx.Exec(`UPDATE pick_lists SET status = $2 WHERE id = $1`, id, models.PickListCancelled); err != nil {
+		return fmt.Errorf("failed to cancel pick list %d: %v", id, err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"fmt"
+	"strings"
+)
+
+const productColumns = `id, name, description, hs_code, origin_country, net_weight_kg, gross_weight_kg`
+
+func scanProduct(row rowScanner) (models.Product, error) {
+	var p models.Product
+	err := row.Scan(&p.ID, &p.Name, &p.Description, &p.HSCode, &p.OriginCountry, &p.NetWeightKg, &p.GrossWeightKg)
+	return p, err
+}
+
+// productArgs normalizes a product for storing: HS codes without dots and
+// upper case country codes
+func productArgs(p models.Product) []any {
+	return []any{strings.TrimSpace(p.Name), p.Description, strings.ReplaceAll(strings.TrimSpace(p.HSCode), ".", ""),
+		strings.ToUpper(strings.TrimSpace(p.OriginCountry)), p.NetWeightKg, p.GrossWeightKg}
+}
+
+func (s *PostgresStorage) GetProducts() ([]models.Product, error) {
+	rows, err := s.DB.Query(`SELECT ` + productColumns + ` FROM products ORDER BY LOWER(name)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %v", err)
+	}
+	defer rows.Close()
+
+	products := []models.Product{}
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan product: %v", err)
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+func (s *PostgresStorage) GetProduct(id int) (models.Product, error) {
+	p, err := scanProduct(s.DB.QueryRow(`SELECT `+productColumns+` FROM products WHERE id = $1`, id))
+	if err != nil {
+		return models.Product{}, notFound(err, "product %d", id)
+	}
+	return p, nil
+}
+
+// CreateProduct adds a product; names are unique ignoring case
+func (s *PostgresStorage) CreateProduct(p models.Product) (int, error) {
+	var id int
+	err := s.DB.QueryRow(`
+		INSERT INTO products (name, description, hs_code, origin_country, net_weight_kg, gross_weight_kg)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, productArgs(p)...).Scan(&id)
+	if err != nil {
+		return 0, classify(err)
+	}
+	return id, nil
+}
+
+func (s *PostgresStorage) UpdateProduct(p models.Product) error {
+	res, err := s.DB.Exec(`
+		UPDATE products
+		SET name = $1, description = $2, hs_code = $3, origin_country = $4, net_weight_kg = $5, gross_weight_kg = $6
+		WHERE id = $7
+	`, append(productArgs(p), p.ID)...)
+	if err != nil {
+		return classify(err)
+	}
+	return requireRow(res, "product %d", p.ID)
+}
+
+func (s *PostgresStorage) DeleteProduct(id int) error {
+	res, err := s.DB.Exec(`DELETE FROM products WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return requireRow(res, "product %d", id)
+}
+
+// GetCustomsLines lists what a shipment contained with the customs details
+// of the product matching each item's name
+func (s *PostgresStorage) GetCustomsLines(shipmentID int) ([]models.CustomsLine, error) {
+	rows, err := s.DB.Query(`
+		SELECT oi.id, COALESCE(oi.name, ''), oi.size, oi.color, si.quantity, si.price,
+			p.id, COALESCE(p.description, ''), COALESCE(p.hs_code, ''), COALESCE(p.origin_country, ''),
+			COALESCE(p.net_weight_kg, 0), COALESCE(p.gross_weight_kg, 0)
+		FROM shipment_items si
+		JOIN order_items oi ON oi.id = si.order_item_id
+		LEFT JOIN products p ON LOWER(p.name) = LOWER(TRIM(oi.name))
+		WHERE si.shipment_id = $1
+		ORDER BY oi.id
+	`, shipmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch customs lines for shipment %d: %v", shipmentID, err)
+	}
+	defer rows.Close()
+
+	lines := []models.CustomsLine{}
+	for rows.Next() {
+		var l models.CustomsLine
+		err := rows.Scan(&l.OrderItemID, &l.Name, &l.Size, &l.Color, &l.Quantity, &l.UnitValue,
+			&l.ProductID, &l.Description, &l.HSCode, &l.OriginCountry, &l.NetWeightKg, &l.GrossWeightKg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan customs line: %v", err)
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
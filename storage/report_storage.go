@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"fmt"
+)
+
+// salesLines is the CTE shared by the sales report queries: ordered lines
+// dated by order date and shipped lines dated by their shipment, both
+// limited to the $1..$2 date range.
+const salesLines = `
+	ordered_lines AS (
+		SELECT o.id AS order_id, o.customer_id, o.order_date AS day, oi.name AS item,
+			COALESCE(oi.quantity, 0) AS quantity, COALESCE(oi.price * oi.quantity, 0) AS value
+		FROM orders o
+		LEFT JOIN order_items oi ON oi.order_id = o.id
+		WHERE ($1 = '' OR o.order_date >= NULLIF($1, '')::date)
+			AND ($2 = '' OR o.order_date <= NULLIF($2, '')::date)
+	), shipped_dated AS (
+		SELECT s.order_id, o.customer_id, s.shipped_date AS day, oi.name AS item, si.quantity, si.quantity * si.price AS value
+		FROM shipment_items si
+		JOIN shipments s ON s.id = si.shipment_id
+		JOIN orders o ON o.id = s.order_id
+		JOIN order_items oi ON oi.id = si.order_item_id
+		WHERE ($1 = '' OR s.shipped_date >= NULLIF($1, '')::date)
+			AND ($2 = '' OR s.shipped_date <= NULLIF($2, '')::date)
+	)`
+
+// salesGroups maps group_by values to the label each series point is
+// grouped on. Queries only ever interpolate these fixed expressions.
+var salesGroups = map[string]string{
+	"":                     "''",
+	models.GroupByCustomer: "COALESCE(c.name, '')",
+	models.GroupByCountry:  "COALESCE(c.country, '')",
+	models.GroupByProduct:  "COALESCE(l.item, '')",
+}
+
+// GetSalesReport builds the sales series and top customers and items for
+// the filter. Granularity and GroupBy must already be validated.
+func (s *PostgresStorage) GetSalesReport(filter models.SalesFilter) (models.SalesReport, error) {
+	report := models.SalesReport{
+		From:         filter.From,
+		To:           filter.To,
+		Granularity:  filter.Granularity,
+		GroupBy:      filter.GroupBy,
+		Series:       []models.SalesPoint{},
+		TopCustomers: []models.TopCustomer{},
+		TopItems:     []models.TopItem{},
+	}
+
+	group, ok := salesGroups[filter.GroupBy]
+	if !ok {
+		return report, fmt.Errorf("unknown group_by %q: %w", filter.GroupBy, ErrValidation)
+	}
+
+	// Every period from From to To is listed, with zeros where nothing
+	// happened, for each group seen in the range. Open-ended ranges run from
+	// the first to the last day with activity, so the span is only known once
+	// the bounds are worked out here.
+	var periods int
+	err := s.DB.QueryRow(`
+		WITH `+salesLines+`, lines AS (
+			SELECT day FROM ordered_lines
+			UNION ALL
+			SELECT day FROM shipped_dated
+		), bounds AS (
+			SELECT DATE_TRUNC($3, COALESCE(NULLIF($1, '')::date, MIN(day))::timestamp) AS first_period,
+				DATE_TRUNC($3, COALESCE(NULLIF($2, '')::date, MAX(day))::timestamp) AS last_period
+			FROM lines
+		)
+		SELECT COUNT(*) FROM (
+			SELECT generate_series(first_period, last_period, ('1 ' || $3)::interval) FROM bounds LIMIT $4
+		) p
+	`, filter.From, filter.To, filter.Granularity, models.MaxSalesPeriods+1).Scan(&periods)
+	if err != nil {
+		return report, fmt.Errorf("failed to count sales periods: %v", err)
+	}
+	if periods > models.MaxSalesPeriods {
+		return report, fmt.Errorf("the report would list more than %d %s periods, narrow from and to or use a coarser granularity: %w",
+			models.MaxSalesPeriods, filter.Granularity, ErrValidation)
+	}
+
+	rows, err := s.DB.Query(`
+		WITH `+salesLines+`, lines AS (
+			SELECT order_id, customer_id, day, item, value AS ordered, 0 AS shipped FROM ordered_lines
+			UNION ALL
+			SELECT NULL, customer_id, day, item, 0, value FROM shipped_dated
+		), agg AS (
+			SELECT DATE_TRUNC($3, l.day::timestamp) AS period, `+group+` AS grp,
+				COUNT(DISTINCT l.order_id) AS order_count, COALESCE(SUM(l.ordered), 0) AS ordered,
+				COALESCE(SUM(l.shipped), 0) AS shipped
+			FROM lines l
+			LEFT JOIN customers c ON c.id = l.customer_id
+			GROUP BY 1, 2
+		), bounds AS (
+			SELECT DATE_TRUNC($3, COALESCE(NULLIF($1, '')::date, MIN(day))::timestamp) AS first_period,
+				DATE_TRUNC($3, COALESCE(NULLIF($2, '')::date, MAX(day))::timestamp) AS last_period
+			FROM lines
+		), periods AS (
+			SELECT generate_series(first_period, last_period, ('1 ' || $3)::interval) AS period
+			FROM bounds
+		), groups AS (
+			SELECT DISTINCT grp FROM agg
+			UNION
+			SELECT '' WHERE NOT EXISTS (SELECT 1 FROM agg)
+		)
+		SELECT TO_CHAR(p.period, 'YYYY-MM-DD'), g.grp,
+			COALESCE(a.order_count, 0), COALESCE(a.ordered, 0), COALESCE(a.shipped, 0)
+		FROM periods p
+		CROSS JOIN groups g
+		LEFT JOIN agg a ON a.period = p.period AND a.grp = g.grp
+		ORDER BY 1, 2
+	`, filter.From, filter.To, filter.Granularity)
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch sales series: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.SalesPoint
+		if err := rows.Scan(&p.Period, &p.Group, &p.OrderCount, &p.OrderedValue, &p.ShippedValue); err != nil {
+			return report, fmt.Errorf("failed to scan sales series: %v", err)
+		}
+		report.Series = append(report.Series, p)
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	rows, err = s.DB.Query(`
+		WITH `+salesLines+`, lines AS (
+			SELECT order_id, customer_id, value AS ordered, 0 AS shipped FROM ordered_lines
+			UNION ALL
+			SELECT NULL, customer_id, 0, value FROM shipped_dated
+		)
+		SELECT c.id, c.name, COUNT(DISTINCT l.order_id), COALESCE(SUM(l.ordered), 0), COALESCE(SUM(l.shipped), 0)
+		FROM lines l
+		JOIN customers c ON c.id = l.customer_id
+		GROUP BY c.id, c.name
+		ORDER BY 4 DESC, 5 DESC, c.name
+		LIMIT $3
+	`, filter.From, filter.To, filter.Top)
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch top customers: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.TopCustomer
+		if err := rows.Scan(&t.CustomerID, &t.CustomerName, &t.OrderCount, &t.OrderedValue, &t.ShippedValue); err != nil {
+			return report, fmt.Errorf("failed to scan top customers: %v", err)
+		}
+		report.TopCustomers = append(report.TopCustomers, t)
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	rows, err = s.DB.Query(`
+		WITH `+salesLines+`, lines AS (
+			SELECT item, quantity AS ordered_qty, value AS ordered, 0 AS shipped_qty, 0 AS shipped
+			FROM ordered_lines WHERE quantity > 0
+			UNION ALL
+			SELECT item, 0, 0, quantity, value FROM shipped_dated
+		)
+		SELECT COALESCE(item, '') AS name, SUM(ordered_qty), SUM(ordered), SUM(shipped_qty), SUM(shipped)
+		FROM lines
+		GROUP BY 1
+		ORDER BY 3 DESC, 5 DESC, 1
+		LIMIT $3
+	`, filter.From, filter.To, filter.Top)
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch top items: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.TopItem
+		if err := rows.Scan(&t.Name, &t.OrderedQuantity, &t.OrderedValue, &t.ShippedQuantity, &t.ShippedValue); err != nil {
+			return report, fmt.Errorf("failed to scan top items: %v", err)
+		}
+		report.TopItems = append(report.TopItems, t)
+	}
+	return report, rows.Err()
+}
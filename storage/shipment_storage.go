@@ -1,136 +1,288 @@
 package storage
 
 import (
+	"AAHAOMS/OMS/carriers"
 	"AAHAOMS/OMS/models"
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
 )
 
-// Handle a new shipment transactionally
-func (s *PostgresStorage) HandleShipment(shipment models.Shipment) error {
-	tx, err := s.DB.Begin()
-	if err != nil {
-		log.Printf("Error starting transaction: %v", err)
-		return fmt.Errorf("failed to start transaction: %v", err)
+// shipmentColumns are read by scanShipment; items and packages are loaded
+// separately by loadShipmentDetails
+const shipmentColumns = `s.id, s.order_id, s.shipped_date::DATE, s.due_order_type,
+	s.carrier, s.service_level, s.shipping_cost, s.status, s.delivered_date, s.destination_id`
+
+func scanShipment(row rowScanner, shipment *models.Shipment) error {
+	var delivered sql.NullString
+	var destination sql.NullInt64
+	err := row.Scan(&shipment.ID, &shipment.OrderID, &shipment.ShippedDate, &shipment.DueOrderType,
+		&shipment.Carrier, &shipment.ServiceLevel, &shipment.ShippingCost, &shipment.Status, &delivered, &destination)
+	if delivered.Valid {
+		shipment.DeliveredDate = &delivered.String
 	}
-	defer tx.Rollback()
+	if destination.Valid {
+		id := int(destination.Int64)
+		shipment.DestinationID = &id
+	}
+	return err
+}
 
-	// Validate order existence and get status
-	orderStatus, err := s.getOrderStatus(tx, shipment.OrderID)
+// loadShipmentDetails fills in a shipment's items and packages
+func (s *PostgresStorage) loadShipmentDetails(shipment *models.Shipment) error {
+	items, err := s.getItemDetails(shipment.ID)
 	if err != nil {
 		return err
 	}
+	shipment.Items = items
 
-	// Fetch order items
-	orderItems, err := s.getOrderItems(tx, shipment.OrderID)
+	shipment.Packages, err = s.getShipmentPackages(shipment.ID, shipment.Carrier)
+	return err
+}
+
+func (s *PostgresStorage) getShipmentPackages(shipmentID int, carrier string) ([]models.ShipmentPackage, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, tracking_number, weight_kg, length_cm, width_cm, height_cm
+		FROM shipment_packages
+		WHERE shipment_id = $1
+		ORDER BY id
+	`, shipmentID)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to fetch packages for shipment %d: %v", shipmentID, err)
 	}
+	defer rows.Close()
 
-	// Handle due order shipment
-	if shipment.DueOrderType {
-		err := s.handleDueOrderShipment(tx, shipment, orderStatus)
-		if err != nil {
-			return err
-		}
-		orderStatus = "shipped"
-	} else {
-		orderStatus, err = s.handlePartialShipment(tx, shipment, orderItems)
-		if err != nil {
-			return err
+	var packages []models.ShipmentPackage
+	for rows.Next() {
+		var p models.ShipmentPackage
+		if err := rows.Scan(&p.ID, &p.TrackingNumber, &p.WeightKg, &p.LengthCm, &p.WidthCm, &p.HeightCm); err != nil {
+			return nil, fmt.Errorf("failed to scan shipment package: %v", err)
 		}
+		p.TrackingURL = carriers.TrackingURL(carrier, p.TrackingNumber)
+		packages = append(packages, p)
 	}
+	return packages, rows.Err()
+}
 
-	// Update order status
-	if err := s.updateOrderStatus(tx, shipment.OrderID, orderStatus); err != nil {
-		return err
+// Handle a new shipment transactionally and return the new shipment's ID.
+// An order can ship in any number of shipments; each one may only ship what
+// is still outstanding, and whatever it leaves out stays due.
+func (s *PostgresStorage) HandleShipment(shipment models.Shipment) (int, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
 	}
+	defer tx.Rollback()
 
-	// Insert shipment record
-	if err := s.insertShipment(tx, shipment); err != nil {
-		return err
+	shipmentID, err := s.createShipment(tx, shipment)
+	if err != nil {
+		return 0, err
 	}
 
 	// Commit transaction
 	if err = tx.Commit(); err != nil {
 		log.Printf("Error committing transaction: %v", err)
-		return fmt.Errorf("failed to commit transaction: %v", err)
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
 	log.Printf("Shipment processed successfully for order ID %d", shipment.OrderID)
-	return nil
+	return shipmentID, nil
 }
 
-// Handle due order shipment
-func (s *PostgresStorage) handleDueOrderShipment(tx *sql.Tx, shipment models.Shipment, orderStatus string) error {
-	if orderStatus != "shipped and due" {
-		log.Printf("Order status mismatch: expected 'shipped and due', got '%s'", orderStatus)
-		return fmt.Errorf("order is not in 'shipped and due' status")
+// createShipment stores a shipment within tx, updates what its order still
+// owes and queues the follow-up jobs
+func (s *PostgresStorage) createShipment(tx *sql.Tx, shipment models.Shipment) (int, error) {
+	// Validate order existence and lock it against concurrent shipments
+	previousStatus, err := s.getOrderStatus(tx, shipment.OrderID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Fetch order items
+	orderItems, err := s.getOrderItems(tx, shipment.OrderID)
+	if err != nil {
+		return 0, err
 	}
 
-	rows, err := tx.Query(`SELECT item_id, quantity FROM due_orders WHERE order_id = $1`, shipment.OrderID)
+	shipped, err := s.shippedQuantities(tx, shipment.OrderID, nil)
 	if err != nil {
-		return fmt.Errorf("failed to fetch due items: %v", err)
+		return 0, err
+	}
+
+	// A split order ships each destination's allocation separately
+	allocations, err := destinationAllocations(tx, shipment.OrderID, shipment.DestinationID)
+	if err != nil {
+		return 0, err
+	}
+	if allocations == nil {
+		err = checkOutstanding(shipment, orderItems, shipped)
+	} else {
+		err = s.checkAllocated(tx, shipment, allocations)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// A shipment following an earlier one is shipping items that were due
+	shipment.DueOrderType = len(shipped) > 0
+
+	// Insert shipment record
+	shipmentID, err := s.insertShipment(tx, shipment, orderItems)
+	if err != nil {
+		return 0, err
+	}
+
+	status, err := s.refreshOrderFulfillment(tx, shipment.OrderID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Queue follow-up work in the outbox so it only happens if we commit
+	payload := models.ShipmentJobPayload{
+		ShipmentID:          shipmentID,
+		OrderID:             shipment.OrderID,
+		ShippedDate:         shipment.ShippedDate,
+		Items:               shipment.Items,
+		PreviousOrderStatus: previousStatus,
+		OrderStatus:         status,
+	}
+	if _, err := enqueueJob(tx, models.JobShipmentCreated, payload, ""); err != nil {
+		return 0, err
+	}
+	return shipmentID, nil
+}
+
+// shippedQuantities totals what has shipped so far of each line of an
+// order, or only to one destination when destinationID is set
+func (s *PostgresStorage) shippedQuantities(tx *sql.Tx, orderID int, destinationID *int) (map[int]int, error) {
+	rows, err := tx.Query(`
+		SELECT si.order_item_id, SUM(si.quantity)
+		FROM shipment_items si
+		JOIN shipments s ON s.id = si.shipment_id
+		WHERE s.order_id = $1 AND ($2::int IS NULL OR s.destination_id = $2)
+		GROUP BY si.order_item_id
+	`, orderID, destinationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch shipped quantities: %v", err)
 	}
 	defer rows.Close()
 
-	dueItems := make(map[int]int)
+	shipped := make(map[int]int)
 	for rows.Next() {
 		var itemID, quantity int
 		if err := rows.Scan(&itemID, &quantity); err != nil {
-			return fmt.Errorf("failed to scan due item: %v", err)
+			return nil, fmt.Errorf("failed to scan shipped quantity: %v", err)
 		}
-		dueItems[itemID] = quantity
+		shipped[itemID] = quantity
 	}
+	return shipped, rows.Err()
+}
 
+// checkOutstanding rejects shipping anything not on the order or more of a
+// line than is still outstanding
+func checkOutstanding(shipment models.Shipment, orderItems map[int]models.Item, shipped map[int]int) error {
 	for _, shippedItem := range shipment.Items {
-		if dueQuantity, exists := dueItems[shippedItem.ID]; !exists || shippedItem.Quantity != dueQuantity {
-			return fmt.Errorf("shipment item %d does not match due quantity", shippedItem.ID)
+		orderItem, exists := orderItems[shippedItem.ID]
+		if !exists {
+			return fmt.Errorf("item ID %d does not exist in the order: %w", shippedItem.ID, ErrValidation)
+		}
+		outstanding := orderItem.Quantity - shipped[shippedItem.ID]
+		if outstanding <= 0 {
+			return fmt.Errorf("item %d has already shipped in full: %w", shippedItem.ID, ErrConflict)
+		}
+		if shippedItem.Quantity > outstanding {
+			return fmt.Errorf("shipped quantity for item %d exceeds the %d outstanding: %w", shippedItem.ID, outstanding, ErrValidation)
 		}
-	}
-
-	_, err = tx.Exec(`DELETE FROM due_orders WHERE order_id = $1`, shipment.OrderID)
-	if err != nil {
-		return fmt.Errorf("failed to clear due orders: %v", err)
 	}
 	return nil
 }
 
-// Handle partial shipment
-func (s *PostgresStorage) handlePartialShipment(tx *sql.Tx, shipment models.Shipment, orderItems map[int]models.Item) (string, error) {
-	hasDueItems := false
-
+// checkAllocated rejects shipping anything not allocated to the shipment's
+// destination or more of a line than is still outstanding there
+func (s *PostgresStorage) checkAllocated(tx *sql.Tx, shipment models.Shipment, allocations map[int]int) error {
+	shipped, err := s.shippedQuantities(tx, shipment.OrderID, shipment.DestinationID)
+	if err != nil {
+		return err
+	}
 	for _, shippedItem := range shipment.Items {
-		orderItem, exists := orderItems[shippedItem.ID]
+		allocated, exists := allocations[shippedItem.ID]
 		if !exists {
-			return "", fmt.Errorf("item ID %d does not exist in the order", shippedItem.ID)
+			return fmt.Errorf("item ID %d is not allocated to destination %d: %w", shippedItem.ID, *shipment.DestinationID, ErrValidation)
 		}
-		if shippedItem.Quantity > orderItem.Quantity {
-			return "", fmt.Errorf("shipped quantity for item %d exceeds order quantity", shippedItem.ID)
+		outstanding := allocated - shipped[shippedItem.ID]
+		if outstanding <= 0 {
+			return fmt.Errorf("item %d has already shipped in full to destination %d: %w", shippedItem.ID, *shipment.DestinationID, ErrConflict)
 		}
-
-		dueQuantity := orderItem.Quantity - shippedItem.Quantity
-		if dueQuantity > 0 {
-			hasDueItems = true
-			_, err := tx.Exec(`
-				INSERT INTO due_orders (order_id, item_id, quantity)
-				VALUES ($1, $2, $3)
-				ON CONFLICT (order_id, item_id) DO UPDATE SET quantity = EXCLUDED.quantity
-			`, shipment.OrderID, orderItem.ID, dueQuantity)
-			if err != nil {
-				return "", fmt.Errorf("failed to update due orders: %v", err)
-			}
+		if shippedItem.Quantity > outstanding {
+			return fmt.Errorf("shipped quantity for item %d exceeds the %d outstanding for destination %d: %w", shippedItem.ID, outstanding, *shipment.DestinationID, ErrValidation)
 		}
 	}
+	return nil
+}
 
-	if hasDueItems {
-		return "shipped and due", nil
+// refreshOrderFulfillment rebuilds an order's due_orders as ordered less
+// shipped for every line, or allocated less shipped there for every
+// destination of a split order, and sets its status to match: pending before
+// anything ships, shipped once nothing is outstanding, and shipped and due
+// in between. A shipped order whose shipments have all been delivered is
+// delivered. Until something ships an order has no due_orders, the same as
+// when it was created. It returns the new status.
+func (s *PostgresStorage) refreshOrderFulfillment(tx *sql.Tx, orderID int) (string, error) {
+	if _, err := tx.Exec(`DELETE FROM due_orders WHERE order_id = $1`, orderID); err != nil {
+		return "", fmt.Errorf("failed to clear due orders: %v", err)
 	}
-	return "shipped", nil
+
+	var shipments, undelivered, outstanding int
+	err := tx.QueryRow(`
+		WITH lines AS (
+			SELECT oi.id AS item_id, NULL::int AS destination_id, oi.quantity
+			FROM order_items oi
+			WHERE oi.order_id = $1
+				AND NOT EXISTS (SELECT 1 FROM order_destinations WHERE order_id = $1)
+			UNION ALL
+			SELECT a.order_item_id, a.destination_id, a.quantity
+			FROM order_allocations a
+			JOIN order_destinations od ON od.id = a.destination_id
+			WHERE od.order_id = $1
+		), due AS (
+			INSERT INTO due_orders (order_id, item_id, destination_id, quantity)
+			SELECT $1, l.item_id, l.destination_id, l.quantity - COALESCE(SUM(si.quantity), 0)
+			FROM lines l
+			LEFT JOIN (shipment_items si JOIN shipments sh ON sh.id = si.shipment_id)
+				ON si.order_item_id = l.item_id AND sh.destination_id IS NOT DISTINCT FROM l.destination_id
+			WHERE EXISTS (SELECT 1 FROM shipments WHERE order_id = $1)
+			GROUP BY l.item_id, l.destination_id, l.quantity
+			HAVING l.quantity - COALESCE(SUM(si.quantity), 0) > 0
+			RETURNING quantity
+		)
+		SELECT
+			(SELECT COUNT(*) FROM shipments WHERE order_id = $1),
+			(SELECT COUNT(*) FROM shipments WHERE order_id = $1 AND status <> $2),
+			(SELECT COUNT(*) FROM due)
+	`, orderID, models.ShipmentDelivered).Scan(&shipments, &undelivered, &outstanding)
+	if err != nil {
+		return "", fmt.Errorf("failed to update due orders: %v", err)
+	}
+
+	status := models.OrderShippedAndDue
+	switch {
+	case shipments == 0:
+		status = models.OrderPending
+	case outstanding == 0 && undelivered == 0:
+		status = models.OrderDelivered
+	case outstanding == 0:
+		status = models.OrderShipped
+	}
+
+	if err := s.updateOrderStatus(tx, orderID, status); err != nil {
+		return "", fmt.Errorf("failed to update order status: %v", err)
+	}
+	return status, nil
 }
 
 // Update order status
@@ -139,8 +291,10 @@ func (s *PostgresStorage) updateOrderStatus(tx *sql.Tx, orderID int, status stri
 	return err
 }
 
-// Insert shipment into the database
-func (s *PostgresStorage) insertShipment(tx *sql.Tx, shipment models.Shipment) error {
+// Insert shipment into the database along with the quantity shipped of each
+// line at its current price. The items array is still filled in for older
+// readers of the table.
+func (s *PostgresStorage) insertShipment(tx *sql.Tx, shipment models.Shipment, orderItems map[int]models.Item) (int, error) {
 	var itemIDs []int
 	for _, item := range shipment.Items {
 		itemIDs = append(itemIDs, item.ID)
@@ -148,21 +302,47 @@ func (s *PostgresStorage) insertShipment(tx *sql.Tx, shipment models.Shipment) e
 
 	shippedDate, err := time.Parse("2006-01-02", shipment.ShippedDate)
 	if err != nil {
-		return fmt.Errorf("invalid date format: %v", err)
+		return 0, fmt.Errorf("invalid date format: %v: %w", err, ErrValidation)
+	}
+
+	var shipmentID int
+	err = tx.QueryRow(`
+		INSERT INTO shipments (order_id, shipped_date, items, due_order_type, carrier, service_level, shipping_cost, destination_id)
+		VALUES ($1, $2, $3::int[], $4, $5, $6, $7, $8)
+		RETURNING id
+	`, shipment.OrderID, shippedDate, pq.Array(itemIDs), shipment.DueOrderType,
+		strings.TrimSpace(shipment.Carrier), strings.TrimSpace(shipment.ServiceLevel), shipment.ShippingCost,
+		shipment.DestinationID).Scan(&shipmentID)
+	if err != nil {
+		return 0, err
 	}
 
-	_, err = tx.Exec(`
-		INSERT INTO shipments (order_id, shipped_date, items, due_order_type)
-		VALUES ($1, $2, $3::int[], $4)
-	`, shipment.OrderID, shippedDate, pq.Array(itemIDs), shipment.DueOrderType)
+	for _, p := range shipment.Packages {
+		_, err := tx.Exec(`
+			INSERT INTO shipment_packages (shipment_id, tracking_number, weight_kg, length_cm, width_cm, height_cm)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, shipmentID, strings.TrimSpace(p.TrackingNumber), p.WeightKg, p.LengthCm, p.WidthCm, p.HeightCm)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert shipment package: %v", err)
+		}
+	}
 
-	return err
+	for _, item := range shipment.Items {
+		_, err := tx.Exec(`
+			INSERT INTO shipment_items (shipment_id, order_item_id, quantity, price)
+			VALUES ($1, $2, $3, $4)
+		`, shipmentID, item.ID, item.Quantity, orderItems[item.ID].Price)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert shipment item %d: %v", item.ID, err)
+		}
+	}
+	return shipmentID, nil
 }
 
 // Retrieve all shipments
 func (s *PostgresStorage) GetAllShipments() ([]models.Shipment, error) {
 	rows, err := s.DB.Query(`
-		SELECT s.id, s.order_id, s.shipped_date::DATE, s.items::int[], s.due_order_type
+		SELECT ` + shipmentColumns + `
 		FROM shipments s
 	`)
 	if err != nil {
@@ -181,7 +361,7 @@ func (s *PostgresStorage) GetShipmentByName(customerName string) ([]models.Shipm
 		SELECT o.id 
 		FROM orders o
 		INNER JOIN customers c ON o.customer_id = c.id
-		WHERE c.name = $1
+		WHERE LOWER(c.name) = LOWER(TRIM($1))
 	`
 
 	rows, err := s.DB.Query(orderQuery, customerName)
@@ -205,7 +385,7 @@ func (s *PostgresStorage) GetShipmentByName(customerName string) ([]models.Shipm
 
 	// Fetch shipments for the retrieved order IDs
 	shipmentsQuery := `
-		SELECT s.id, s.order_id, s.shipped_date::DATE, s.items::int[], s.due_order_type
+		SELECT ` + shipmentColumns + `
 		FROM shipments s
 		WHERE s.order_id = ANY($1)
 	`
@@ -219,70 +399,88 @@ func (s *PostgresStorage) GetShipmentByName(customerName string) ([]models.Shipm
 	return s.parseShipments(rows)
 }
 
+// GetShipmentsByCustomerID returns every shipment against a customer's orders
+func (s *PostgresStorage) GetShipmentsByCustomerID(customerID int) ([]models.Shipment, error) {
+	rows, err := s.DB.Query(`
+		SELECT `+shipmentColumns+`
+		FROM shipments s
+		INNER JOIN orders o ON s.order_id = o.id
+		WHERE o.customer_id = $1
+		ORDER BY s.shipped_date DESC, s.id DESC
+	`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch shipments for customer %d: %v", customerID, err)
+	}
+	defer rows.Close()
+
+	shipments, err := s.parseShipments(rows)
+	if shipments == nil && err == nil {
+		shipments = []models.Shipment{}
+	}
+	return shipments, err
+}
+
 // Parse shipments from SQL rows
 func (s *PostgresStorage) parseShipments(rows *sql.Rows) ([]models.Shipment, error) {
 	var shipments []models.Shipment
 
 	for rows.Next() {
 		var shipment models.Shipment
-		var shippedDate string
-		var itemIDs pq.Int64Array
-
-		err := rows.Scan(&shipment.ID, &shipment.OrderID, &shippedDate, &itemIDs, &shipment.DueOrderType)
-		if err != nil {
+		if err := scanShipment(rows, &shipment); err != nil {
 			return nil, fmt.Errorf("failed to scan shipment row: %v", err)
 		}
+		shipments = append(shipments, shipment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		shipment.ShippedDate = shippedDate
-
-		// Fetch full item details
-		shipment.Items, err = s.getItemDetails(itemIDs)
-		if err != nil {
+	// Fetch items and packages once the rows are closed
+	rows.Close()
+	for i := range shipments {
+		if err := s.loadShipmentDetails(&shipments[i]); err != nil {
 			return nil, err
 		}
-
-		shipments = append(shipments, shipment)
 	}
 
 	return shipments, nil
 }
 
-// Fetch full item details
-func (s *PostgresStorage) getItemDetails(itemIDs pq.Int64Array) ([]models.Item, error) {
-	if len(itemIDs) == 0 {
-		return []models.Item{}, nil
-	}
-
-	query := `
-		SELECT id, name, price, quantity 
-		FROM order_items 
-		WHERE id = ANY($1)
-	`
-	rows, err := s.DB.Query(query, pq.Array(itemIDs))
+// getItemDetails returns the order lines in a shipment with the quantity
+// shipped and the price at the time, rather than what was ordered
+func (s *PostgresStorage) getItemDetails(shipmentID int) ([]models.Item, error) {
+	rows, err := s.DB.Query(`
+		SELECT oi.id, oi.name, oi.size, oi.color, si.price, si.quantity, si.estimated
+		FROM shipment_items si
+		JOIN order_items oi ON oi.id = si.order_item_id
+		WHERE si.shipment_id = $1
+		ORDER BY oi.id
+	`, shipmentID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to fetch items for shipment %d: %v", shipmentID, err)
 	}
 	defer rows.Close()
 
-	var items []models.Item
+	items := []models.Item{}
 	for rows.Next() {
 		var item models.Item
-		err := rows.Scan(&item.ID, &item.Name, &item.Price, &item.Quantity)
+		err := rows.Scan(&item.ID, &item.Name, &item.Size, &item.Color, &item.Price, &item.Quantity, &item.Estimated)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to scan shipment item: %v", err)
 		}
 		items = append(items, item)
 	}
 
-	return items, nil
+	return items, rows.Err()
 }
 
 func (s *PostgresStorage) GetDueItems(orderID int) ([]DueItem, error) {
 	var dueItems []DueItem
 	query := `
-		SELECT item_id, quantity
+		SELECT item_id, destination_id, quantity
 		FROM due_orders
-		WHERE order_id = $1;
+		WHERE order_id = $1
+		ORDER BY destination_id NULLS FIRST, item_id;
 	`
 
 	rows, err := s.DB.Query(query, orderID)
@@ -293,7 +491,7 @@ func (s *PostgresStorage) GetDueItems(orderID int) ([]DueItem, error) {
 
 	for rows.Next() {
 		var dueItem DueItem
-		if err := rows.Scan(&dueItem.ItemID, &dueItem.Quantity); err != nil {
+		if err := rows.Scan(&dueItem.ItemID, &dueItem.DestinationID, &dueItem.Quantity); err != nil {
 			return nil, fmt.Errorf("error scanning due item: %w", err)
 		}
 		dueItems = append(dueItems, dueItem)
@@ -302,17 +500,19 @@ func (s *PostgresStorage) GetDueItems(orderID int) ([]DueItem, error) {
 	return dueItems, nil
 }
 
-// DueItem struct
+// DueItem struct. DestinationID is set for orders split across
+// destinations, which owe each line per destination.
 type DueItem struct {
-	ItemID   int `json:"item_id"`
-	Quantity int `json:"quantity"`
+	ItemID        int  `json:"item_id"`
+	DestinationID *int `json:"destination_id,omitempty"`
+	Quantity      int  `json:"quantity"`
 }
 
 func (s *PostgresStorage) GetTotalSalesForShippedOrders() (float64, error) {
 	query := `
 		SELECT COALESCE(SUM(o.total_price), 0) AS total_sales
 		FROM orders o
-		WHERE TRIM(o.order_status) = 'shipped'
+		WHERE TRIM(o.order_status) IN ('shipped', 'delivered')
 	`
 	var totalSales float64
 	err := s.DB.QueryRow(query).Scan(&totalSales)
@@ -328,10 +528,11 @@ func (s *PostgresStorage) GetTotalSalesForShippedOrdersByCustomer(customerName s
 	query := `
 		SELECT COALESCE(SUM(o.total_price), 0) AS total_sales
 		FROM orders o
-		WHERE TRIM(o.order_status) = 'shipped' AND TRIM(o.customer_name) ILIKE $1
+		WHERE TRIM(o.order_status) IN ('shipped', 'delivered')
+			AND o.customer_id IN (SELECT id FROM customers WHERE LOWER(name) = LOWER(TRIM($1)))
 	`
 	var totalSales float64
-	err := s.DB.QueryRow(query, "%"+customerName+"%").Scan(&totalSales)
+	err := s.DB.QueryRow(query, customerName).Scan(&totalSales)
 	if err != nil {
 
 		return 0, err
@@ -344,7 +545,7 @@ func (s *PostgresStorage) GetTotalSalesForShippedOrdersByCustomer(customerName s
 
 func (s *PostgresStorage) GetShipmentByID(shipmentID int) (*models.Shipment, error) {
 	query := `
-        SELECT s.id, s.order_id, s.shipped_date::DATE, s.items::int[], s.due_order_type
+        SELECT ` + shipmentColumns + `
         FROM shipments s
         WHERE s.id = $1
     `
@@ -352,21 +553,15 @@ func (s *PostgresStorage) GetShipmentByID(shipmentID int) (*models.Shipment, err
 	row := s.DB.QueryRow(query, shipmentID)
 
 	var shipment models.Shipment
-	var shippedDate string
-	var itemIDs pq.Int64Array
-
-	err := row.Scan(&shipment.ID, &shipment.OrderID, &shippedDate, &itemIDs, &shipment.DueOrderType)
+	err := scanShipment(row, &shipment)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("shipment not found")
+			return nil, fmt.Errorf("shipment %d: %w", shipmentID, ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to scan shipment row: %v", err)
 	}
 
-	shipment.ShippedDate = shippedDate
-
-	shipment.Items, err = s.getItemDetails(itemIDs)
-	if err != nil {
+	if err := s.loadShipmentDetails(&shipment); err != nil {
 		return nil, err
 	}
 
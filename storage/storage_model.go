@@ -2,6 +2,7 @@ package storage
 
 import (
 	"AAHAOMS/OMS/models"
+	"time"
 )
 
 type Storage interface {
@@ -11,14 +12,38 @@ type Storage interface {
 
 	GetCustomerByID(id string) (*models.Customer, error)
 	GetAllCustomers() ([]models.Customer, error)
+	SearchCustomers(q string, page, pageSize int) (models.CustomerPage, error)
+	GetCustomerOptions(q string, limit int) ([]models.CustomerOption, error)
+	StreamCustomers(q string, fn func(models.Customer) error) error
+	FindDuplicateCustomers(customerID int, threshold float64, limit int) ([]models.DuplicateCandidate, error)
+	MergeCustomers(targetID, sourceID int, actor string) (models.CustomerMerge, error)
+	GetCustomerMerges(customerID int) ([]models.CustomerMerge, error)
 	CountCustumer() (int, error)
 	DeleteCustomer(id int) error
+	SetCustomerNotificationOptOut(id int, optOut bool) error
+
+	// Addresses and contacts
+	GetCustomerAddresses(customerID int) ([]models.CustomerAddress, error)
+	GetCustomerAddress(customerID, id int) (models.CustomerAddress, error)
+	CreateCustomerAddress(address models.CustomerAddress) (int, error)
+	UpdateCustomerAddress(address models.CustomerAddress) error
+	DeleteCustomerAddress(customerID, id int) error
+	GetCustomerContacts(customerID int) ([]models.CustomerContact, error)
+	GetCustomerContact(customerID, id int) (models.CustomerContact, error)
+	CreateCustomerContact(contact models.CustomerContact) (int, error)
+	UpdateCustomerContact(contact models.CustomerContact) error
+	DeleteCustomerContact(customerID, id int) error
 
 	///Order
 	CreateOrder(order models.Order) (int, error)
 	GetOrderHistoryByCustomerName(customerName string) ([]models.Order, error)
+	GetOrdersByCustomerID(customerID int) ([]models.Order, error)
+	GetCustomerSales(customerID int) (models.CustomerSales, error)
+	GetCustomerSummary(customerID int, recent int) (models.CustomerSummary, error)
 	GetOrderByID(orderID int) (models.Order, error)
 	GetAllOrders() ([]models.Order, error)
+	GetOrders(filter models.OrderFilter) ([]models.Order, error)
+	StreamOrderLines(filter models.OrderFilter, fn func(models.OrderLine) error) error
 	UpdateOrderStatus(orderID int, status string) error
 	DeleteOrder(orderID int) error
 	GetTotalOrderValueByCustomerName(customerName string) (float64, error)
@@ -28,11 +53,17 @@ type Storage interface {
 	GetOrdersByNameAndDate(customerName string, orderDate string) ([]models.Order, error)
 	TotalOrderCount() (int, error)
 	GetRecentOrders(limit int) ([]models.Order, error)
+	GetOrderDestinations(orderID int) ([]models.OrderDestination, error)
+	SetOrderDestinations(orderID int, req models.OrderDestinations) error
+	DeleteOrderDestinations(orderID int) error
 
 	//Shipement
 	DeleteShipment(shipmentID int) error
-	HandleShipment(shipment models.Shipment) error
+	HandleShipment(shipment models.Shipment) (int, error)
 	GetAllShipments() ([]models.Shipment, error)
+	GetShipments(filter models.ShipmentFilter) ([]models.Shipment, error)
+	StreamShipmentLines(filter models.ShipmentFilter, fn func(models.ShipmentLine) error) error
+
 	GetCompletedShipments() ([]models.Shipment, error)
 	GetShippedButPendingShipments() ([]models.Shipment, error)
 	GetDueItems(orderID int) ([]DueItem, error)
@@ -40,7 +71,71 @@ type Storage interface {
 	GetTotalSalesForShippedOrders() (float64, error)
 	GetTotalSalesForShippedOrdersByCustomer(customerName string) (float64, error)
 	GetShipmentByName(customerName string) ([]models.Shipment, error)
+	GetShipmentsByCustomerID(customerID int) ([]models.Shipment, error)
 	GetShipmentByID(shipmentID int) (*models.Shipment, error)
+	SaveShipmentLabel(label models.ShipmentLabel) (int, error)
+	GetShipmentLabel(shipmentID int) (models.ShipmentLabel, error)
+	CancelShipmentLabel(shipmentID int) error
+	AddTrackingEvent(event models.TrackingEvent) (int, error)
+	GetTrackingEvents(shipmentID int) ([]models.TrackingEvent, error)
+	FindShipmentByTrackingNumber(trackingNumber string) (int, error)
+
+	// Products and customs
+	GetProducts() ([]models.Product, error)
+	GetProduct(id int) (models.Product, error)
+	CreateProduct(p models.Product) (int, error)
+	UpdateProduct(p models.Product) error
+	DeleteProduct(id int) error
+	GetCustomsLines(shipmentID int) ([]models.CustomsLine, error)
+
+	// Pick lists
+	CreatePickList(orderIDs []int) (int, error)
+	GetPickLists(status string) ([]models.PickList, error)
+	GetPickList(id int) (models.PickList, error)
+	SavePickListPacking(id int, req models.PackRequest) error
+	ShipPickListOrder(id, orderID int, shipments []models.Shipment) ([]int, error)
+	CancelPickList(id int) error
+
+	// Imports
+	ImportCustomers(rows []models.CustomerImport, opts models.ImportOptions) (models.ImportResult, error)
+	ImportOrders(rows []models.OrderImport, opts models.ImportOptions) (models.ImportResult, error)
+
+	// Reports
+	GetSalesReport(filter models.SalesFilter) (models.SalesReport, error)
+	GetBacklog(filter models.BacklogFilter) (models.BacklogReport, error)
+	GetFulfillmentReport(filter models.FulfillmentFilter) (models.FulfillmentReport, error)
+
+	// Notifications
+	RecordOrderNotification(notification models.OrderNotification) error
+	GetOrderNotifications(orderID int) ([]models.OrderNotification, error)
+
+	// Webhooks
+	CreateWebhookSubscription(sub models.WebhookSubscription) (int, error)
+	GetWebhookSubscriptions() ([]models.WebhookSubscription, error)
+	DeleteWebhookSubscription(id int) error
+	SetWebhookSubscriptionActive(id int, active bool) error
+	EnqueueWebhookEvent(eventID, eventType string, payload []byte) (int, error)
+	ClaimWebhookDeliveries(limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	MarkWebhookDelivered(id int, statusCode int) error
+	MarkWebhookFailed(id int, statusCode *int, errMsg string, retryAt *time.Time) error
+	GetWebhookDeliveries(subscriptionID int, limit int) ([]models.WebhookDelivery, error)
+	ReplayWebhookDelivery(id int) (int, error)
+
+	// Background jobs
+	EnqueueJob(kind string, payload any, dedupeKey string) (int, error)
+	ClaimJob(kinds []string) (*models.Job, error)
+	CompleteJob(id int) error
+	FailJob(id int, errMsg string, retryAt *time.Time) error
+	RequeueStaleJobs(timeout time.Duration) (int, error)
+	GetJobs(status, kind string, limit int) ([]models.Job, error)
+	GetJobCounts() (map[string]int, error)
+	GetJobByID(id int) (*models.Job, error)
+	RetryJob(id int) error
+
+	// Audit log
+	RecordAudit(entry models.AuditEntry) error
+	GetAuditEntries(filter models.AuditFilter) ([]models.AuditEntry, error)
+	StreamAuditEntries(filter models.AuditFilter, fn func(models.AuditEntry) error) error
 
 	// Auth user
 	VerifyOtp(user models.AuthUser) (bool, error)
@@ -48,4 +143,5 @@ type Storage interface {
 	AddOtp(user models.AuthUser) error
 	AddAuthUser(user models.AuthUser) error
 	IsKeyInStorage(token string) (bool, error)
+	GetKeyEmail(token string) (string, error)
 }
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"database/sql"
+	"fmt"
+)
+
+// AddTrackingEvent records a shipment event and moves the shipment to the
+// combined status of its packages, each at its latest event, setting the
+// delivered date once every package is delivered. The order is refreshed so
+// it becomes delivered once all of its shipments are. An event already
+// recorded is an ErrConflict, so carriers can safely resend.
+func (s *PostgresStorage) AddTrackingEvent(event models.TrackingEvent) (int, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var orderID int
+	err = tx.QueryRow(`SELECT order_id FROM shipments WHERE id = $1`, event.ShipmentID).Scan(&orderID)
+	if err != nil {
+		return 0, notFound(err, "shipment %d", event.ShipmentID)
+	}
+	if _, err := s.getOrderStatus(tx, orderID); err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO shipment_events (shipment_id, tracking_number, status, description, location, occurred_at, source)
+		VALUES ($1, $2, $3, $4, $5, COALESCE(NULLIF($6, '')::timestamptz, NOW()), $7)
+		ON CONFLICT (shipment_id, tracking_number, status, occurred_at) DO NOTHING
+		RETURNING id
+	`, event.ShipmentID, event.TrackingNumber, event.Status, event.Description, event.Location, event.OccurredAt, event.Source).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("event already recorded for shipment %d: %w", event.ShipmentID, ErrConflict)
+	}
+	if err != nil {
+		return 0, classify(err)
+	}
+
+	if err := updateShipmentStatus(tx, event.ShipmentID); err != nil {
+		return 0, err
+	}
+
+	if _, err := s.refreshOrderFulfillment(tx, orderID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit tracking event: %v", err)
+	}
+	return id, nil
+}
+
+// updateShipmentStatus sets a shipment's status from the latest event of each
+// of its packages. Packages are the tracking numbers on the shipment or its
+// events; a shipment without any is treated as a single package. Events with
+// no tracking number count towards every package.
+func updateShipmentStatus(tx *sql.Tx, shipmentID int) error {
+	rows, err := tx.Query(`
+		WITH packages AS (
+			SELECT tracking_number FROM shipment_packages WHERE shipment_id = $1 AND tracking_number <> ''
+			UNION
+			SELECT tracking_number FROM shipment_events WHERE shipment_id = $1 AND tracking_number <> ''
+		), keys AS (
+			SELECT tracking_number FROM packages
+			UNION ALL
+			SELECT '' WHERE NOT EXISTS (SELECT 1 FROM packages)
+		)
+		SELECT COALESCE(e.status, $2), e.occurred_at
+		FROM keys k
+		LEFT JOIN LATERAL (
+			SELECT status, occurred_at
+			FROM shipment_events
+			WHERE shipment_id = $1 AND tracking_number IN (k.tracking_number, '')
+			ORDER BY occurred_at DESC, id DESC
+			LIMIT 1
+		) e ON TRUE
+	`, shipmentID, models.ShipmentCreated)
+	if err != nil {
+		return fmt.Errorf("failed to fetch package statuses for shipment %d: %v", shipmentID, err)
+	}
+	defer rows.Close()
+
+	var statuses []string
+	var deliveredAt sql.NullTime
+	for rows.Next() {
+		var status string
+		var occurredAt sql.NullTime
+		if err := rows.Scan(&status, &occurredAt); err != nil {
+			return fmt.Errorf("failed to scan package status: %v", err)
+		}
+		statuses = append(statuses, status)
+		if status == models.ShipmentDelivered && occurredAt.Time.After(deliveredAt.Time) {
+			deliveredAt = occurredAt
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	status := models.CombinedShipmentStatus(statuses)
+	if status != models.ShipmentDelivered {
+		deliveredAt = sql.NullTime{}
+	}
+	_, err = tx.Exec(`
+		UPDATE shipments SET status = $2, delivered_date = $3::timestamptz::DATE WHERE id = $1
+	`, shipmentID, status, deliveredAt)
+	if err != nil {
+		return fmt.Errorf("failed to update shipment %d status: %v", shipmentID, err)
+	}
+	return nil
+}
+
+// GetTrackingEvents lists a shipment's events, oldest first
+func (s *PostgresStorage) GetTrackingEvents(shipmentID int) ([]models.TrackingEvent, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, shipment_id, tracking_number, status, description, location, occurred_at, source
+		FROM shipment_events
+		WHERE shipment_id = $1
+		ORDER BY occurred_at, id
+	`, shipmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch events for shipment %d: %v", shipmentID, err)
+	}
+	defer rows.Close()
+
+	events := []models.TrackingEvent{}
+	for rows.Next() {
+		var e models.TrackingEvent
+		if err := rows.Scan(&e.ID, &e.ShipmentID, &e.TrackingNumber, &e.Status, &e.Description, &e.Location, &e.OccurredAt, &e.Source); err != nil {
+			return nil, fmt.Errorf("failed to scan tracking event: %v", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// FindShipmentByTrackingNumber returns the shipment with a package carrying
+// the tracking number
+func (s *PostgresStorage) FindShipmentByTrackingNumber(trackingNumber string) (int, error) {
+	var id int
+	err := s.DB.QueryRow(`
+		SELECT shipment_id FROM shipment_packages
+		WHERE tracking_number = $1
+		ORDER BY id DESC
+		LIMIT 1
+	`, trackingNumber).Scan(&id)
+	if err != nil {
+		return 0, notFound(err, "tracking number %q", trackingNumber)
+	}
+	return id, nil
+}
@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"AAHAOMS/OMS/models"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func (s *PostgresStorage) CreateWebhookSubscription(sub models.WebhookSubscription) (int, error) {
+	query := `
+		INSERT INTO webhook_subscriptions (url, event_types, secret, active)
+		VALUES ($1, $2, $3, TRUE)
+		RETURNING id
+	`
+	var id int
+	err := s.DB.QueryRow(query, sub.URL, pq.Array(sub.EventTypes), sub.Secret).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook subscription: %v", err)
+	}
+	return id, nil
+}
+
+func (s *PostgresStorage) GetWebhookSubscriptions() ([]models.WebhookSubscription, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, url, event_types, active, created_at
+		FROM webhook_subscriptions
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch webhook subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	subs := []models.WebhookSubscription{}
+	for rows.Next() {
+		var sub models.WebhookSubscription
+		var events pq.StringArray
+		if err := rows.Scan(&sub.ID, &sub.URL, &events, &sub.Active, &sub.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %v", err)
+		}
+		sub.EventTypes = events
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (s *PostgresStorage) DeleteWebhookSubscription(id int) error {
+	res, err := s.DB.Exec(`DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return requireRow(res, "webhook %d", id)
+}
+
+// SetWebhookSubscriptionActive pauses or resumes a subscription
+func (s *PostgresStorage) SetWebhookSubscriptionActive(id int, active bool) error {
+	res, err := s.DB.Exec(`UPDATE webhook_subscriptions SET active = $2 WHERE id = $1`, id, active)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook subscription: %v", err)
+	}
+	return requireRow(res, "webhook %d", id)
+}
+
+// EnqueueWebhookEvent queues one delivery of the event for every active
+// subscription listening to it and returns how many were queued. Enqueueing
+// the same event ID twice is a no-op, so retried jobs don't double-deliver.
+func (s *PostgresStorage) EnqueueWebhookEvent(eventID, eventType string, payload []byte) (int, error) {
+	query := `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		SELECT id, $1, $2, $3
+		FROM webhook_subscriptions
+		WHERE active AND ($2 = ANY(event_types) OR '*' = ANY(event_types))
+		ON CONFLICT (subscription_id, event_id) WHERE replay_of IS NULL DO NOTHING
+	`
+	res, err := s.DB.Exec(query, eventID, eventType, payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue webhook event: %v", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// ClaimWebhookDeliveries picks up to limit deliveries that are due and
+// pushes their next attempt out by lease, so a crashed worker's deliveries
+// are retried rather than lost or sent twice concurrently. Deliveries still
+// pending for deactivated subscriptions are cancelled instead.
+func (s *PostgresStorage) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	_, err := s.DB.Exec(`
+		UPDATE webhook_deliveries d
+		SET status = 'cancelled', next_attempt_at = NULL, last_error = 'subscription is inactive'
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id AND NOT s.active AND d.status = 'pending'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel webhook deliveries: %v", err)
+	}
+
+	query := `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + make_interval(secs => $2), attempts = d.attempts + 1
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id
+		  AND s.active
+		  AND d.id IN (
+			SELECT wd.id FROM webhook_deliveries wd
+			JOIN webhook_subscriptions ws ON ws.id = wd.subscription_id
+			WHERE wd.status = 'pending' AND wd.next_attempt_at <= NOW() AND ws.active
+			ORDER BY wd.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF wd SKIP LOCKED
+		  )
+		RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.attempts, s.url, s.secret
+	`
+	rows, err := s.DB.Query(query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload, &d.Attempts, &d.URL, &d.Secret); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %v", err)
+		}
+		d.Status = "pending"
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (s *PostgresStorage) MarkWebhookDelivered(id int, statusCode int) error {
+	_, err := s.DB.Exec(`
+		UPDATE webhook_deliveries
+		SET status = 'delivered', last_status_code = $2, last_error = NULL, next_attempt_at = NULL, delivered_at = NOW()
+		WHERE id = $1
+	`, id, statusCode)
+	return err
+}
+
+// MarkWebhookFailed records a failed attempt. A nil retryAt means the
+// delivery has used up its attempts and is given up on.
+func (s *PostgresStorage) MarkWebhookFailed(id int, statusCode *int, errMsg string, retryAt *time.Time) error {
+	status := "pending"
+	if retryAt == nil {
+		status = "failed"
+	}
+	_, err := s.DB.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $2, last_status_code = $3, last_error = $4, next_attempt_at = $5
+		WHERE id = $1
+	`, id, status, statusCode, errMsg, retryAt)
+	return err
+}
+
+func (s *PostgresStorage) GetWebhookDeliveries(subscriptionID int, limit int) ([]models.WebhookDelivery, error) {
+	query := `
+		SELECT id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+		       last_status_code, COALESCE(last_error, ''), replay_of, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE subscription_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`
+	rows, err := s.DB.Query(query, subscriptionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch webhook deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var nextAttempt, deliveredAt sql.NullString
+		var statusCode, replayOf sql.NullInt64
+		err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+			&nextAttempt, &statusCode, &d.LastError, &replayOf, &d.CreatedAt, &deliveredAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %v", err)
+		}
+		if nextAttempt.Valid {
+			d.NextAttemptAt = &nextAttempt.String
+		}
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.String
+		}
+		if statusCode.Valid {
+			code := int(statusCode.Int64)
+			d.LastStatusCode = &code
+		}
+		if replayOf.Valid {
+			id := int(replayOf.Int64)
+			d.ReplayOf = &id
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// ReplayWebhookDelivery queues a fresh copy of an earlier delivery and
+// returns the new delivery's ID. The original row is kept for the log.
+func (s *PostgresStorage) ReplayWebhookDelivery(id int) (int, error) {
+	query := `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, replay_of)
+		SELECT subscription_id, event_id, event_type, payload, id
+		FROM webhook_deliveries
+		WHERE id = $1
+		RETURNING id
+	`
+	var newID int
+	err := s.DB.QueryRow(query, id).Scan(&newID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("webhook delivery %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to replay webhook delivery: %v", err)
+	}
+	return newID, nil
+}
@@ -0,0 +1,136 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"AAHAOMS/OMS/models"
+	"AAHAOMS/OMS/storage"
+)
+
+// Dispatcher polls the delivery queue and POSTs due deliveries to their
+// subscribers, retrying failures with exponential backoff.
+type Dispatcher struct {
+	Store        storage.Storage
+	Client       *http.Client
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+}
+
+// NewDispatcher creates a dispatcher with the default retry policy:
+// up to 8 attempts, starting 30s apart and doubling up to 6h.
+func NewDispatcher(store storage.Storage) *Dispatcher {
+	return &Dispatcher{
+		Store:        store,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+		PollInterval: 5 * time.Second,
+		BatchSize:    20,
+		MaxAttempts:  8,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   6 * time.Hour,
+	}
+}
+
+// Run delivers queued webhooks until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce claims and attempts one batch of due deliveries
+func (d *Dispatcher) RunOnce(ctx context.Context) {
+	lease := d.Client.Timeout + d.PollInterval
+	deliveries, err := d.Store.ClaimWebhookDeliveries(d.BatchSize, lease)
+	if err != nil {
+		log.Printf("Error claiming webhook deliveries: %v", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		d.attempt(ctx, delivery)
+	}
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, delivery models.WebhookDelivery) {
+	statusCode, err := d.send(ctx, delivery)
+	if err == nil {
+		if err := d.Store.MarkWebhookDelivered(delivery.ID, statusCode); err != nil {
+			log.Printf("Error marking webhook delivery %d delivered: %v", delivery.ID, err)
+		}
+		return
+	}
+
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+
+	var retryAt *time.Time
+	if delivery.Attempts < d.MaxAttempts {
+		next := time.Now().Add(d.backoff(delivery.Attempts))
+		retryAt = &next
+	}
+
+	log.Printf("Webhook delivery %d attempt %d to %s failed: %v", delivery.ID, delivery.Attempts, delivery.URL, err)
+	if err := d.Store.MarkWebhookFailed(delivery.ID, code, err.Error(), retryAt); err != nil {
+		log.Printf("Error recording webhook delivery %d failure: %v", delivery.ID, err)
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "OMS-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(delivery.ID))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait before the attempt following the given one
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= d.MaxBackoff {
+			return d.MaxBackoff
+		}
+	}
+	return wait
+}
@@ -0,0 +1,72 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Event types that can be subscribed to. "*" subscribes to all of them.
+const (
+	OrderCreated          = "order.created"
+	OrderStatusUpdated    = "order.status_updated"
+	OrderDeleted          = "order.deleted"
+	ShipmentCreated       = "shipment.created"
+	ShipmentDeleted       = "shipment.deleted"
+	ShipmentStatusUpdated = "shipment.status_updated"
+	CustomerCreated       = "customer.created"
+	CustomerUpdated       = "customer.updated"
+	CustomerDeleted       = "customer.deleted"
+)
+
+var EventTypes = []string{
+	OrderCreated,
+	OrderStatusUpdated,
+	OrderDeleted,
+	ShipmentCreated,
+	ShipmentDeleted,
+	ShipmentStatusUpdated,
+	CustomerCreated,
+	CustomerUpdated,
+	CustomerDeleted,
+}
+
+// IsKnownEvent reports whether a subscription may listen to eventType
+func IsKnownEvent(eventType string) bool {
+	if eventType == "*" {
+		return true
+	}
+	for _, e := range EventTypes {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Envelope is the JSON body POSTed to subscribers
+type Envelope struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	CreatedAt string `json:"created_at"`
+	Data      any    `json:"data"`
+}
+
+// NewEventID returns a random event ID
+func NewEventID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return "evt_" + hex.EncodeToString(b)
+}
+
+// NewEnvelope wraps data in an envelope and encodes it. Callers that may
+// publish the same event more than once should pass a stable id.
+func NewEnvelope(id, eventType string, data any) ([]byte, error) {
+	return json.Marshal(Envelope{
+		ID:        id,
+		Type:      eventType,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Data:      data,
+	})
+}
@@ -0,0 +1,46 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderSignature = "X-OMS-Signature"
+	HeaderTimestamp = "X-OMS-Timestamp"
+	HeaderEvent     = "X-OMS-Event"
+	HeaderDelivery  = "X-OMS-Delivery"
+)
+
+// Sign returns the signature header value for body sent at timestamp.
+// The HMAC covers "<timestamp>.<body>" so a captured request can't be
+// replayed later with a new timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a received delivery's signature and rejects timestamps
+// older than tolerance. Receivers can use it directly.
+func Verify(secret, signature, timestamp string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(strings.TrimSpace(timestamp), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp header")
+	}
+	if tolerance > 0 && time.Since(time.Unix(ts, 0)) > tolerance {
+		return fmt.Errorf("timestamp outside tolerance")
+	}
+	expected := Sign(secret, ts, body)
+	if !hmac.Equal([]byte(expected), []byte(strings.TrimSpace(signature))) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}